@@ -0,0 +1,587 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	data "github.com/markor147/peverel/internal/data"
+	"github.com/markor147/peverel/internal/log"
+)
+
+// apiPrefix is the path prefix of the JSON API.
+const apiPrefix = "/api/"
+
+// openAPIPath serves the OpenAPI document of the JSON API, kept in assets/api/openapi.json.
+// It must be updated together with the routes of apiRoutes and the request and response types.
+const openAPIPath = "/api/openapi.json"
+
+// maxAPIBodySize bounds the size of the JSON request bodies.
+const maxAPIBodySize = 1 << 20
+
+// apiTask is the JSON representation of a task.
+// Dates are formatted as YYYY-MM-DD and omitted when not set.
+type apiTask struct {
+	Id            data.TaskId       `json:"id"`
+	Name          string            `json:"name"`
+	Description   string            `json:"description"`
+	Period        int               `json:"period,omitempty"`
+	Recurrence    string            `json:"recurrence,omitempty"`
+	Schedule      data.Schedule     `json:"schedule"`
+	Anchor        string            `json:"anchor,omitempty"`
+	Due           string            `json:"due,omitempty"`
+	GroupId       *data.GroupId     `json:"group_id"`
+	Group         string            `json:"group,omitempty"`
+	AssigneeId    *data.MemberId    `json:"assignee_id"`
+	Assignee      string            `json:"assignee,omitempty"`
+	RotationMode  data.RotationMode `json:"rotation_mode,omitempty"`
+	Rotation      []data.MemberId   `json:"rotation,omitempty"`
+	LastCompleted *time.Time        `json:"last_completed"`
+	Completions   int               `json:"completions"`
+	NextDue       string            `json:"next_due,omitempty"`
+	SnoozedUntil  string            `json:"snoozed_until,omitempty"`
+	SkippedUntil  string            `json:"skipped_until,omitempty"`
+	Done          bool              `json:"done"`
+}
+
+// apiTaskRequest is the body of the requests creating or replacing a task.
+// A null or missing group_id or assignee_id leaves the task without group or assignee.
+type apiTaskRequest struct {
+	Name         string          `json:"name"`
+	Description  string          `json:"description"`
+	Period       int             `json:"period"`
+	Recurrence   string          `json:"recurrence"`
+	Schedule     string          `json:"schedule"`
+	Anchor       string          `json:"anchor"`
+	Due          string          `json:"due"`
+	GroupId      *data.GroupId   `json:"group_id"`
+	AssigneeId   *data.MemberId  `json:"assignee_id"`
+	RotationMode string          `json:"rotation_mode"`
+	Rotation     []data.MemberId `json:"rotation"`
+}
+
+// apiCompleteRequest is the optional body of the requests completing a task.
+type apiCompleteRequest struct {
+	MemberId *data.MemberId `json:"member_id"`
+}
+
+// apiSnoozeRequest is the body of the requests snoozing a task, until a date or for a number of days.
+type apiSnoozeRequest struct {
+	Until string `json:"until"`
+	Days  int    `json:"days"`
+}
+
+// apiCompletion is the JSON representation of a completion.
+type apiCompletion struct {
+	Id       data.CompletionId `json:"id"`
+	TaskId   data.TaskId       `json:"task_id"`
+	At       time.Time         `json:"at"`
+	MemberId *data.MemberId    `json:"member_id"`
+	By       string            `json:"by,omitempty"`
+	Note     string            `json:"note,omitempty"`
+}
+
+// apiError is the body of the error responses of the JSON API.
+type apiError struct {
+	Error apiErrorBody `json:"error"`
+}
+
+type apiErrorBody struct {
+	Code    string            `json:"code"`
+	Message string            `json:"message"`
+	Fields  map[string]string `json:"fields,omitempty"` // invalid fields of the request body
+}
+
+// apiMethods are the methods tried on an API path without a route for the method of the request.
+var apiMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete}
+
+// apiRoutes returns the handlers of the JSON API by route pattern.
+// Every route must be described in assets/api/openapi.json, which the tests check.
+func (s *server) apiRoutes() map[string]http.HandlerFunc {
+	return map[string]http.HandlerFunc{
+		"GET " + openAPIPath:               getOpenAPI,
+		"GET /api/v1/tasks":                s.apiGetTasks,
+		"POST /api/v1/tasks":               s.apiPostTask,
+		"GET /api/v1/tasks/{id}":           s.apiGetTask,
+		"PUT /api/v1/tasks/{id}":           s.apiPutTask,
+		"DELETE /api/v1/tasks/{id}":        s.apiDeleteTask,
+		"POST /api/v1/tasks/{id}/complete": s.apiCompleteTask,
+		"POST /api/v1/tasks/{id}/skip":     s.apiSkipTask,
+		"POST /api/v1/tasks/{id}/snooze":   s.apiSnoozeTask,
+		"DELETE /api/v1/tasks/{id}/snooze": s.apiWakeTask,
+		"GET /api/v1/tasks/{id}/history":   s.apiGetTaskHistory,
+	}
+}
+
+// registerAPI registers the routes of the JSON API, version 1.
+func (s *server) registerAPI(mux *http.ServeMux) {
+	api := http.NewServeMux()
+	for pattern, handler := range s.apiRoutes() {
+		api.HandleFunc(pattern, handler)
+	}
+	mux.Handle(apiPrefix, serveAPI(api))
+}
+
+// serveAPI serves the requests with a route in the API mux, and answers the others with a JSON error:
+// 405 with the Allow header when the path has routes for other methods, 404 otherwise.
+func serveAPI(api *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, pattern := api.Handler(r); pattern != "" {
+			api.ServeHTTP(w, r)
+			return
+		}
+
+		var allowed []string
+		for _, method := range apiMethods {
+			probe := *r
+			probe.Method = method
+			if _, pattern := api.Handler(&probe); pattern != "" {
+				allowed = append(allowed, method)
+			}
+		}
+		if len(allowed) == 0 {
+			writeAPIError(w, http.StatusNotFound, fmt.Sprintf("no route for %s %s", r.Method, r.URL.Path))
+			return
+		}
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		writeAPIError(w, http.StatusMethodNotAllowed, fmt.Sprintf("%s is not allowed on %s", r.Method, r.URL.Path))
+	})
+}
+
+// getOpenAPI serves the OpenAPI document of the JSON API.
+func getOpenAPI(w http.ResponseWriter, r *http.Request) {
+	doc, err := assetsFS.ReadFile("assets/api/openapi.json")
+	if err != nil {
+		log.Logger.Errorf("read OpenAPI document: %v", err)
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(doc); err != nil {
+		log.Logger.Errorf("write OpenAPI document: %v", err)
+	}
+}
+
+// apiGetTasks lists the tasks, filtered by the group, assignee, days and expired query parameters.
+func (s *server) apiGetTasks(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter, err := parseTaskFilter(q.Get("group"), q.Get("assignee"), q.Get("days"), q.Get("expired"))
+	if err != nil {
+		log.Logger.Errorf("parse tasks filter: %v", err)
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tasks, err := s.store.Tasks(r.Context(), filter)
+	if err != nil {
+		log.Logger.Errorf("get tasks: %v", err)
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	res := make([]apiTask, 0, len(tasks))
+	for _, task := range tasks {
+		res = append(res, newAPITask(task))
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// apiGetTask returns a task.
+func (s *server) apiGetTask(w http.ResponseWriter, r *http.Request) {
+	id, err := pathTaskId(r)
+	if err != nil {
+		log.Logger.Errorf("parse task id: %v", err)
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.writeAPITask(w, r, id, http.StatusOK)
+}
+
+// apiPostTask creates a task and returns it.
+func (s *server) apiPostTask(w http.ResponseWriter, r *http.Request) {
+	task, err := decodeAPITask(w, r)
+	if err != nil {
+		log.Logger.Errorf("decode task: %v", err)
+		writeAPIRequestError(w, err)
+		return
+	}
+	task.LastCompleted = time.Now()
+
+	id, err := s.store.AddTask(r.Context(), task)
+	if err != nil {
+		log.Logger.Errorf("add task: %v", err)
+		writeAPIError(w, dataErrorStatus(err), err.Error())
+		return
+	}
+	s.events.publish(taskEvent{Type: taskCreated, TaskId: id})
+
+	log.Logger.Infof("task %d created", id)
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/tasks/%d", id))
+	s.writeAPITask(w, r, id, http.StatusCreated)
+}
+
+// apiPutTask replaces the fields of a task and returns it.
+func (s *server) apiPutTask(w http.ResponseWriter, r *http.Request) {
+	id, err := pathTaskId(r)
+	if err != nil {
+		log.Logger.Errorf("parse task id: %v", err)
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	task, err := decodeAPITask(w, r)
+	if err != nil {
+		log.Logger.Errorf("decode task: %v", err)
+		writeAPIRequestError(w, err)
+		return
+	}
+
+	if err := s.store.UpdateTask(r.Context(), id, task); err != nil {
+		log.Logger.Errorf("update task with id %d: %v", id, err)
+		writeAPIError(w, dataErrorStatus(err), err.Error())
+		return
+	}
+	s.events.publish(taskEvent{Type: taskUpdated, TaskId: id})
+
+	s.writeAPITask(w, r, id, http.StatusOK)
+}
+
+// apiDeleteTask removes a task.
+func (s *server) apiDeleteTask(w http.ResponseWriter, r *http.Request) {
+	id, err := pathTaskId(r)
+	if err != nil {
+		log.Logger.Errorf("parse task id: %v", err)
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := s.store.DeleteTask(r.Context(), id); err != nil {
+		log.Logger.Errorf("delete task with id %d: %v", id, err)
+		writeAPIError(w, dataErrorStatus(err), err.Error())
+		return
+	}
+	s.events.publish(taskEvent{Type: taskDeleted, TaskId: id})
+
+	log.Logger.Infof("task %d deleted", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiCompleteTask marks a task as completed, by the member of the optional body, and returns it.
+func (s *server) apiCompleteTask(w http.ResponseWriter, r *http.Request) {
+	id, err := pathTaskId(r)
+	if err != nil {
+		log.Logger.Errorf("parse task id: %v", err)
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var req apiCompleteRequest
+	if err := decodeJSON(w, r, &req); err != nil && !errors.Is(err, io.EOF) {
+		log.Logger.Errorf("decode completion: %v", err)
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	by := data.NoMember
+	if req.MemberId != nil {
+		by = *req.MemberId
+	}
+
+	if err := s.store.CompleteTask(r.Context(), id, by); err != nil {
+		log.Logger.Errorf("complete task with id %d: %v", id, err)
+		writeAPIError(w, dataErrorStatus(err), err.Error())
+		return
+	}
+	s.events.publish(taskEvent{Type: taskCompleted, TaskId: id})
+
+	s.writeAPITask(w, r, id, http.StatusOK)
+}
+
+// apiSkipTask skips the next occurrence of a task without completing it and returns the task.
+func (s *server) apiSkipTask(w http.ResponseWriter, r *http.Request) {
+	id, err := pathTaskId(r)
+	if err != nil {
+		log.Logger.Errorf("parse task id: %v", err)
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := s.store.SkipTask(r.Context(), id); err != nil {
+		log.Logger.Errorf("skip task with id %d: %v", id, err)
+		writeAPIError(w, dataErrorStatus(err), err.Error())
+		return
+	}
+	s.events.publish(taskEvent{Type: taskUpdated, TaskId: id})
+
+	s.writeAPITask(w, r, id, http.StatusOK)
+}
+
+// apiSnoozeTask hides a task from the due lists until the date, or for the number of days, of the body
+// and returns the task.
+func (s *server) apiSnoozeTask(w http.ResponseWriter, r *http.Request) {
+	id, err := pathTaskId(r)
+	if err != nil {
+		log.Logger.Errorf("parse task id: %v", err)
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	until, err := decodeAPISnooze(w, r)
+	if err != nil {
+		log.Logger.Errorf("decode snooze: %v", err)
+		writeAPIRequestError(w, err)
+		return
+	}
+
+	if err := s.store.SnoozeTask(r.Context(), id, until); err != nil {
+		log.Logger.Errorf("snooze task with id %d: %v", id, err)
+		writeAPIError(w, dataErrorStatus(err), err.Error())
+		return
+	}
+	s.events.publish(taskEvent{Type: taskUpdated, TaskId: id})
+
+	s.writeAPITask(w, r, id, http.StatusOK)
+}
+
+// apiWakeTask wakes a snoozed task up and returns it.
+func (s *server) apiWakeTask(w http.ResponseWriter, r *http.Request) {
+	id, err := pathTaskId(r)
+	if err != nil {
+		log.Logger.Errorf("parse task id: %v", err)
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := s.store.SnoozeTask(r.Context(), id, time.Time{}); err != nil {
+		log.Logger.Errorf("wake task with id %d: %v", id, err)
+		writeAPIError(w, dataErrorStatus(err), err.Error())
+		return
+	}
+	s.events.publish(taskEvent{Type: taskUpdated, TaskId: id})
+
+	s.writeAPITask(w, r, id, http.StatusOK)
+}
+
+// apiGetTaskHistory lists the completions of a task, most recent first.
+func (s *server) apiGetTaskHistory(w http.ResponseWriter, r *http.Request) {
+	id, err := pathTaskId(r)
+	if err != nil {
+		log.Logger.Errorf("parse task id: %v", err)
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Completions of a missing task are an empty list, a 404 is more useful
+	if _, err := s.store.GetTask(r.Context(), id); err != nil {
+		log.Logger.Errorf("get task with id %d: %v", id, err)
+		writeAPIError(w, dataErrorStatus(err), err.Error())
+		return
+	}
+	completions, err := s.store.Completions(r.Context(), id)
+	if err != nil {
+		log.Logger.Errorf("get completions of task with id %d: %v", id, err)
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	res := make([]apiCompletion, 0, len(completions))
+	for _, c := range completions {
+		res = append(res, newAPICompletion(c))
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// writeAPITask fetches a task and writes it with the given status.
+func (s *server) writeAPITask(w http.ResponseWriter, r *http.Request, id data.TaskId, status int) {
+	task, err := s.store.GetTask(r.Context(), id)
+	if err != nil {
+		log.Logger.Errorf("get task with id %d: %v", id, err)
+		writeAPIError(w, dataErrorStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, status, newAPITask(task))
+}
+
+// === Helpers ===
+
+// isAPIRequest reports whether the request targets the JSON API.
+func isAPIRequest(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, apiPrefix)
+}
+
+// decodeAPITask decodes and validates the task of the request body.
+func decodeAPITask(w http.ResponseWriter, r *http.Request) (data.Task, error) {
+	var req apiTaskRequest
+	if err := decodeJSON(w, r, &req); errors.Is(err, io.EOF) {
+		return data.Task{}, errors.New("request body is required")
+	} else if err != nil {
+		return data.Task{}, err
+	}
+
+	in := taskInput{
+		Name:         req.Name,
+		Description:  req.Description,
+		Period:       req.Period,
+		Recurrence:   req.Recurrence,
+		Schedule:     req.Schedule,
+		Anchor:       req.Anchor,
+		Due:          req.Due,
+		GroupId:      data.NoGroup,
+		AssigneeId:   data.NoMember,
+		RotationMode: req.RotationMode,
+		Rotation:     req.Rotation,
+	}
+	if req.GroupId != nil {
+		in.GroupId = *req.GroupId
+	}
+	if req.AssigneeId != nil {
+		in.AssigneeId = *req.AssigneeId
+	}
+	return in.task()
+}
+
+// decodeAPISnooze decodes the snooze of the request body and returns the day the task wakes up.
+// Exactly one of until and days must be set.
+func decodeAPISnooze(w http.ResponseWriter, r *http.Request) (time.Time, error) {
+	var req apiSnoozeRequest
+	if err := decodeJSON(w, r, &req); errors.Is(err, io.EOF) {
+		return time.Time{}, errors.New("request body is required")
+	} else if err != nil {
+		return time.Time{}, err
+	}
+
+	switch {
+	case req.Until != "" && req.Days != 0:
+		return time.Time{}, validationError{"until": "cannot be set together with days"}
+	case req.Until != "":
+		until, err := time.Parse("2006-01-02", req.Until)
+		if err != nil {
+			return time.Time{}, validationError{"until": "must be a date as YYYY-MM-DD"}
+		}
+		if !until.After(data.Today()) {
+			return time.Time{}, validationError{"until": "must be after today"}
+		}
+		return until, nil
+	case req.Days > 0:
+		return data.Today().AddDate(0, 0, req.Days), nil
+	case req.Days < 0:
+		return time.Time{}, validationError{"days": "must be positive"}
+	default:
+		return time.Time{}, validationError{"until": "either until or days is required"}
+	}
+}
+
+// decodeJSON decodes the request body into v, rejecting unknown fields and trailing data.
+// It returns io.EOF if the body is empty.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAPIBodySize))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		if errors.Is(err, io.EOF) {
+			return err
+		}
+		return fmt.Errorf("invalid JSON body: %w", err)
+	}
+	if dec.More() {
+		return errors.New("invalid JSON body: unexpected data after the object")
+	}
+	return nil
+}
+
+// writeJSON writes v as the JSON body of the response.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Logger.Errorf("encode JSON response: %v", err)
+	}
+}
+
+// writeAPIError writes an error body with a machine-readable code derived from the status.
+func writeAPIError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, apiError{Error: apiErrorBody{
+		Code:    apiErrorCode(status),
+		Message: message,
+	}})
+}
+
+// writeAPIRequestError writes the error of an invalid request body:
+// a 422 listing the invalid fields for a validationError, a 400 otherwise.
+func writeAPIRequestError(w http.ResponseWriter, err error) {
+	var verr validationError
+	if !errors.As(err, &verr) {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusUnprocessableEntity, apiError{Error: apiErrorBody{
+		Code:    apiErrorCode(http.StatusUnprocessableEntity),
+		Message: verr.Error(),
+		Fields:  verr,
+	}})
+}
+
+// apiErrorCode returns the snake case name of an HTTP status, such as "not_found".
+func apiErrorCode(status int) string {
+	text := http.StatusText(status)
+	if text == "" {
+		return "error"
+	}
+	return strings.ReplaceAll(strings.ToLower(text), " ", "_")
+}
+
+func newAPITask(t data.Task) apiTask {
+	task := apiTask{
+		Id:           t.Id,
+		Name:         t.Name,
+		Description:  t.Description,
+		Period:       t.Period,
+		Recurrence:   t.Recurrence,
+		Schedule:     t.Schedule,
+		Anchor:       apiDate(t.Anchor),
+		Due:          apiDate(t.DueDate),
+		Group:        t.GroupName,
+		Assignee:     t.AssigneeName,
+		RotationMode: t.RotationMode,
+		Rotation:     t.Rotation,
+		Completions:  t.Completions,
+		NextDue:      apiDate(t.NextDue()),
+		SnoozedUntil: apiDate(t.SnoozedUntil),
+		SkippedUntil: apiDate(t.SkippedUntil),
+		Done:         t.Done(),
+	}
+	if t.GroupId != data.NoGroup {
+		task.GroupId = &t.GroupId
+	}
+	if t.AssigneeId != data.NoMember {
+		task.AssigneeId = &t.AssigneeId
+	}
+	if !t.LastCompleted.IsZero() {
+		task.LastCompleted = &t.LastCompleted
+	}
+	return task
+}
+
+func newAPICompletion(c data.Completion) apiCompletion {
+	completion := apiCompletion{
+		Id:     c.Id,
+		TaskId: c.TaskId,
+		At:     c.At,
+		By:     c.By,
+		Note:   c.Note,
+	}
+	if c.MemberId != data.NoMember {
+		completion.MemberId = &c.MemberId
+	}
+	return completion
+}
+
+// apiDate formats a day as YYYY-MM-DD, or "" for the zero time.
+func apiDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format("2006-01-02")
+}
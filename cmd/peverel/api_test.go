@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/markor147/peverel/internal/data"
+)
+
+// openAPIDoc is the part of the OpenAPI document checked against the code.
+type openAPIDoc struct {
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas map[string]openAPISchema `json:"schemas"`
+	} `json:"components"`
+}
+
+type openAPISchema struct {
+	Properties map[string]struct {
+		Enum []string `json:"enum"`
+	} `json:"properties"`
+	Required []string `json:"required"`
+}
+
+func readOpenAPI(t *testing.T) openAPIDoc {
+	t.Helper()
+	raw, err := assetsFS.ReadFile("assets/api/openapi.json")
+	if err != nil {
+		t.Fatalf("read OpenAPI document: %v", err)
+	}
+	var doc openAPIDoc
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		t.Fatalf("parse OpenAPI document: %v", err)
+	}
+	return doc
+}
+
+// jsonFields returns the JSON names of the fields of the struct, and those that are never omitted.
+func jsonFields(typ reflect.Type) (all, always []string) {
+	for i := range typ.NumField() {
+		name, opts, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		all = append(all, name)
+		if !strings.Contains(opts, "omitempty") {
+			always = append(always, name)
+		}
+	}
+	sort.Strings(all)
+	sort.Strings(always)
+	return all, always
+}
+
+func TestOpenAPIRoutes(t *testing.T) {
+	doc := readOpenAPI(t)
+
+	documented := make(map[string]bool)
+	for path, operations := range doc.Paths {
+		for method := range operations {
+			if method != "parameters" {
+				documented[strings.ToUpper(method)+" "+path] = true
+			}
+		}
+	}
+	s := &server{}
+	for pattern := range s.apiRoutes() {
+		if !documented[pattern] {
+			t.Errorf("route %s is not in the OpenAPI document", pattern)
+		}
+		delete(documented, pattern)
+	}
+	for pattern := range documented {
+		t.Errorf("OpenAPI operation %s has no route", pattern)
+	}
+}
+
+func TestOpenAPISchemas(t *testing.T) {
+	doc := readOpenAPI(t)
+
+	tests := []struct {
+		schema   string
+		typ      any
+		response bool // whether the schema lists the fields never omitted as required
+	}{
+		{"Task", apiTask{}, true},
+		{"Completion", apiCompletion{}, true},
+		{"Error", apiError{}, true},
+		{"TaskRequest", apiTaskRequest{}, false},
+		{"CompleteRequest", apiCompleteRequest{}, false},
+		{"SnoozeRequest", apiSnoozeRequest{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.schema, func(t *testing.T) {
+			schema, ok := doc.Components.Schemas[tt.schema]
+			if !ok {
+				t.Fatalf("schema %s is not in the OpenAPI document", tt.schema)
+			}
+			properties := make([]string, 0, len(schema.Properties))
+			for name := range schema.Properties {
+				properties = append(properties, name)
+			}
+			sort.Strings(properties)
+
+			all, always := jsonFields(reflect.TypeOf(tt.typ))
+			if !slices.Equal(properties, all) {
+				t.Errorf("properties %v, want the fields %v", properties, all)
+			}
+			if tt.response {
+				required := slices.Sorted(slices.Values(schema.Required))
+				if !slices.Equal(required, always) {
+					t.Errorf("required %v, want the fields never omitted %v", required, always)
+				}
+			}
+		})
+	}
+}
+
+func TestOpenAPIEnums(t *testing.T) {
+	doc := readOpenAPI(t)
+	request := doc.Components.Schemas["TaskRequest"].Properties
+
+	for _, value := range request["schedule"].Enum {
+		if _, err := data.ParseSchedule(value); err != nil {
+			t.Errorf("documented schedule %q is rejected: %v", value, err)
+		}
+	}
+	for _, value := range request["rotation_mode"].Enum {
+		if _, err := data.ParseRotationMode(value); err != nil {
+			t.Errorf("documented rotation mode %q is rejected: %v", value, err)
+		}
+	}
+	if len(request["schedule"].Enum) == 0 || len(request["rotation_mode"].Enum) == 0 {
+		t.Errorf("the schedule and rotation_mode of TaskRequest must list their values")
+	}
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/markor147/peverel/internal/data"
+	"github.com/markor147/peverel/internal/log"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	sessionCookie = "peverel_session"
+	sessionTTL    = 30 * 24 * time.Hour
+
+	// csrfHeader carries the CSRF token of the session on htmx requests,
+	// csrfField on plain form submissions.
+	csrfHeader = "X-CSRF-Token"
+	csrfField  = "csrf_token"
+)
+
+// dummyPasswordHash is compared against when the username does not exist,
+// so that a failed login takes the same time whether the user exists or not.
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("peverel"), bcrypt.DefaultCost)
+
+type contextKey int
+
+const (
+	userContextKey contextKey = iota
+	sessionContextKey
+	apiTokenContextKey
+)
+
+// userFromContext returns the signed in user stored in the context by requireLogin.
+func userFromContext(ctx context.Context) (data.User, bool) {
+	user, ok := ctx.Value(userContextKey).(data.User)
+	return user, ok
+}
+
+// sessionFromContext returns the session stored in the context by requireLogin.
+func sessionFromContext(ctx context.Context) (data.Session, bool) {
+	session, ok := ctx.Value(sessionContextKey).(data.Session)
+	return session, ok
+}
+
+// apiTokenFromContext returns the API token stored in the context by requireLogin,
+// if the request was authenticated by one.
+func apiTokenFromContext(ctx context.Context) (data.APIToken, bool) {
+	token, ok := ctx.Value(apiTokenContextKey).(data.APIToken)
+	return token, ok
+}
+
+// requireLogin lets through the requests of signed in users only,
+// and sends the others to the login page.
+// API requests may be authenticated by a bearer API token instead of the session cookie.
+// Static assets, the login page and the OpenAPI document are public.
+func (s *server) requireLogin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/static/") || r.URL.Path == "/login" || r.URL.Path == openAPIPath {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if bearer, fromQuery, ok := bearerToken(r); ok {
+			s.authenticateToken(w, r, next, bearer, fromQuery)
+			return
+		}
+
+		session, user, err := s.sessionUser(r)
+		if errors.Is(err, data.ErrNotFound) {
+			redirectToLogin(w, r)
+			return
+		}
+		if err != nil {
+			log.Logger.Errorf("get session user: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), userContextKey, user)
+		ctx = context.WithValue(ctx, sessionContextKey, session)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// requireCSRF rejects the POST, PUT and DELETE requests that do not carry the CSRF token
+// of the session, either in the X-CSRF-Token header or in the csrf_token form field.
+// It must run after requireLogin. The login form is exempt, as there is no session yet,
+// and so are the requests authenticated by an API token, which browsers do not send on their own.
+func requireCSRF(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost, http.MethodPut, http.MethodDelete:
+		default:
+			next.ServeHTTP(w, r)
+			return
+		}
+		if _, ok := apiTokenFromContext(r.Context()); ok || r.URL.Path == "/login" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		session, ok := sessionFromContext(r.Context())
+		token := r.Header.Get(csrfHeader)
+		if token == "" {
+			token = r.PostFormValue(csrfField)
+		}
+		if !ok || session.CSRFToken == "" ||
+			subtle.ConstantTimeCompare([]byte(token), []byte(session.CSRFToken)) != 1 {
+			log.Logger.Warnf("%s %s: invalid CSRF token", r.Method, r.URL.Path)
+			if isAPIRequest(r) {
+				writeAPIError(w, http.StatusForbidden, "invalid CSRF token")
+				return
+			}
+			http.Error(w, "invalid CSRF token", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// sessionUser returns the session of the cookie of the request and its user.
+// It returns data.ErrNotFound if there is no valid session.
+func (s *server) sessionUser(r *http.Request) (data.Session, data.User, error) {
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return data.Session{}, data.User{}, fmt.Errorf("session cookie: %w", data.ErrNotFound)
+	}
+	session, err := s.store.GetSession(r.Context(), hashToken(cookie.Value))
+	if err != nil {
+		return data.Session{}, data.User{}, err
+	}
+	user, err := s.store.GetUser(r.Context(), session.UserId)
+	if err != nil {
+		return data.Session{}, data.User{}, err
+	}
+	return session, user, nil
+}
+
+// redirectToLogin sends the browser to the login page,
+// coming back to the requested page after signing in.
+// htmx requests are redirected by the HX-Redirect header, API requests just get a 401.
+func redirectToLogin(w http.ResponseWriter, r *http.Request) {
+	if isAPIRequest(r) {
+		writeAPIError(w, http.StatusUnauthorized, "login required")
+		return
+	}
+	if r.Header.Get("HX-Request") != "" {
+		w.Header().Set("HX-Redirect", "/login")
+		http.Error(w, "login required", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "login required", http.StatusUnauthorized)
+		return
+	}
+	http.Redirect(w, r, "/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+}
+
+// getLogin renders the login page.
+func getLogin(t *template.Template) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		renderLogin(w, r, t, http.StatusOK, r.URL.Query().Get("next"), "")
+	}
+}
+
+// postLogin checks the submitted credentials, opens a session and redirects to the requested page.
+func (s *server) postLogin(t *template.Template) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		username := strings.TrimSpace(r.FormValue("username"))
+		password := r.FormValue("password")
+		next := r.FormValue("next")
+
+		user, err := s.store.GetUserByName(ctx, username)
+		if err != nil && !errors.Is(err, data.ErrNotFound) {
+			log.Logger.Errorf("get user %q: %v", username, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err != nil {
+			_ = bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+			log.Logger.Warnf("login failed: unknown user %q", username)
+			renderLogin(w, r, t, http.StatusUnauthorized, next, "invalid username or password")
+			return
+		}
+		if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+			log.Logger.Warnf("login failed: wrong password for user %q", username)
+			renderLogin(w, r, t, http.StatusUnauthorized, next, "invalid username or password")
+			return
+		}
+
+		token, err := newToken()
+		if err != nil {
+			log.Logger.Errorf("generate session token: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		csrfToken, err := newToken()
+		if err != nil {
+			log.Logger.Errorf("generate CSRF token: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		now := time.Now()
+		if err := s.store.AddSession(ctx, data.Session{
+			TokenHash: hashToken(token),
+			UserId:    user.Id,
+			CSRFToken: csrfToken,
+			CreatedAt: now,
+			ExpiresAt: now.Add(sessionTTL),
+		}); err != nil {
+			log.Logger.Errorf("add session for user %q: %v", username, err)
+			http.Error(w, err.Error(), dataErrorStatus(err))
+			return
+		}
+		if err := s.store.DeleteExpiredSessions(ctx, now); err != nil {
+			log.Logger.Warnf("delete expired sessions: %v", err)
+		}
+
+		http.SetCookie(w, &http.Cookie{
+			Name:     sessionCookie,
+			Value:    token,
+			Path:     "/",
+			Expires:  now.Add(sessionTTL),
+			HttpOnly: true,
+			Secure:   r.TLS != nil,
+			SameSite: http.SameSiteLaxMode,
+		})
+		log.Logger.Infof("user %q logged in", username)
+		http.Redirect(w, r, safeNext(next), http.StatusSeeOther)
+	}
+}
+
+// postLogout closes the current session and redirects to the login page.
+func (s *server) postLogout(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		if err := s.store.DeleteSession(r.Context(), hashToken(cookie.Value)); err != nil && !errors.Is(err, data.ErrNotFound) {
+			log.Logger.Errorf("delete session: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
+
+func renderLogin(w http.ResponseWriter, r *http.Request, t *template.Template, status int, next, message string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := t.ExecuteTemplate(w, "base", newPage(r, map[string]any{
+		"Next":  next,
+		"Error": message,
+	})); err != nil {
+		log.Logger.Errorf("execute template %q: %v", "login.html", err)
+	}
+}
+
+// === Helpers ===
+
+// hashPassword hashes a password with bcrypt.
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// newToken returns a random URL-safe token.
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken returns the hex SHA-256 of a token, which is what the store keeps.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// safeNext returns the page to go to after signing in,
+// falling back to the home page for anything but a local path.
+// Browsers read a backslash as a slash and drop tabs and newlines, so "/\host" and "/<tab>/host"
+// would lead to another site: backslashes and control characters are refused anywhere.
+func safeNext(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.ContainsRune(next, '\\') {
+		return "/"
+	}
+	if u, err := url.Parse(next); err != nil || u.Scheme != "" || u.Host != "" {
+		return "/"
+	}
+	return next
+}
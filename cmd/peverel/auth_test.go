@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/markor147/peverel/internal/data"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	testUsername = "admin"
+	testPassword = "secret123"
+)
+
+// testServer is the whole web UI and JSON API served on a fresh MemStore with one user.
+type testServer struct {
+	*httptest.Server
+	store  *data.MemStore
+	userId data.UserId
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	store := data.NewMemStore()
+	hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("hash password: %v", err)
+	}
+	userId, err := store.AddUser(context.Background(), data.User{Username: testUsername, PasswordHash: string(hash), CreatedAt: time.Now()})
+	if err != nil {
+		t.Fatalf("AddUser: %v", err)
+	}
+
+	s := &server{store: store, events: newEventHub()}
+	handler, err := s.handler()
+	if err != nil {
+		t.Fatalf("handler: %v", err)
+	}
+	srv := httptest.NewServer(handler)
+	t.Cleanup(func() {
+		s.events.close()
+		srv.Close()
+	})
+	return &testServer{Server: srv, store: store, userId: userId}
+}
+
+// newClient returns a client keeping the cookies and not following the redirects.
+func (ts *testServer) newClient(t *testing.T) *http.Client {
+	t.Helper()
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatalf("cookie jar: %v", err)
+	}
+	return &http.Client{
+		Jar:           jar,
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+}
+
+// login signs the client in and returns the CSRF token of its session.
+func (ts *testServer) login(t *testing.T, client *http.Client) string {
+	t.Helper()
+	resp, err := client.PostForm(ts.URL+"/login", url.Values{"username": {testUsername}, "password": {testPassword}})
+	if err != nil {
+		t.Fatalf("POST /login: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("POST /login: status %d, want %d", resp.StatusCode, http.StatusSeeOther)
+	}
+
+	_, body := ts.do(t, client, http.MethodGet, "/", nil, nil)
+	m := regexp.MustCompile(`name="csrf_token" value="([^"]+)"`).FindStringSubmatch(body)
+	if m == nil {
+		t.Fatalf("no CSRF token in the home page")
+	}
+	return m[1]
+}
+
+// do sends the request with the headers and returns the response, with its body read.
+func (ts *testServer) do(t *testing.T, client *http.Client, method, path string, body io.Reader, header http.Header) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, ts.URL+path, body)
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read %s %s: %v", method, path, err)
+	}
+	return resp, string(b)
+}
+
+// addTask adds a task due today to the store.
+func (ts *testServer) addTask(t *testing.T, name string) data.TaskId {
+	t.Helper()
+	id, err := ts.store.AddTask(context.Background(), data.Task{Name: name, Period: 1, LastCompleted: time.Now().AddDate(0, 0, -1)})
+	if err != nil {
+		t.Fatalf("AddTask: %v", err)
+	}
+	return id
+}
+
+func TestLoginRequired(t *testing.T) {
+	ts := newTestServer(t)
+	client := ts.newClient(t)
+
+	resp, _ := ts.do(t, client, http.MethodGet, "/tasks/new", nil, nil)
+	if resp.StatusCode != http.StatusSeeOther || resp.Header.Get("Location") != "/login?next=%2Ftasks%2Fnew" {
+		t.Errorf("GET /tasks/new: %d to %q, want a redirect to the login page", resp.StatusCode, resp.Header.Get("Location"))
+	}
+
+	resp, _ = ts.do(t, client, http.MethodGet, "/tasks", nil, http.Header{"Hx-Request": {"true"}})
+	if resp.StatusCode != http.StatusUnauthorized || resp.Header.Get("HX-Redirect") != "/login" {
+		t.Errorf("htmx GET /tasks: %d to %q, want 401 with HX-Redirect", resp.StatusCode, resp.Header.Get("HX-Redirect"))
+	}
+
+	resp, body := ts.do(t, client, http.MethodGet, "/api/v1/tasks", nil, nil)
+	if resp.StatusCode != http.StatusUnauthorized || !strings.Contains(body, `"unauthorized"`) {
+		t.Errorf("GET /api/v1/tasks: %d %s, want a JSON 401", resp.StatusCode, body)
+	}
+
+	for _, path := range []string{"/login", "/static/style.css", openAPIPath} {
+		if resp, _ := ts.do(t, client, http.MethodGet, path, nil, nil); resp.StatusCode != http.StatusOK {
+			t.Errorf("GET %s: status %d, want public", path, resp.StatusCode)
+		}
+	}
+}
+
+func TestLogin(t *testing.T) {
+	ts := newTestServer(t)
+	client := ts.newClient(t)
+
+	for _, creds := range []url.Values{
+		{"username": {testUsername}, "password": {"wrong"}},
+		{"username": {"nobody"}, "password": {testPassword}},
+	} {
+		resp, err := client.PostForm(ts.URL+"/login", creds)
+		if err != nil {
+			t.Fatalf("POST /login: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized || len(resp.Cookies()) != 0 {
+			t.Errorf("login as %s: status %d with %d cookies, want 401 without session", creds.Get("username"), resp.StatusCode, len(resp.Cookies()))
+		}
+	}
+
+	resp, err := client.PostForm(ts.URL+"/login", url.Values{
+		"username": {testUsername}, "password": {testPassword}, "next": {"/settings"},
+	})
+	if err != nil {
+		t.Fatalf("POST /login: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSeeOther || resp.Header.Get("Location") != "/settings" {
+		t.Fatalf("login: %d to %q, want a redirect to /settings", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	var cookie *http.Cookie
+	for _, c := range resp.Cookies() {
+		if c.Name == sessionCookie {
+			cookie = c
+		}
+	}
+	if cookie == nil || !cookie.HttpOnly || cookie.SameSite != http.SameSiteLaxMode {
+		t.Fatalf("session cookie = %+v, want an HttpOnly SameSite=Lax cookie", cookie)
+	}
+	// The store keeps the hash of the session token only
+	if _, err := ts.store.GetSession(context.Background(), cookie.Value); err == nil {
+		t.Errorf("the session is found by its clear token")
+	}
+	if _, err := ts.store.GetSession(context.Background(), hashToken(cookie.Value)); err != nil {
+		t.Errorf("GetSession: %v", err)
+	}
+
+	if resp, _ := ts.do(t, client, http.MethodGet, "/settings", nil, nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("GET /settings once signed in: status %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestLogout(t *testing.T) {
+	ts := newTestServer(t)
+	client := ts.newClient(t)
+	csrf := ts.login(t, client)
+
+	resp, _ := ts.do(t, client, http.MethodPost, "/logout", nil, http.Header{"X-Csrf-Token": {csrf}})
+	if resp.StatusCode != http.StatusSeeOther || resp.Header.Get("Location") != "/login" {
+		t.Fatalf("POST /logout: %d to %q, want a redirect to /login", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	if resp, _ := ts.do(t, client, http.MethodGet, "/settings", nil, nil); resp.StatusCode != http.StatusSeeOther {
+		t.Errorf("GET /settings after logout: status %d, want a redirect to the login page", resp.StatusCode)
+	}
+}
+
+func TestExpiredSession(t *testing.T) {
+	ts := newTestServer(t)
+	client := ts.newClient(t)
+
+	token := "expired-session"
+	if err := ts.store.AddSession(context.Background(), data.Session{
+		TokenHash: hashToken(token),
+		UserId:    ts.userId,
+		CSRFToken: "csrf",
+		CreatedAt: time.Now().AddDate(0, 0, -31),
+		ExpiresAt: time.Now().Add(-time.Hour),
+	}); err != nil {
+		t.Fatalf("AddSession: %v", err)
+	}
+	u, _ := url.Parse(ts.URL)
+	client.Jar.SetCookies(u, []*http.Cookie{{Name: sessionCookie, Value: token}})
+
+	if resp, _ := ts.do(t, client, http.MethodGet, "/settings", nil, nil); resp.StatusCode != http.StatusSeeOther {
+		t.Errorf("GET /settings with an expired session: status %d, want a redirect to the login page", resp.StatusCode)
+	}
+}
+
+func TestLoginRefusesOpenRedirects(t *testing.T) {
+	ts := newTestServer(t)
+
+	tests := []struct {
+		next string
+		want string
+	}{
+		{"/tasks/new", "/tasks/new"},
+		{"/tasks?group=1", "/tasks?group=1"},
+		{"", "/"},
+		{"https://evil.example/", "/"},
+		{"//evil.example/", "/"},
+		{"/\\evil.example/", "/"},
+		{"/\t/evil.example/", "/"},
+		{"/\n/evil.example/", "/"},
+		{"/tasks\\..\\\\evil.example", "/"},
+		{"javascript:alert(1)", "/"},
+		{"evil.example", "/"},
+	}
+	for _, tt := range tests {
+		if got := safeNext(tt.next); got != tt.want {
+			t.Errorf("safeNext(%q) = %q, want %q", tt.next, got, tt.want)
+		}
+
+		client := ts.newClient(t)
+		resp, err := client.PostForm(ts.URL+"/login", url.Values{
+			"username": {testUsername}, "password": {testPassword}, "next": {tt.next},
+		})
+		if err != nil {
+			t.Fatalf("POST /login: %v", err)
+		}
+		resp.Body.Close()
+		if got := resp.Header.Get("Location"); got != tt.want {
+			t.Errorf("login with next %q redirects to %q, want %q", tt.next, got, tt.want)
+		}
+	}
+}
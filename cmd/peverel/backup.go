@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/markor147/peverel/internal/backup"
+	"github.com/markor147/peverel/internal/config"
+	data "github.com/markor147/peverel/internal/data"
+	"github.com/markor147/peverel/internal/log"
+)
+
+const (
+	backupUsage  = "usage: peverel backup [-dir dir] [-keep n] | peverel backup list [-dir dir]"
+	restoreUsage = "usage: peverel restore [-dir dir] <file|latest>"
+)
+
+// runBackup implements the `peverel backup` command, backing up the database while
+// the server may be running, and `peverel backup list`.
+func runBackup(ctx context.Context, db config.Database, cfg config.Backup, args []string) error {
+	dir, keep := cfg.Dir, cfg.Keep
+
+	list := len(args) > 0 && args[0] == "list"
+	if list {
+		args = args[1:]
+	}
+	fs := flag.NewFlagSet("backup", flag.ContinueOnError)
+	fs.StringVar(&dir, "dir", dir, "backups directory")
+	if !list {
+		fs.IntVar(&keep, "keep", keep, "number of backups to keep, 0 to keep them all")
+	}
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 {
+		return errors.New(backupUsage)
+	}
+
+	if list {
+		backups, err := backup.List(dir)
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "CREATED AT\tSIZE\tFILE")
+		for _, b := range backups {
+			fmt.Fprintf(tw, "%s\t%d\t%s\n", b.CreatedAt.Local().Format(time.DateTime), b.Size, b.Path)
+		}
+		return tw.Flush()
+	}
+
+	store, err := data.OpenSQLiteStore(ctx, db.Path, data.WithQueryTimeout(db.QueryTimeout))
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	b, removed, err := backup.Run(ctx, store, dir, keep)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("backed up to %s (%d bytes), integrity ok\n", b.Path, b.Size)
+	for _, r := range removed {
+		fmt.Printf("removed %s\n", r.Path)
+	}
+	return nil
+}
+
+// runRestore implements the `peverel restore` command, replacing the database with a backup.
+// The server must be stopped.
+func runRestore(ctx context.Context, connStr string, cfg config.Backup, args []string) error {
+	dir := cfg.Dir
+
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	fs.StringVar(&dir, "dir", dir, "backups directory, to restore the latest backup")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 1 {
+		return errors.New(restoreUsage)
+	}
+
+	src := fs.Arg(0)
+	if src == "latest" {
+		b, err := backup.Latest(dir)
+		if err != nil {
+			return err
+		}
+		src = b.Path
+	}
+
+	dbPath := data.DatabasePath(connStr)
+	if dbPath == "" {
+		return errors.New("connStr is empty")
+	}
+	previous, err := backup.Restore(ctx, src, dbPath)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("restored %s to %s\n", src, dbPath)
+	if previous != "" {
+		fmt.Printf("the replaced database is kept in %s\n", previous)
+	}
+	return nil
+}
+
+// scheduleBackups backs up the database every cfg.Interval until ctx is done.
+// It does nothing when the interval is zero.
+func scheduleBackups(ctx context.Context, store *data.SQLiteStore, cfg config.Backup) {
+	if cfg.Interval <= 0 {
+		return
+	}
+	dir, keep, interval := cfg.Dir, cfg.Keep, cfg.Interval
+
+	log.Logger.Infof("backing up the database to %q every %s, keeping %d backups", dir, interval, keep)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				b, removed, err := backup.Run(ctx, store, dir, keep)
+				if err != nil {
+					log.Logger.Errorf("scheduled backup: %v", err)
+					continue
+				}
+				log.Logger.Infof("backed up the database to %q", b.Path)
+				for _, r := range removed {
+					log.Logger.Infof("removed backup %q", r.Path)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/markor147/peverel/internal/data"
+	"github.com/markor147/peverel/internal/log"
+)
+
+// calendarPath serves the iCalendar feed. Calendar apps cannot send headers,
+// so besides the session cookie it accepts a token of the calendar scope in the token query parameter.
+// Since calendar URLs end up shared with third-party services, that token gives access to nothing else.
+const calendarPath = "/calendar.ics"
+
+// getCalendar renders the next due day of the tasks as an iCalendar feed of all-day events,
+// filtered by the group and assignee query parameters.
+func (s *server) getCalendar(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter, err := parseTaskFilter(q.Get("group"), q.Get("assignee"), "", "")
+	if err != nil {
+		log.Logger.Errorf("parse tasks filter: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tasks, err := s.store.Tasks(r.Context(), filter)
+	if err != nil {
+		log.Logger.Errorf("get tasks: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="peverel.ics"`)
+	if _, err := w.Write([]byte(renderCalendar(tasks, time.Now()))); err != nil {
+		log.Logger.Errorf("write calendar: %v", err)
+	}
+}
+
+// renderCalendar returns the iCalendar document with an event per task still due.
+// Snoozed tasks are shown on the day they wake up, as on the home page.
+func renderCalendar(tasks []data.Task, now time.Time) string {
+	var b strings.Builder
+	line := func(s string) {
+		b.WriteString(foldICalLine(s))
+		b.WriteString("\r\n")
+	}
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//peverel//peverel//EN")
+	line("CALSCALE:GREGORIAN")
+	line("METHOD:PUBLISH")
+	line("X-WR-CALNAME:peverel")
+	stamp := now.UTC().Format("20060102T150405Z")
+	for _, task := range tasks {
+		day := task.NextDue()
+		if task.Snoozed() {
+			day = task.SnoozedUntil
+		}
+		if day.IsZero() {
+			continue
+		}
+
+		line("BEGIN:VEVENT")
+		line(fmt.Sprintf("UID:task-%d@peverel", task.Id))
+		line("DTSTAMP:" + stamp)
+		line("DTSTART;VALUE=DATE:" + day.Format("20060102"))
+		line("DTEND;VALUE=DATE:" + day.AddDate(0, 0, 1).Format("20060102"))
+		line("SUMMARY:" + escapeICalText(task.Name))
+		line("DESCRIPTION:" + escapeICalText(calendarDescription(task)))
+		if task.GroupName != "" {
+			line("CATEGORIES:" + escapeICalText(task.GroupName))
+		}
+		line("TRANSP:TRANSPARENT")
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+	return b.String()
+}
+
+// calendarDescription describes the task for the body of its event.
+func calendarDescription(task data.Task) string {
+	var lines []string
+	if task.Description != "" {
+		lines = append(lines, task.Description, "")
+	}
+	lines = append(lines, "Repeats: "+task.Frequency())
+	if task.GroupName != "" {
+		lines = append(lines, "Group: "+task.GroupName)
+	}
+	if task.AssigneeName != "" {
+		lines = append(lines, "Assigned to: "+task.AssigneeName)
+	}
+	if task.Snoozed() {
+		lines = append(lines, "Snoozed until "+task.SnoozedUntil.Format("2006-01-02"))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// === Helpers ===
+
+// escapeICalText escapes a TEXT value as required by RFC 5545.
+func escapeICalText(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	).Replace(s)
+}
+
+// foldICalLine splits a content line longer than 75 octets into continuation lines,
+// without breaking UTF-8 sequences.
+func foldICalLine(s string) string {
+	const limit = 75
+	if len(s) <= limit {
+		return s
+	}
+
+	var b strings.Builder
+	width := 0
+	for _, r := range s {
+		n := len(string(r))
+		if width+n > limit {
+			b.WriteString("\r\n ")
+			width = 1
+		}
+		b.WriteRune(r)
+		width += n
+	}
+	return b.String()
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/markor147/peverel/internal/data"
+)
+
+func TestRenderCalendar(t *testing.T) {
+	now := time.Date(2025, 3, 1, 10, 30, 0, 0, time.UTC)
+	snoozed := data.Today().AddDate(0, 0, 5)
+	tasks := []data.Task{
+		{Id: 1, Name: "Water; plants, ferns", Description: `first\second` + "\nthird", DueDate: time.Date(2025, 3, 4, 0, 0, 0, 0, time.UTC), GroupName: "Garden"},
+		{Id: 2, Name: "Done", DueDate: time.Date(2025, 3, 2, 0, 0, 0, 0, time.UTC), Completions: 1},
+		{Id: 3, Name: "Snoozed", DueDate: time.Date(2025, 3, 2, 0, 0, 0, 0, time.UTC), SnoozedUntil: snoozed},
+		{Id: 4, Name: strings.Repeat("é", 60), DueDate: time.Date(2025, 3, 6, 0, 0, 0, 0, time.UTC)},
+	}
+	ics := renderCalendar(tasks, now)
+
+	if !strings.HasPrefix(ics, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n") || !strings.HasSuffix(ics, "END:VCALENDAR\r\n") {
+		t.Errorf("renderCalendar is not a VCALENDAR:\n%s", ics)
+	}
+	if n := strings.Count(ics, "BEGIN:VEVENT"); n != 3 {
+		t.Errorf("renderCalendar has %d events, want 3 without the done task:\n%s", n, ics)
+	}
+	for _, want := range []string{
+		"UID:task-1@peverel\r\n",
+		"DTSTAMP:20250301T103000Z\r\n",
+		"DTSTART;VALUE=DATE:20250304\r\nDTEND;VALUE=DATE:20250305\r\n",
+		`SUMMARY:Water\; plants\, ferns` + "\r\n",
+		`DESCRIPTION:first\\second\nthird\n\nRepeats: once\nGroup: Garden` + "\r\n",
+		"CATEGORIES:Garden\r\n",
+		"UID:task-3@peverel\r\nDTSTAMP:20250301T103000Z\r\nDTSTART;VALUE=DATE:" + snoozed.Format("20060102") + "\r\n",
+	} {
+		if !strings.Contains(ics, want) {
+			t.Errorf("renderCalendar lacks %q:\n%s", want, ics)
+		}
+	}
+	if strings.Contains(ics, "task-2@") {
+		t.Errorf("renderCalendar has the done task:\n%s", ics)
+	}
+
+	for _, line := range strings.Split(strings.TrimSuffix(ics, "\r\n"), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("line of %d octets: %q", len(line), line)
+		}
+		if !utf8.ValidString(line) {
+			t.Errorf("line splits a UTF-8 sequence: %q", line)
+		}
+	}
+	if !strings.Contains(strings.ReplaceAll(ics, "\r\n ", ""), "SUMMARY:"+strings.Repeat("é", 60)+"\r\n") {
+		t.Errorf("the folded summary does not unfold to the task name:\n%s", ics)
+	}
+}
+
+func TestCalendarTokens(t *testing.T) {
+	ts := newTestServer(t)
+	client := ts.newClient(t)
+	read := ts.addAPIToken(t, data.ScopeRead)
+	complete := ts.addAPIToken(t, data.ScopeComplete)
+	calendar := ts.addAPIToken(t, data.ScopeCalendar)
+	ts.addTask(t, "Dishes")
+
+	tests := []struct {
+		name   string
+		path   string
+		header http.Header
+		want   int
+	}{
+		{"calendar token in the URL", calendarPath + "?token=" + calendar, nil, http.StatusOK},
+		{"read token in the URL", calendarPath + "?token=" + read, nil, http.StatusForbidden},
+		{"complete token in the URL", calendarPath + "?token=" + complete, nil, http.StatusForbidden},
+		{"unknown token in the URL", calendarPath + "?token=" + apiTokenPrefix + "unknown", nil, http.StatusUnauthorized},
+		{"read token in the header", calendarPath, bearer(read), http.StatusOK},
+		{"calendar token in the header", calendarPath, bearer(calendar), http.StatusOK},
+		{"no token", calendarPath, nil, http.StatusSeeOther},
+		{"calendar token in the URL of the API", "/api/v1/tasks?token=" + calendar, nil, http.StatusUnauthorized},
+		{"read token in the URL of the API", "/api/v1/tasks?token=" + read, nil, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		resp, body := ts.do(t, client, http.MethodGet, tt.path, nil, tt.header)
+		if resp.StatusCode != tt.want {
+			t.Errorf("%s: GET %s: status %d %s, want %d", tt.name, tt.path, resp.StatusCode, body, tt.want)
+			continue
+		}
+		if tt.want != http.StatusOK {
+			continue
+		}
+		if ct := resp.Header.Get("Content-Type"); ct != "text/calendar; charset=utf-8" {
+			t.Errorf("%s: Content-Type %q, want text/calendar", tt.name, ct)
+		}
+		if !strings.Contains(body, "SUMMARY:Dishes\r\n") {
+			t.Errorf("%s: the calendar lacks the task:\n%s", tt.name, body)
+		}
+	}
+}
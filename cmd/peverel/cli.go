@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/markor147/peverel/internal/config"
+	data "github.com/markor147/peverel/internal/data"
+	"github.com/markor147/peverel/internal/notify"
+)
+
+const usage = `usage: peverel [-config file] [-db file] [-port n] [-log-level level] [-log-output output] [command]
+
+commands:
+  serve     serve the web UI and the JSON API, the default
+  notify    send the expired tasks through the notification channels
+  task      list, add, edit, complete and remove tasks
+  group     list, add, rename and remove groups
+  user      manage the accounts of the web UI
+  migrate   show and apply the schema migrations
+  backup    back up the database and list the backups
+  restore   replace the database with a backup
+  export    export the tasks as JSON or CSV
+  import    import tasks from JSON or CSV`
+
+// Output formats of the commands printing tasks and groups.
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+)
+
+// run runs the command of args, serving by default.
+func run(cfg config.Config, args []string) error {
+	if len(args) == 0 || args[0] == "serve" {
+		if len(args) > 1 {
+			return errors.New("usage: peverel serve")
+		}
+		return runServe(cfg)
+	}
+
+	// Cancel the pending queries on SIGINT and SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	db := cfg.Database
+	switch args[0] {
+	case "notify":
+		return runNotify(ctx, cfg, args[1:])
+	case "task":
+		return runTask(ctx, db, args[1:])
+	case "group":
+		return runGroup(ctx, db, args[1:])
+	case "user":
+		return runUser(ctx, db, args[1:])
+	case "migrate":
+		return runMigrate(ctx, db, args[1:])
+	case "backup":
+		return runBackup(ctx, db, cfg.Backup, args[1:])
+	case "restore":
+		return runRestore(ctx, db.Path, cfg.Backup, args[1:])
+	case "export":
+		return runExport(ctx, db, args[1:])
+	case "import":
+		return runImport(ctx, db, args[1:])
+	case "help":
+		fmt.Println(usage)
+		return nil
+	default:
+		return fmt.Errorf("unknown command %q\n%s", args[0], usage)
+	}
+}
+
+// runNotify implements the `peverel notify` command, doing the job of peverel-notifier:
+// it sends the expired tasks through the configured channels at the scheduled time,
+// or once right away with -now.
+func runNotify(ctx context.Context, cfg config.Config, args []string) error {
+	fs := flag.NewFlagSet("notify", flag.ContinueOnError)
+	now := fs.Bool("now", false, "send the expired tasks once right away, ignoring the scheduled time")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 {
+		return errors.New("usage: peverel notify [-now]")
+	}
+	if err := cfg.ValidateNotifier(); err != nil {
+		return err
+	}
+	if *now {
+		cfg.Notifier.ScheduledTime = ""
+	}
+
+	store, err := openStore(ctx, cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	return notify.Run(ctx, store, cfg.Notifier)
+}
+
+// === Helpers ===
+
+// openStore opens the database of the configuration, with its query timeout,
+// and applies the pending migrations.
+func openStore(ctx context.Context, db config.Database) (*data.SQLiteStore, error) {
+	return data.NewSQLiteStore(ctx, db.Path, data.WithQueryTimeout(db.QueryTimeout))
+}
+
+// parseFlags parses the flags of fs wherever they are among the positional arguments,
+// so that both `task edit 3 -period 7` and `task edit -period 7 3` work, and returns the latter.
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// checkFormat validates the -format flag of the commands printing tasks and groups.
+func checkFormat(format string) error {
+	if format != formatTable && format != formatJSON {
+		return fmt.Errorf("invalid format %q, expected %s or %s", format, formatTable, formatJSON)
+	}
+	return nil
+}
+
+// printJSON writes v as indented JSON.
+func printJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// resolveGroup returns the id of the group given by id or by name, ignoring case.
+// An empty reference or "none" stand for no group.
+func resolveGroup(ctx context.Context, store data.Store, ref string) (data.GroupId, error) {
+	if ref == "" || ref == "none" {
+		return data.NoGroup, nil
+	}
+	if id, err := strconv.Atoi(ref); err == nil {
+		group, err := store.GetGroup(ctx, data.GroupId(id))
+		return group.Id, err
+	}
+
+	groups, err := store.GetGroups(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for _, g := range groups {
+		if strings.EqualFold(g.Name, ref) {
+			return g.Id, nil
+		}
+	}
+	return 0, fmt.Errorf("group %q: %w", ref, data.ErrNotFound)
+}
+
+// resolveMember returns the id of the member given by id or by name, ignoring case.
+// An empty reference or "none" stand for no member.
+func resolveMember(ctx context.Context, store data.Store, ref string) (data.MemberId, error) {
+	if ref == "" || ref == "none" {
+		return data.NoMember, nil
+	}
+	if id, err := strconv.Atoi(ref); err == nil {
+		member, err := store.GetMember(ctx, data.MemberId(id))
+		return member.Id, err
+	}
+
+	members, err := store.GetMembers(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for _, m := range members {
+		if strings.EqualFold(m.Name, ref) {
+			return m.Id, nil
+		}
+	}
+	return 0, fmt.Errorf("member %q: %w", ref, data.ErrNotFound)
+}
+
+// resolveTask returns the task given by id or by name, ignoring case.
+// Completed one-off tasks are found as well.
+func resolveTask(ctx context.Context, store data.Store, ref string) (data.Task, error) {
+	if id, err := strconv.Atoi(ref); err == nil {
+		return store.GetTask(ctx, data.TaskId(id))
+	}
+
+	tasks, err := store.Tasks(ctx, data.TaskFilter{Group: data.AnyGroup, Assignee: data.AnyMember, Done: true})
+	if err != nil {
+		return data.Task{}, err
+	}
+	var found []data.Task
+	for _, t := range tasks {
+		if strings.EqualFold(t.Name, ref) {
+			found = append(found, t)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return data.Task{}, fmt.Errorf("task %q: %w", ref, data.ErrNotFound)
+	case 1:
+		return found[0], nil
+	default:
+		return data.Task{}, fmt.Errorf("%d tasks are named %q, use the id", len(found), ref)
+	}
+}
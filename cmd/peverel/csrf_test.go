@@ -0,0 +1,96 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestCSRF(t *testing.T) {
+	ts := newTestServer(t)
+	client := ts.newClient(t)
+	csrf := ts.login(t, client)
+	other := ts.newClient(t)
+	otherCSRF := ts.login(t, other)
+	if otherCSRF == csrf {
+		t.Fatalf("two sessions share the CSRF token %q", csrf)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   url.Values
+		header http.Header
+		want   int
+	}{
+		{"missing token", http.MethodPost, "/group", url.Values{"name": {"Garden"}}, nil, http.StatusForbidden},
+		{"wrong header", http.MethodPost, "/group", url.Values{"name": {"Garden"}}, http.Header{"X-Csrf-Token": {"wrong"}}, http.StatusForbidden},
+		{"token of another session", http.MethodPost, "/group", url.Values{"name": {"Garden"}}, http.Header{"X-Csrf-Token": {otherCSRF}}, http.StatusForbidden},
+		{"wrong field", http.MethodPost, "/group", url.Values{"name": {"Garden"}, csrfField: {"wrong"}}, nil, http.StatusForbidden},
+		{"missing token on PUT", http.MethodPut, "/group/1", url.Values{"name": {"Yard"}}, nil, http.StatusForbidden},
+		{"missing token on DELETE", http.MethodDelete, "/group/1", nil, nil, http.StatusForbidden},
+		{"missing token on logout", http.MethodPost, "/logout", nil, nil, http.StatusForbidden},
+		{"header", http.MethodPost, "/group", url.Values{"name": {"Garden"}}, http.Header{"X-Csrf-Token": {csrf}}, http.StatusOK},
+		{"form field", http.MethodPut, "/group/1", url.Values{"name": {"Yard"}, csrfField: {csrf}}, nil, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{"Content-Type": {"application/x-www-form-urlencoded"}}
+			for key, values := range tt.header {
+				header[key] = values
+			}
+			resp, body := ts.do(t, client, tt.method, tt.path, strings.NewReader(tt.body.Encode()), header)
+			if resp.StatusCode != tt.want {
+				t.Errorf("%s %s: status %d %q, want %d", tt.method, tt.path, resp.StatusCode, body, tt.want)
+			}
+		})
+	}
+
+	groups, err := ts.store.GetGroups(t.Context())
+	if err != nil {
+		t.Fatalf("GetGroups: %v", err)
+	}
+	if len(groups) != 1 || groups[0].Name != "Yard" {
+		t.Errorf("groups = %+v, want only the group created and renamed with the token", groups)
+	}
+}
+
+func TestCSRFOnAPI(t *testing.T) {
+	ts := newTestServer(t)
+	client := ts.newClient(t)
+	csrf := ts.login(t, client)
+	id := ts.addTask(t, "Dishes")
+	path := "/api/v1/tasks/" + strconv.Itoa(int(id)) + "/complete"
+
+	// The JSON API used with the session cookie needs the token too
+	resp, body := ts.do(t, client, http.MethodPost, path, nil, nil)
+	if resp.StatusCode != http.StatusForbidden || !strings.Contains(body, `"forbidden"`) {
+		t.Errorf("POST %s without token: %d %s, want a JSON 403", path, resp.StatusCode, body)
+	}
+	if resp, body := ts.do(t, client, http.MethodPost, path, nil, http.Header{"X-Csrf-Token": {csrf}}); resp.StatusCode != http.StatusOK {
+		t.Errorf("POST %s with the token: %d %s, want 200", path, resp.StatusCode, body)
+	}
+
+	// Reads need no token
+	if resp, _ := ts.do(t, client, http.MethodGet, "/api/v1/tasks", nil, nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("GET /api/v1/tasks: status %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestCSRFLoginExempt(t *testing.T) {
+	ts := newTestServer(t)
+	client := ts.newClient(t)
+
+	// Signing in needs no token, there is no session yet
+	resp, err := client.PostForm(ts.URL+"/login", url.Values{"username": {testUsername}, "password": {testPassword}})
+	if err != nil {
+		t.Fatalf("POST /login: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Errorf("POST /login: status %d, want %d", resp.StatusCode, http.StatusSeeOther)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/markor147/peverel/internal/data"
+	"github.com/markor147/peverel/internal/log"
+)
+
+// eventsKeepAlive is how often an idle /events stream sends a comment,
+// so that proxies do not close it.
+const eventsKeepAlive = 30 * time.Second
+
+// eventType is the name of a server-sent event.
+type eventType string
+
+const (
+	taskCreated   eventType = "task-created"
+	taskUpdated   eventType = "task-updated" // fields, schedule, history, group or assignee changed
+	taskCompleted eventType = "task-completed"
+	taskDeleted   eventType = "task-deleted"
+)
+
+// taskEvent tells the subscribers that a task changed.
+type taskEvent struct {
+	Type   eventType
+	TaskId data.TaskId
+}
+
+// eventHub fans out the task events to the open /events streams.
+type eventHub struct {
+	mu     sync.Mutex
+	subs   map[chan taskEvent]struct{}
+	done   chan struct{}
+	closed bool
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{
+		subs: make(map[chan taskEvent]struct{}),
+		done: make(chan struct{}),
+	}
+}
+
+// subscribe returns a channel receiving the published events, and the function to unsubscribe.
+func (h *eventHub) subscribe() (<-chan taskEvent, func()) {
+	ch := make(chan taskEvent, 16)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.subs[ch] = struct{}{}
+
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		delete(h.subs, ch)
+	}
+}
+
+// publish sends the event to every subscriber without blocking.
+// Subscribers too slow to keep up miss the event.
+func (h *eventHub) publish(e taskEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch := range h.subs {
+		select {
+		case ch <- e:
+		default:
+			log.Logger.Warnf("drop %s event of task %d for a slow subscriber", e.Type, e.TaskId)
+		}
+	}
+}
+
+// affectedTasks returns the ids of the tasks, completed one-off tasks included, for which match is true,
+// e.g. the tasks of a group about to be renamed.
+func (s *server) affectedTasks(ctx context.Context, match func(data.Task) bool) ([]data.TaskId, error) {
+	tasks, err := s.store.Tasks(ctx, data.TaskFilter{Group: data.AnyGroup, Assignee: data.AnyMember, Done: true})
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]data.TaskId, 0)
+	for _, task := range tasks {
+		if match(task) {
+			ids = append(ids, task.Id)
+		}
+	}
+	return ids, nil
+}
+
+// publishUpdated publishes a taskUpdated event for every task.
+func (s *server) publishUpdated(ids []data.TaskId) {
+	for _, id := range ids {
+		s.events.publish(taskEvent{Type: taskUpdated, TaskId: id})
+	}
+}
+
+// close ends the open streams, which would otherwise keep the server from shutting down.
+func (h *eventHub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.closed {
+		h.closed = true
+		close(h.done)
+	}
+}
+
+// getEvents streams the task events as server-sent events,
+// named after the event type and carrying the task id as JSON data.
+func (s *server) getEvents(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+	events, unsubscribe := s.events.subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		log.Logger.Errorf("flush events stream: %v", err)
+		return
+	}
+
+	ticker := time.NewTicker(eventsKeepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.events.done:
+			return
+		case e := <-events:
+			payload, err := json.Marshal(map[string]any{"id": e.TaskId})
+			if err != nil {
+				log.Logger.Errorf("marshal %s event: %v", e.Type, err)
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, payload)
+		case <-ticker.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		}
+		if err := rc.Flush(); err != nil {
+			log.Logger.Debugf("flush events stream: %v", err)
+			return
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/markor147/peverel/internal/config"
+	data "github.com/markor147/peverel/internal/data"
+)
+
+const groupUsage = `usage: peverel group list [-format table|json]
+       peverel group add <name>
+       peverel group rename <group> <name>
+       peverel group rm <group>
+groups are given by id or by name.`
+
+// apiGroup is the JSON representation of a group printed by `peverel group list`.
+type apiGroup struct {
+	Id   data.GroupId `json:"id"`
+	Name string       `json:"name"`
+}
+
+// runGroup implements the `peverel group` commands.
+func runGroup(ctx context.Context, db config.Database, args []string) error {
+	if len(args) == 0 {
+		return errors.New(groupUsage)
+	}
+
+	store, err := openStore(ctx, db)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	switch args[0] {
+	case "list":
+		fs := flag.NewFlagSet("group list", flag.ContinueOnError)
+		format := fs.String("format", formatTable, "output format, table or json")
+		if err := fs.Parse(args[1:]); err != nil || fs.NArg() != 0 {
+			return errors.New(groupUsage)
+		}
+		if err := checkFormat(*format); err != nil {
+			return err
+		}
+		groups, err := store.GetGroups(ctx)
+		if err != nil {
+			return err
+		}
+		if *format == formatJSON {
+			res := make([]apiGroup, 0, len(groups))
+			for _, g := range groups {
+				res = append(res, apiGroup{Id: g.Id, Name: g.Name})
+			}
+			return printJSON(os.Stdout, res)
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tNAME")
+		for _, g := range groups {
+			fmt.Fprintf(tw, "%d\t%s\n", g.Id, g.Name)
+		}
+		return tw.Flush()
+	case "add":
+		if len(args) != 2 {
+			return errors.New(groupUsage)
+		}
+		name := strings.TrimSpace(args[1])
+		if name == "" {
+			return errors.New("name is required")
+		}
+		id, err := store.AddGroup(ctx, data.Group{Name: name})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("group %d %q added\n", id, name)
+		return nil
+	case "rename":
+		if len(args) != 3 {
+			return errors.New(groupUsage)
+		}
+		id, err := resolveGroup(ctx, store, args[1])
+		if err != nil {
+			return err
+		}
+		name := strings.TrimSpace(args[2])
+		if name == "" {
+			return errors.New("name is required")
+		}
+		if err := store.UpdateGroup(ctx, id, data.Group{Name: name}); err != nil {
+			return err
+		}
+		fmt.Printf("group %d renamed to %q\n", id, name)
+		return nil
+	case "rm":
+		if len(args) != 2 {
+			return errors.New(groupUsage)
+		}
+		id, err := resolveGroup(ctx, store, args[1])
+		if err != nil {
+			return err
+		}
+		if err := store.DeleteGroup(ctx, id); err != nil {
+			return err
+		}
+		fmt.Printf("group %d removed, its tasks are left without a group\n", id)
+		return nil
+	default:
+		return errors.New(groupUsage)
+	}
+}
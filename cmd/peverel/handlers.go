@@ -1,273 +1,870 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"html/template"
 	"net/http"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
-	"github.com/labstack/echo/v4"
 	data "github.com/markor147/peverel/internal/data"
 	"github.com/markor147/peverel/internal/log"
+	"github.com/markor147/peverel/internal/recur"
 )
 
-func GetLayoutHome(c echo.Context) error {
-	return c.Render(http.StatusOK, "layout", map[string]string{
-		"Title":   "peverel - home",
-		"Content": "home",
-	})
+// server holds the dependencies of the HTTP handlers.
+type server struct {
+	store  data.Store
+	events *eventHub
 }
 
-func GetLayoutSettings(c echo.Context) error {
-	return c.Render(http.StatusOK, "layout", map[string]string{
-		"Title":   "peverel - settings",
-		"Content": "settings",
-	})
-}
+// getTasks renders the filtered tasks either as a table or as a list of <option>.
+func (s *server) getTasks(t *template.Template) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		q := r.URL.Query()
+		filter, err := parseTaskFilter(q.Get("group"), q.Get("assignee"), q.Get("days"), q.Get("expired"))
+		if err != nil {
+			log.Logger.Errorf("parse tasks filter: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 
-func GetLayoutAddTask(c echo.Context) error {
-	return c.Render(http.StatusOK, "layout", map[string]string{
-		"Title":   "peverel - new task",
-		"Content": "add-task",
-	})
-}
+		log.Logger.Debugf("function getTasks params: %+v", filter)
+
+		tasks, err := s.store.Tasks(ctx, filter)
+		if err != nil {
+			log.Logger.Errorf("get tasks: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
 
-func GetLayoutEditTask(c echo.Context) error {
-	taskId := c.QueryParam("id")
-	return c.Render(http.StatusOK, "layout", map[string]string{
-		"Title":   "peverel - edit task " + taskId,
-		"Content": "edit-task?id=" + taskId,
-	})
+		name := "tasks-table"
+		if q.Get("layout") == "options" {
+			name = "tasks-options"
+		}
+		if err := t.ExecuteTemplate(w, name, tasks); err != nil {
+			log.Logger.Errorf("execute template %q: %v", name, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
 }
 
-func GetPageSettings(c echo.Context) error {
-	return c.Render(http.StatusOK, "page/settings", nil)
+// postTask creates a new task from the submitted form.
+func (s *server) postTask(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	task, err := parseTaskForm(r)
+	if err != nil {
+		log.Logger.Errorf("parse task form: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	task.LastCompleted = time.Now()
+
+	id, err := s.store.AddTask(ctx, task)
+	if err != nil {
+		log.Logger.Errorf("add task: %v", err)
+		http.Error(w, err.Error(), dataErrorStatus(err))
+		return
+	}
+	s.events.publish(taskEvent{Type: taskCreated, TaskId: id})
+
+	log.Logger.Infof("task %d created", id)
+	w.Header().Set("HX-Redirect", "/")
+	w.WriteHeader(http.StatusCreated)
+	fmt.Fprint(w, "task created successfully")
 }
 
-func GetPageHome(c echo.Context) error {
-	return c.Render(http.StatusOK, "page/home", nil)
+// putTask replaces the fields of an existing task with the submitted form.
+func (s *server) putTask(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := pathTaskId(r)
+	if err != nil {
+		log.Logger.Errorf("parse task id: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	task, err := parseTaskForm(r)
+	if err != nil {
+		log.Logger.Errorf("parse task form: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.store.UpdateTask(ctx, id, task); err != nil {
+		log.Logger.Errorf("update task with id %d: %v", id, err)
+		http.Error(w, err.Error(), dataErrorStatus(err))
+		return
+	}
+	s.events.publish(taskEvent{Type: taskUpdated, TaskId: id})
+
+	w.Header().Set("HX-Redirect", "/")
+	fmt.Fprint(w, "task modified successfully")
 }
 
-func GetPageAddTask(c echo.Context) error {
-	return c.Render(http.StatusOK, "page/add-task", nil)
+// deleteTask removes a task.
+func (s *server) deleteTask(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := pathTaskId(r)
+	if err != nil {
+		log.Logger.Errorf("parse task id: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.store.DeleteTask(ctx, id); err != nil {
+		log.Logger.Errorf("delete task with id %d: %v", id, err)
+		http.Error(w, err.Error(), dataErrorStatus(err))
+		return
+	}
+	s.events.publish(taskEvent{Type: taskDeleted, TaskId: id})
+
+	w.Header().Set("HX-Redirect", "/")
+	fmt.Fprint(w, "task deleted successfully")
 }
 
-func GetPageEditTask(c echo.Context) error {
-	taskId, _ := strconv.Atoi(c.QueryParam("id"))
-	task, _ := data.GetTask(data.TaskId(taskId))
-	return c.Render(http.StatusOK, "page/edit-task", task)
+// putTaskComplete marks a task as completed by the submitted member, or by nobody,
+// and renders the refreshed tasks table.
+func (s *server) putTaskComplete(t *template.Template) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		id, err := pathTaskId(r)
+		if err != nil {
+			log.Logger.Errorf("parse task id: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// The completion is credited to the member picked in "completed by", not to the assignee filter
+		by, err := parseMemberId(r.FormValue("member"))
+		if err != nil {
+			log.Logger.Errorf("parse completion member: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := s.store.CompleteTask(ctx, id, by); err != nil {
+			log.Logger.Errorf("complete task with id %d: %v", id, err)
+			http.Error(w, err.Error(), dataErrorStatus(err))
+			return
+		}
+		s.events.publish(taskEvent{Type: taskCompleted, TaskId: id})
+
+		s.renderTasksTable(w, r, t)
+	}
 }
 
-func PostTask(c echo.Context) error {
-	period, _ := strconv.Atoi(c.FormValue("period"))
-	_, err := data.AddTask(data.Task{
-		Name:        c.FormValue("name"),
-		Description: c.FormValue("description"),
-		Period:      period,
-	})
-	if err != nil {
-		return err
+// putTaskSkip skips the next occurrence of a task without completing it
+// and renders the refreshed tasks table.
+func (s *server) putTaskSkip(t *template.Template) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		id, err := pathTaskId(r)
+		if err != nil {
+			log.Logger.Errorf("parse task id: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := s.store.SkipTask(ctx, id); err != nil {
+			log.Logger.Errorf("skip task with id %d: %v", id, err)
+			http.Error(w, err.Error(), dataErrorStatus(err))
+			return
+		}
+		s.events.publish(taskEvent{Type: taskUpdated, TaskId: id})
+
+		s.renderTasksTable(w, r, t)
 	}
+}
 
-	/*groupId, _ := strconv.Atoi(c.FormValue("group"))
-	if groupId != -1 {
-		err := data.SetRelation(data.GroupId(groupId), taskId)
+// putTaskSnooze hides a task from the due lists until the "until" date, or for "days" days,
+// and renders the refreshed tasks table.
+// The number of days can also be given by the HX-Prompt header.
+func (s *server) putTaskSnooze(t *template.Template) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		id, err := pathTaskId(r)
+		if err != nil {
+			log.Logger.Errorf("parse task id: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		days := r.FormValue("days")
+		if days == "" {
+			days = strings.TrimSpace(r.Header.Get("HX-Prompt"))
+		}
+		until, err := parseSnoozeUntil(r.FormValue("until"), days)
 		if err != nil {
-			return err
+			log.Logger.Errorf("parse snooze: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
-	}*/
 
-	return c.String(http.StatusOK, "task created successfully")
+		if err := s.store.SnoozeTask(ctx, id, until); err != nil {
+			log.Logger.Errorf("snooze task with id %d: %v", id, err)
+			http.Error(w, err.Error(), dataErrorStatus(err))
+			return
+		}
+		s.events.publish(taskEvent{Type: taskUpdated, TaskId: id})
+
+		s.renderTasksTable(w, r, t)
+	}
 }
 
-func GetTasks(c echo.Context) error {
-	groupId := c.QueryParam("group")
-	days := c.QueryParam("days")
-	expired := c.QueryParam("expired") != "false"
+// deleteTaskSnooze wakes a snoozed task up and renders the refreshed tasks table.
+func (s *server) deleteTaskSnooze(t *template.Template) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		id, err := pathTaskId(r)
+		if err != nil {
+			log.Logger.Errorf("parse task id: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := s.store.SnoozeTask(ctx, id, time.Time{}); err != nil {
+			log.Logger.Errorf("wake task with id %d: %v", id, err)
+			http.Error(w, err.Error(), dataErrorStatus(err))
+			return
+		}
+		s.events.publish(taskEvent{Type: taskUpdated, TaskId: id})
 
-	log.Logger.Debugf("functions GetTask params: (group: %v, days: %v, expired: %v)", groupId, days, expired)
+		s.renderTasksTable(w, r, t)
+	}
+}
 
-	tasks, err := data.Tasks(groupId, days, expired)
+// renderTasksTable renders the tasks table filtered by the group and the assignee in the request.
+func (s *server) renderTasksTable(w http.ResponseWriter, r *http.Request, t *template.Template) {
+	filter, err := parseTaskFilter(r.FormValue("group"), r.FormValue("assignee"), "", "")
 	if err != nil {
-		return err
+		log.Logger.Errorf("parse tasks filter: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	layout := c.QueryParam("layout")
-	var template string
-	switch layout {
-	case "options":
-		template = "tasks-options"
-	case "table":
-		template = "tasks-table"
-	default:
-		template = "tasks-table"
+	tasks, err := s.store.Tasks(r.Context(), filter)
+	if err != nil {
+		log.Logger.Errorf("get tasks: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
 
-	return c.Render(http.StatusOK, template, tasks)
+	if err := t.ExecuteTemplate(w, "tasks-table", tasks); err != nil {
+		log.Logger.Errorf("execute template %q: %v", "tasks-table", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
 }
 
-func PutTaskComplete(c echo.Context) error {
-	id, _ := strconv.Atoi(c.Param("id"))
-	taskId := data.TaskId(id)
-	_ = data.CompleteTask(taskId)
+// getTaskNextTime renders how far away the next deadline of a task is.
+func (s *server) getTaskNextTime(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := pathTaskId(r)
+	if err != nil {
+		log.Logger.Errorf("parse task id: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	tasks, err := data.Tasks("", "", true)
+	task, err := s.store.GetTask(ctx, id)
 	if err != nil {
-		return err
+		log.Logger.Errorf("get task with id %d: %v", id, err)
+		http.Error(w, err.Error(), dataErrorStatus(err))
+		return
 	}
 
-	return c.Render(http.StatusOK, "tasks-table", tasks)
+	fmt.Fprint(w, renderTaskNextTime(task))
 }
 
-func DeleteTask(c echo.Context) error {
-	taskId, _ := strconv.Atoi(c.Param("id"))
-	if err := data.DeleteTask(data.TaskId(taskId)); err != nil {
-		log.Logger.Errorf("Error deleting task: %v", err)
-		return err
+func renderTaskNextTime(task data.Task) string {
+	if task.Snoozed() {
+		return "snoozed until " + task.SnoozedUntil.Format("2006-01-02")
+	}
+	due := task.NextDue()
+	if due.IsZero() {
+		return "never"
+	}
+	diff := int(due.Sub(data.Today()).Hours() / 24)
+	switch {
+	case diff == 0:
+		return "today"
+	case diff < 0:
+		return fmt.Sprintf("%d days ago", -diff)
+	default:
+		return fmt.Sprintf("%d days", diff)
 	}
-	return c.String(http.StatusOK, "task deleted successfully")
 }
 
-func PutTask(c echo.Context) error {
-	taskId, _ := strconv.Atoi(c.Param("id"))
-	period, _ := strconv.Atoi(c.FormValue("period"))
-	if err := data.UpdateTask(data.TaskId(taskId), data.Task{
-		Name:        c.FormValue("name"),
-		Description: c.FormValue("description"),
-		Period:      period,
-	}); err != nil {
-		return err
+// postTaskCompletion records a completion of a task, possibly in the past,
+// and renders the refreshed completion history.
+func (s *server) postTaskCompletion(t *template.Template) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		id, err := pathTaskId(r)
+		if err != nil {
+			log.Logger.Errorf("parse task id: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		at, err := parseFormTime(r.FormValue("at"))
+		if err != nil {
+			log.Logger.Errorf("parse completion time: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		memberId, err := parseMemberId(r.FormValue("member"))
+		if err != nil {
+			log.Logger.Errorf("parse completion member: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if _, err := s.store.AddCompletion(ctx, data.Completion{
+			TaskId:   id,
+			At:       at,
+			MemberId: memberId,
+			By:       strings.TrimSpace(r.FormValue("by")),
+			Note:     strings.TrimSpace(r.FormValue("note")),
+		}); err != nil {
+			log.Logger.Errorf("add completion to task with id %d: %v", id, err)
+			http.Error(w, err.Error(), dataErrorStatus(err))
+			return
+		}
+		s.events.publish(taskEvent{Type: taskCompleted, TaskId: id})
+
+		s.renderCompletionsList(ctx, w, t, id)
 	}
-	/*groupId, _ := strconv.Atoi(c.FormValue("group"))
-	if groupId != -1 {
-		err := data.SetRelation(data.GroupId(groupId), data.TaskId(taskId))
+}
+
+// putTaskCompletion back-dates a completion and renders the refreshed completion history.
+func (s *server) putTaskCompletion(t *template.Template) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		id, err := pathTaskId(r)
 		if err != nil {
-			return err
+			log.Logger.Errorf("parse task id: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
-	}*/
-	return c.String(http.StatusOK, "task modified successfully")
+
+		completionId, err := pathCompletionId(r)
+		if err != nil {
+			log.Logger.Errorf("parse completion id: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		at, err := parseFormTime(r.FormValue("at"))
+		if err != nil {
+			log.Logger.Errorf("parse completion time: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := s.store.BackdateCompletion(ctx, id, completionId, at); err != nil {
+			log.Logger.Errorf("backdate completion with id %d of task with id %d: %v", completionId, id, err)
+			http.Error(w, err.Error(), dataErrorStatus(err))
+			return
+		}
+		s.events.publish(taskEvent{Type: taskUpdated, TaskId: id})
+
+		s.renderCompletionsList(ctx, w, t, id)
+	}
 }
 
-func GetTaskNextTime(c echo.Context) error {
-	taskId, _ := strconv.Atoi(c.Param("id"))
-	return c.HTML(http.StatusOK, renderTaskNextTime(data.TaskId(taskId)))
+// deleteTaskCompletion removes a completion and renders the refreshed completion history.
+func (s *server) deleteTaskCompletion(t *template.Template) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		id, err := pathTaskId(r)
+		if err != nil {
+			log.Logger.Errorf("parse task id: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		completionId, err := pathCompletionId(r)
+		if err != nil {
+			log.Logger.Errorf("parse completion id: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := s.store.DeleteCompletion(ctx, id, completionId); err != nil {
+			log.Logger.Errorf("delete completion with id %d of task with id %d: %v", completionId, id, err)
+			http.Error(w, err.Error(), dataErrorStatus(err))
+			return
+		}
+		s.events.publish(taskEvent{Type: taskUpdated, TaskId: id})
+
+		s.renderCompletionsList(ctx, w, t, id)
+	}
 }
 
-func renderTaskNextTime(taskId data.TaskId) string {
-	task, err := data.GetTask(taskId)
+func (s *server) renderCompletionsList(ctx context.Context, w http.ResponseWriter, t *template.Template, id data.TaskId) {
+	completions, err := s.store.Completions(ctx, id)
 	if err != nil {
-		log.Logger.Fatalf("renderTaskNextTime: %v", err)
+		log.Logger.Errorf("get completions of task with id %d: %v", id, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
 
-	layout := "20060102"
-	nextDay := task.LastCompleted.AddDate(0, 0, task.Period)
+	if err := t.ExecuteTemplate(w, "completions-list", map[string]any{
+		"TaskId":      id,
+		"Completions": completions,
+	}); err != nil {
+		log.Logger.Errorf("execute template %q: %v", "completions-list", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
 
-	nextDayStr := nextDay.Format(layout)
-	nextDayTime, _ := time.Parse(layout, nextDayStr)
-	todayStr := time.Now().Format(layout)
-	todayTime, _ := time.Parse(layout, todayStr)
-	if todayStr == nextDayStr {
-		return "today"
+// getGroups renders all the groups either as a list or as a list of <option>.
+// With the options layout, the group id in the "selected" query parameter is preselected.
+func (s *server) getGroups(t *template.Template) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		groups, err := s.store.GetGroups(ctx)
+		if err != nil {
+			log.Logger.Errorf("get groups: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if r.URL.Query().Get("layout") != "options" {
+			renderGroupsList(w, t, groups)
+			return
+		}
+
+		selected, err := parseGroupId(r.URL.Query().Get("selected"))
+		if err != nil {
+			log.Logger.Errorf("parse selected group: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := t.ExecuteTemplate(w, "groups-options", map[string]any{
+			"Groups":   groups,
+			"Selected": selected,
+		}); err != nil {
+			log.Logger.Errorf("execute template %q: %v", "groups-options", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
 	}
-	diff := int(nextDayTime.Sub(todayTime).Hours() / 24)
-	if diff < 0 {
-		return fmt.Sprintf("%d days ago", -diff)
+}
+
+// postGroup creates a new group and renders the refreshed groups list.
+func (s *server) postGroup(t *template.Template) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		name := strings.TrimSpace(r.FormValue("name"))
+		if name == "" {
+			http.Error(w, "name is required", http.StatusBadRequest)
+			return
+		}
+
+		if _, err := s.store.AddGroup(ctx, data.Group{Name: name}); err != nil {
+			log.Logger.Errorf("add group %q: %v", name, err)
+			http.Error(w, err.Error(), dataErrorStatus(err))
+			return
+		}
+
+		s.refreshGroupsList(ctx, w, t)
 	}
-	return fmt.Sprintf("%d days", diff)
 }
 
-/*func GetGroups(c echo.Context) error {
-	template := "groups"
-	layout := c.QueryParam("layout")
-	if layout == "options" {
-		template = "groups-options"
+// putGroup renames a group and renders the refreshed groups list.
+// The new name is read from the form or, when missing, from the htmx prompt.
+func (s *server) putGroup(t *template.Template) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		id, err := pathGroupId(r)
+		if err != nil {
+			log.Logger.Errorf("parse group id: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		name := strings.TrimSpace(r.FormValue("name"))
+		if name == "" {
+			name = strings.TrimSpace(r.Header.Get("HX-Prompt"))
+		}
+		if name == "" {
+			http.Error(w, "name is required", http.StatusBadRequest)
+			return
+		}
+
+		// The tasks of the group are shown with its name
+		affected, err := s.affectedTasks(ctx, func(task data.Task) bool { return task.GroupId == id })
+		if err != nil {
+			log.Logger.Errorf("get tasks of group with id %d: %v", id, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if err := s.store.UpdateGroup(ctx, id, data.Group{Name: name}); err != nil {
+			log.Logger.Errorf("update group with id %d: %v", id, err)
+			http.Error(w, err.Error(), dataErrorStatus(err))
+			return
+		}
+		s.publishUpdated(affected)
+
+		s.refreshGroupsList(ctx, w, t)
 	}
+}
 
-	groups, err := data.GetGroups()
-	if err != nil {
-		return err
+// deleteGroup removes a group and renders the refreshed groups list.
+func (s *server) deleteGroup(t *template.Template) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		id, err := pathGroupId(r)
+		if err != nil {
+			log.Logger.Errorf("parse group id: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// The tasks of the group are left without a group
+		affected, err := s.affectedTasks(ctx, func(task data.Task) bool { return task.GroupId == id })
+		if err != nil {
+			log.Logger.Errorf("get tasks of group with id %d: %v", id, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if err := s.store.DeleteGroup(ctx, id); err != nil {
+			log.Logger.Errorf("delete group with id %d: %v", id, err)
+			http.Error(w, err.Error(), dataErrorStatus(err))
+			return
+		}
+		s.publishUpdated(affected)
+
+		s.refreshGroupsList(ctx, w, t)
 	}
+}
 
-	return c.Render(http.StatusOK, template, groups)
-}*/
+func (s *server) refreshGroupsList(ctx context.Context, w http.ResponseWriter, t *template.Template) {
+	groups, err := s.store.GetGroups(ctx)
+	if err != nil {
+		log.Logger.Errorf("get groups: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	renderGroupsList(w, t, groups)
+}
 
-/*func PostGroup(c echo.Context) error {
-	if _, err := data.AddGroup(&data.Group{
-		Name: c.FormValue("name"),
-	}); err != nil {
-		return err
+func renderGroupsList(w http.ResponseWriter, t *template.Template, groups []data.Group) {
+	if err := t.ExecuteTemplate(w, "groups-list", groups); err != nil {
+		log.Logger.Errorf("execute template %q: %v", "groups-list", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
-	return c.NoContent(http.StatusOK)
-}*/
+}
 
-/*func PutGroupAssignTask(c echo.Context) error {
-	groupId, _ := strconv.Atoi(c.Param("id"))
-	taskId, _ := strconv.Atoi(c.FormValue("assign-task"))
+// === Helpers ===
 
-	err := data.SetRelation(data.GroupId(groupId), data.TaskId(taskId))
+// pathTaskId parses the {id} path value of the request.
+func pathTaskId(r *http.Request) (data.TaskId, error) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		return err
+		return 0, fmt.Errorf("invalid task id %q", idStr)
 	}
+	return data.TaskId(id), nil
+}
 
-	groups, err := data.GetGroups()
+// taskInput holds the fields of a task as submitted by a form or the JSON API, before validation.
+type taskInput struct {
+	Name         string
+	Description  string
+	Period       int
+	Recurrence   string
+	Schedule     string
+	Anchor       string // YYYY-MM-DD
+	Due          string // YYYY-MM-DD, makes a one-off task
+	GroupId      data.GroupId
+	AssigneeId   data.MemberId
+	RotationMode string
+	Rotation     []data.MemberId
+}
+
+// parseTaskForm reads and validates the fields of the new/edit task forms.
+func parseTaskForm(r *http.Request) (data.Task, error) {
+	groupId, err := parseGroupId(r.FormValue("group"))
+	if err != nil {
+		return data.Task{}, err
+	}
+	assigneeId, err := parseMemberId(r.FormValue("assignee"))
 	if err != nil {
-		return err
+		return data.Task{}, err
+	}
+
+	var rotation []data.MemberId
+	for _, idStr := range r.Form["rotation_members"] {
+		id, err := parseMemberId(idStr)
+		if err != nil {
+			return data.Task{}, err
+		}
+		rotation = append(rotation, id)
+	}
+
+	period := 0
+	if periodStr := r.FormValue("period"); periodStr != "" {
+		period, err = strconv.Atoi(periodStr)
+		if err != nil {
+			return data.Task{}, fmt.Errorf("invalid period %q", periodStr)
+		}
+	}
+
+	return taskInput{
+		Name:         r.FormValue("name"),
+		Description:  r.FormValue("description"),
+		Period:       period,
+		Recurrence:   r.FormValue("recurrence"),
+		Schedule:     r.FormValue("schedule"),
+		Anchor:       r.FormValue("anchor"),
+		Due:          r.FormValue("due"),
+		GroupId:      groupId,
+		AssigneeId:   assigneeId,
+		RotationMode: r.FormValue("rotation"),
+		Rotation:     rotation,
+	}.task()
+}
+
+// task validates the input and returns the task it describes.
+// The invalid fields are reported together by a validationError.
+func (in taskInput) task() (data.Task, error) {
+	errs := validationError{}
+
+	name := strings.TrimSpace(in.Name)
+	if name == "" {
+		errs["name"] = "is required"
 	}
+	description := strings.TrimSpace(in.Description)
 
-	return c.Render(http.StatusOK, "groups", groups)
-}*/
+	// Members taking turns at the task, the first one takes it unless somebody is assigned
+	assigneeId := in.AssigneeId
+	rotationMode, err := data.ParseRotationMode(in.RotationMode)
+	if err != nil {
+		errs["rotation_mode"] = err.Error()
+	}
+	var rotation []data.MemberId
+	if rotationMode != data.NoRotation {
+		rotation = in.Rotation
+		if len(rotation) == 0 {
+			errs["rotation"] = "needs at least one member"
+		} else if assigneeId == data.NoMember {
+			assigneeId = rotation[0]
+		}
+	}
 
-/*func DeleteGroup(c echo.Context) error {
-	groupId, _ := strconv.Atoi(c.Param("id"))
-	if err := data.DeleteGroup(data.GroupId(groupId)); err != nil {
-		log.Logger.Errorf("Error deleting group: %v", err)
-		return err
+	// A due date makes a one-off task, which has no period nor recurrence
+	if in.Due != "" {
+		due, err := time.Parse("2006-01-02", in.Due)
+		if err != nil {
+			errs["due"] = fmt.Sprintf("invalid date %q, expected YYYY-MM-DD", in.Due)
+		}
+		if len(errs) > 0 {
+			return data.Task{}, errs
+		}
+		return data.Task{
+			Name:         name,
+			Description:  description,
+			Schedule:     data.Floating,
+			DueDate:      due,
+			GroupId:      in.GroupId,
+			AssigneeId:   assigneeId,
+			RotationMode: rotationMode,
+			Rotation:     rotation,
+		}, nil
 	}
 
-	groups, err := data.GetGroups()
+	schedule, err := data.ParseSchedule(in.Schedule)
 	if err != nil {
-		return err
+		errs["schedule"] = err.Error()
+	}
+
+	// Fixed tasks are due on a grid starting at the anchor, today if not given
+	var anchor time.Time
+	if schedule == data.Fixed {
+		anchor = data.Today()
+		if in.Anchor != "" {
+			anchor, err = time.Parse("2006-01-02", in.Anchor)
+			if err != nil {
+				errs["anchor"] = fmt.Sprintf("invalid date %q, expected YYYY-MM-DD", in.Anchor)
+			}
+		}
+	}
+
+	// A recurrence rule replaces the period, which is then optional
+	recurrence := strings.TrimSpace(in.Recurrence)
+	if recurrence != "" {
+		start := anchor
+		if start.IsZero() {
+			start = data.Today()
+		}
+		rule, err := recur.Parse(recurrence)
+		if err == nil {
+			err = rule.Validate(start)
+		}
+		if err != nil {
+			errs["recurrence"] = err.Error()
+		} else {
+			recurrence = rule.String()
+		}
+	}
+
+	if in.Period < 0 || (in.Period == 0 && recurrence == "") {
+		errs["period"] = "must be greater than zero"
 	}
 
-	return c.Render(http.StatusOK, "groups", groups)
-}*/
+	if len(errs) > 0 {
+		return data.Task{}, errs
+	}
+	return data.Task{
+		Name:         name,
+		Description:  description,
+		Period:       in.Period,
+		Recurrence:   recurrence,
+		Schedule:     schedule,
+		Anchor:       anchor,
+		GroupId:      in.GroupId,
+		AssigneeId:   assigneeId,
+		RotationMode: rotationMode,
+		Rotation:     rotation,
+	}, nil
+}
+
+// validationError maps the invalid fields of a request to what is wrong with them.
+type validationError map[string]string
 
-/*func PutTaskUnassign(c echo.Context) error {
-	taskId, _ := strconv.Atoi(c.Param("id"))
-	if err := data.UnassignTask(data.TaskId(taskId)); err != nil {
-		log.Logger.Errorf("Error unassigning task: %v", err)
-		return err
+func (e validationError) Error() string {
+	fields := make([]string, 0, len(e))
+	for field := range e {
+		fields = append(fields, field)
 	}
+	sort.Strings(fields)
 
-	groups, err := data.GetGroups()
+	msgs := make([]string, 0, len(fields))
+	for _, field := range fields {
+		msgs = append(msgs, field+": "+e[field])
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// pathGroupId parses the {id} path value of the request.
+func pathGroupId(r *http.Request) (data.GroupId, error) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		return err
+		return 0, fmt.Errorf("invalid group id %q", idStr)
 	}
+	return data.GroupId(id), nil
+}
 
-	return c.Render(http.StatusOK, "groups", groups)
-}*/
+// pathCompletionId parses the {completion} path value of the request.
+func pathCompletionId(r *http.Request) (data.CompletionId, error) {
+	idStr := r.PathValue("completion")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return 0, fmt.Errorf("invalid completion id %q", idStr)
+	}
+	return data.CompletionId(id), nil
+}
 
-/*func GetTaskGroupName(c echo.Context) error {
-	taskId, _ := strconv.Atoi(c.Param("id"))
-	group, err := data.GetTaskGroupName(data.TaskId(taskId))
+// parseFormTime parses a datetime-local or date input in the server time zone.
+// An empty value stands for now.
+func parseFormTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Now(), nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", s)
+}
+
+// parseSnoozeUntil parses the end of a snooze, given either as a date or as a number of days from today.
+func parseSnoozeUntil(until, days string) (time.Time, error) {
+	if until != "" {
+		t, err := time.Parse("2006-01-02", until)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid date %q", until)
+		}
+		return t, nil
+	}
+	n, err := strconv.Atoi(days)
+	if err != nil || n <= 0 {
+		return time.Time{}, fmt.Errorf("invalid number of days %q", days)
+	}
+	return data.Today().AddDate(0, 0, n), nil
+}
+
+// parseTaskFilter builds a tasks filter from the group, assignee, days and expired query parameters.
+// An empty group selects every group, "-1" the tasks without a group.
+// An empty assignee selects every task, "-1" the unassigned tasks.
+// Expired tasks are included unless expired is "false".
+func parseTaskFilter(group, assignee, days, expired string) (data.TaskFilter, error) {
+	groupId, err := parseGroupFilter(group)
 	if err != nil {
-		return err
+		return data.TaskFilter{}, err
+	}
+	assigneeId, err := parseMemberFilter(assignee)
+	if err != nil {
+		return data.TaskFilter{}, err
 	}
-	return c.String(http.StatusOK, group)
-}*/
 
-/*func GetModalInactive(c echo.Context) error {
-	return c.Render(http.StatusOK, "modal-inactive", nil)
-}*/
+	filter := data.TaskFilter{
+		Group:    groupId,
+		Assignee: assigneeId,
+		Expired:  expired != "false",
+	}
+	if days != "" {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return data.TaskFilter{}, fmt.Errorf("invalid days %q", days)
+		}
+		filter.Days = &n
+	}
+	return filter, nil
+}
+
+// parseGroupFilter parses the group of a tasks filter.
+// An empty value selects every group, "-1" the tasks without a group.
+func parseGroupFilter(idStr string) (data.GroupId, error) {
+	if idStr == "" {
+		return data.AnyGroup, nil
+	}
+	return parseGroupId(idStr)
+}
 
-/*func GetModalTaskInfo(c echo.Context) error {
-	id, _ := strconv.Atoi(c.QueryParam("id"))
-	task, err := data.GetTask(data.TaskId(id))
+// parseGroupId parses a group id submitted by a form.
+// An empty value or "-1" stand for no group.
+func parseGroupId(idStr string) (data.GroupId, error) {
+	if idStr == "" {
+		return data.NoGroup, nil
+	}
+	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		log.Logger.Fatalf("GetModalTaskInfo: %v", err)
+		return 0, fmt.Errorf("invalid group id %q", idStr)
+	}
+	return data.GroupId(id), nil
+}
+
+// dataErrorStatus maps an error returned by the data package to an HTTP status code.
+func dataErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, data.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, data.ErrConflict):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
 	}
-	return c.Render(http.StatusOK, "modal-task-info", map[string]any{
-		"Name": task.Name,
-		"Info": task.Description,
-	})
-}*/
+}
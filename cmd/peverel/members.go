@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/markor147/peverel/internal/data"
+	"github.com/markor147/peverel/internal/log"
+)
+
+// getMembers renders all the members either as a list or as a list of <option>.
+// With the options layout, the member ids in the "selected" query parameters are preselected.
+func (s *server) getMembers(t *template.Template) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		members, err := s.store.GetMembers(ctx)
+		if err != nil {
+			log.Logger.Errorf("get members: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if r.URL.Query().Get("layout") != "options" {
+			renderMembersList(w, t, members)
+			return
+		}
+
+		selected := make(map[data.MemberId]bool)
+		for _, idStr := range r.URL.Query()["selected"] {
+			id, err := parseMemberId(idStr)
+			if err != nil {
+				log.Logger.Errorf("parse selected member: %v", err)
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			selected[id] = true
+		}
+		if err := t.ExecuteTemplate(w, "members-options", map[string]any{
+			"Members":  members,
+			"Selected": selected,
+		}); err != nil {
+			log.Logger.Errorf("execute template %q: %v", "members-options", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// postMember creates a new member and renders the refreshed members list.
+func (s *server) postMember(t *template.Template) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		member, err := parseMemberForm(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if _, err := s.store.AddMember(ctx, member); err != nil {
+			log.Logger.Errorf("add member %q: %v", member.Name, err)
+			http.Error(w, err.Error(), dataErrorStatus(err))
+			return
+		}
+
+		s.refreshMembersList(ctx, w, t)
+	}
+}
+
+// putMember replaces name and email of a member and renders the refreshed members list.
+func (s *server) putMember(t *template.Template) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		id, err := pathMemberId(r)
+		if err != nil {
+			log.Logger.Errorf("parse member id: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		member, err := parseMemberForm(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		affected, err := s.affectedTasks(ctx, func(task data.Task) bool { return involves(task, id) })
+		if err != nil {
+			log.Logger.Errorf("get tasks of member with id %d: %v", id, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if err := s.store.UpdateMember(ctx, id, member); err != nil {
+			log.Logger.Errorf("update member with id %d: %v", id, err)
+			http.Error(w, err.Error(), dataErrorStatus(err))
+			return
+		}
+		s.publishUpdated(affected)
+
+		s.refreshMembersList(ctx, w, t)
+	}
+}
+
+// deleteMember removes a member and renders the refreshed members list.
+func (s *server) deleteMember(t *template.Template) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		id, err := pathMemberId(r)
+		if err != nil {
+			log.Logger.Errorf("parse member id: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		affected, err := s.affectedTasks(ctx, func(task data.Task) bool { return involves(task, id) })
+		if err != nil {
+			log.Logger.Errorf("get tasks of member with id %d: %v", id, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if err := s.store.DeleteMember(ctx, id); err != nil {
+			log.Logger.Errorf("delete member with id %d: %v", id, err)
+			http.Error(w, err.Error(), dataErrorStatus(err))
+			return
+		}
+		s.publishUpdated(affected)
+
+		s.refreshMembersList(ctx, w, t)
+	}
+}
+
+// involves reports whether the member is the assignee of the task or takes turns at it,
+// so that the task is shown differently once the member is renamed or deleted.
+func involves(task data.Task, id data.MemberId) bool {
+	return task.AssigneeId == id || slices.Contains(task.Rotation, id)
+}
+
+func (s *server) refreshMembersList(ctx context.Context, w http.ResponseWriter, t *template.Template) {
+	members, err := s.store.GetMembers(ctx)
+	if err != nil {
+		log.Logger.Errorf("get members: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	renderMembersList(w, t, members)
+}
+
+func renderMembersList(w http.ResponseWriter, t *template.Template, members []data.Member) {
+	if err := t.ExecuteTemplate(w, "members-list", members); err != nil {
+		log.Logger.Errorf("execute template %q: %v", "members-list", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// === Helpers ===
+
+// pathMemberId parses the {id} path value of the request.
+func pathMemberId(r *http.Request) (data.MemberId, error) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return 0, fmt.Errorf("invalid member id %q", idStr)
+	}
+	return data.MemberId(id), nil
+}
+
+// parseMemberForm reads and validates the fields of the member forms.
+func parseMemberForm(r *http.Request) (data.Member, error) {
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" {
+		return data.Member{}, errors.New("name is required")
+	}
+	email := strings.TrimSpace(r.FormValue("email"))
+	if email != "" && !strings.Contains(email, "@") {
+		return data.Member{}, fmt.Errorf("invalid email %q", email)
+	}
+	return data.Member{Name: name, Email: email}, nil
+}
+
+// parseMemberFilter parses the assignee of a tasks filter.
+// An empty value selects every task, "-1" the unassigned tasks.
+func parseMemberFilter(idStr string) (data.MemberId, error) {
+	if idStr == "" {
+		return data.AnyMember, nil
+	}
+	return parseMemberId(idStr)
+}
+
+// parseMemberId parses a member id submitted by a form.
+// An empty value or "-1" stand for nobody.
+func parseMemberId(idStr string) (data.MemberId, error) {
+	if idStr == "" {
+		return data.NoMember, nil
+	}
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return 0, fmt.Errorf("invalid member id %q", idStr)
+	}
+	return data.MemberId(id), nil
+}
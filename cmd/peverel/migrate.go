@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/markor147/peverel/internal/config"
+	data "github.com/markor147/peverel/internal/data"
+)
+
+const migrateUsage = "usage: peverel migrate status|up"
+
+// runMigrate implements the `peverel migrate status|up` command.
+func runMigrate(ctx context.Context, db config.Database, args []string) error {
+	if len(args) != 1 {
+		return errors.New(migrateUsage)
+	}
+
+	store, err := data.OpenSQLiteStore(ctx, db.Path, data.WithQueryTimeout(db.QueryTimeout))
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	switch args[0] {
+	case "status":
+		statuses, err := store.MigrationsStatus(ctx)
+		if err != nil {
+			return err
+		}
+		return printMigrationsStatus(os.Stdout, statuses)
+	case "up":
+		applied, err := store.Migrate(ctx)
+		for _, m := range applied {
+			fmt.Printf("applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("schema is up to date")
+		}
+		return nil
+	default:
+		return errors.New(migrateUsage)
+	}
+}
+
+func printMigrationsStatus(w io.Writer, statuses []data.MigrationStatus) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED AT")
+	for _, s := range statuses {
+		appliedAt := "pending"
+		if s.Applied() {
+			appliedAt = s.AppliedAt.Local().Format(time.DateTime)
+		}
+		fmt.Fprintf(tw, "%04d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+	}
+	return tw.Flush()
+}
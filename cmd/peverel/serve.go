@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/markor147/peverel/internal/config"
+	data "github.com/markor147/peverel/internal/data"
+	"github.com/markor147/peverel/internal/log"
+)
+
+// runServe implements the `peverel serve` command, the default one:
+// it serves the web UI and the JSON API until SIGINT or SIGTERM.
+func runServe(cfg config.Config) error {
+	store, err := openStore(context.Background(), cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	s := &server{store: store, events: newEventHub()}
+
+	// Scheduled backups, stopped with the server
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	scheduleBackups(jobsCtx, store, cfg.Backup)
+
+	handler, err := s.handler()
+	if err != nil {
+		return err
+	}
+
+	// Init server
+	port := strconv.Itoa(cfg.Server.Port)
+	srv := &http.Server{
+		Addr:    ":" + port,
+		Handler: handler,
+	}
+	srv.RegisterOnShutdown(s.events.close)
+
+	// Run server
+	go func() {
+		log.Logger.Infof("listening on :%s", port)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Logger.Fatalf("listen: %v\n", err)
+		}
+	}()
+
+	// Trap SIGINT and SIGTERM
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	<-stop
+
+	log.Logger.Info("shutting down server...")
+	stopJobs()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		return fmt.Errorf("server shutdown failed: %w", err)
+	}
+
+	log.Logger.Info("server exited")
+	return nil
+}
+
+// handler returns the handler of the web UI and of the JSON API, behind the login and CSRF checks.
+func (s *server) handler() (http.Handler, error) {
+	// Mux initialisation
+	mux := http.NewServeMux()
+
+	// Base layout
+	baseTmpl := template.Must(template.ParseFS(assetsFS, "assets/tmpl/base.html"))
+
+	// Static assets
+	fsys, err := fs.Sub(assetsFS, "assets/static")
+	if err != nil {
+		return nil, err
+	}
+	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.FS(fsys))))
+
+	// Register login and logout
+	{
+		t := template.Must(mustClone(baseTmpl).ParseFS(assetsFS, "assets/tmpl/login.html"))
+		mux.HandleFunc("GET /login", getLogin(t))
+		mux.HandleFunc("POST /login", s.postLogin(t))
+		mux.HandleFunc("POST /logout", s.postLogout)
+	}
+
+	// Register simple pages
+	for r, f := range map[string]string{
+		"GET /settings":  "settings.html",
+		"GET /tasks/new": "new-task.html",
+	} {
+		route := r
+		file := f
+		t := template.Must(mustClone(baseTmpl).ParseFS(assetsFS, "assets/tmpl/"+file))
+		mux.HandleFunc(route, func(w http.ResponseWriter, r *http.Request) {
+			if err := t.ExecuteTemplate(w, "base", newPage(r, nil)); err != nil {
+				log.Logger.Errorf("execute template %q: %v", file, err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+		})
+	}
+
+	// Fragments rendered by htmx requests
+	fragmentsTmpl := template.Must(template.ParseFS(assetsFS,
+		"assets/tmpl/tasks-table.html",
+		"assets/tmpl/tasks-options.html",
+		"assets/tmpl/groups.html",
+		"assets/tmpl/members.html",
+		"assets/tmpl/api-tokens.html",
+		"assets/tmpl/transfer.html",
+		"assets/tmpl/completions.html",
+	))
+
+	// Register home page
+	{
+		const file = "home.html"
+		t := template.Must(mustClone(baseTmpl).ParseFS(assetsFS, "assets/tmpl/"+file, "assets/tmpl/tasks-table.html"))
+		mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+			tasks, err := s.store.Tasks(r.Context(), data.TaskFilter{})
+			if err != nil {
+				log.Logger.Errorf("get tasks: %v", err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			if err := t.ExecuteTemplate(w, "base", newPage(r, tasks)); err != nil {
+				log.Logger.Errorf("execute template %q: %v", file, err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+		})
+	}
+
+	// Register edit task
+	{
+		const file = "edit-task.html"
+		t := template.Must(mustClone(baseTmpl).ParseFS(assetsFS, "assets/tmpl/"+file))
+		mux.HandleFunc("GET /tasks/{id}/edit", func(w http.ResponseWriter, r *http.Request) {
+			idStr := r.PathValue("id")
+			id, err := strconv.Atoi(idStr)
+			if err != nil {
+				log.Logger.Errorf("parse id %q: %v", idStr, err)
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			task, err := s.store.GetTask(r.Context(), data.TaskId(id))
+			if err != nil {
+				log.Logger.Errorf("get task with id %d: %v", id, err)
+				http.Error(w, err.Error(), dataErrorStatus(err))
+				return
+			}
+
+			if err := t.ExecuteTemplate(w, "base", newPage(r, task)); err != nil {
+				log.Logger.Errorf("execute template %q: %v", file, err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+		})
+	}
+
+	// Register task history
+	{
+		const file = "history.html"
+		t := template.Must(mustClone(baseTmpl).ParseFS(assetsFS, "assets/tmpl/"+file, "assets/tmpl/completions.html"))
+		mux.HandleFunc("GET /tasks/{id}/history", func(w http.ResponseWriter, r *http.Request) {
+			id, err := pathTaskId(r)
+			if err != nil {
+				log.Logger.Errorf("parse task id: %v", err)
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			task, err := s.store.GetTask(r.Context(), id)
+			if err != nil {
+				log.Logger.Errorf("get task with id %d: %v", id, err)
+				http.Error(w, err.Error(), dataErrorStatus(err))
+				return
+			}
+
+			completions, err := s.store.Completions(r.Context(), id)
+			if err != nil {
+				log.Logger.Errorf("get completions of task with id %d: %v", id, err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			if err := t.ExecuteTemplate(w, "base", newPage(r, map[string]any{
+				"Task":        task,
+				"TaskId":      task.Id,
+				"Completions": completions,
+			})); err != nil {
+				log.Logger.Errorf("execute template %q: %v", file, err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+		})
+	}
+
+	// Register tasks fragments and task mutations
+	mux.HandleFunc("GET /tasks", s.getTasks(fragmentsTmpl))
+	mux.HandleFunc("POST /task", s.postTask)
+	mux.HandleFunc("PUT /task/{id}", s.putTask)
+	mux.HandleFunc("DELETE /task/{id}", s.deleteTask)
+	mux.HandleFunc("PUT /task/{id}/complete", s.putTaskComplete(fragmentsTmpl))
+	mux.HandleFunc("PUT /task/{id}/skip", s.putTaskSkip(fragmentsTmpl))
+	mux.HandleFunc("PUT /task/{id}/snooze", s.putTaskSnooze(fragmentsTmpl))
+	mux.HandleFunc("DELETE /task/{id}/snooze", s.deleteTaskSnooze(fragmentsTmpl))
+	mux.HandleFunc("GET /task/{id}/next-time", s.getTaskNextTime)
+	mux.HandleFunc("POST /task/{id}/completions", s.postTaskCompletion(fragmentsTmpl))
+	mux.HandleFunc("PUT /task/{id}/completions/{completion}", s.putTaskCompletion(fragmentsTmpl))
+	mux.HandleFunc("DELETE /task/{id}/completions/{completion}", s.deleteTaskCompletion(fragmentsTmpl))
+
+	// Register groups fragments and group mutations
+	mux.HandleFunc("GET /groups", s.getGroups(fragmentsTmpl))
+	mux.HandleFunc("POST /group", s.postGroup(fragmentsTmpl))
+	mux.HandleFunc("PUT /group/{id}", s.putGroup(fragmentsTmpl))
+	mux.HandleFunc("DELETE /group/{id}", s.deleteGroup(fragmentsTmpl))
+
+	// Register members fragments and member mutations
+	mux.HandleFunc("GET /members", s.getMembers(fragmentsTmpl))
+	mux.HandleFunc("POST /member", s.postMember(fragmentsTmpl))
+	mux.HandleFunc("PUT /member/{id}", s.putMember(fragmentsTmpl))
+	mux.HandleFunc("DELETE /member/{id}", s.deleteMember(fragmentsTmpl))
+
+	// Register API tokens fragments and mutations
+	mux.HandleFunc("GET /api-tokens", s.getAPITokens(fragmentsTmpl))
+	mux.HandleFunc("POST /api-token", s.postAPIToken(fragmentsTmpl))
+	mux.HandleFunc("DELETE /api-token/{id}", s.deleteAPIToken(fragmentsTmpl))
+
+	// Register task events stream
+	mux.HandleFunc("GET /events", s.getEvents)
+
+	// Register import and export
+	mux.HandleFunc("GET /export", s.getExport)
+	mux.HandleFunc("POST /import", s.postImport(fragmentsTmpl))
+
+	// Register calendar feed
+	mux.HandleFunc("GET "+calendarPath, s.getCalendar)
+
+	// Register JSON API
+	s.registerAPI(mux)
+
+	return s.requireLogin(requireCSRF(mux)), nil
+}
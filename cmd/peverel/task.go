@@ -0,0 +1,336 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/markor147/peverel/internal/config"
+	data "github.com/markor147/peverel/internal/data"
+)
+
+const taskUsage = `usage: peverel task list [-group g] [-assignee m] [-days n] [-expired=false] [-done] [-format table|json]
+       peverel task add [task flags] [-format table|json] <name>
+       peverel task edit [-name name] [task flags] [-format table|json] <task>
+       peverel task done [-by m] [-format table|json] <task>
+       peverel task rm <task>
+tasks, groups and members are given by id or by name.
+task flags: -description, -period, -recurrence, -schedule, -anchor, -due, -group, -assignee, -rotation-mode, -rotation`
+
+// runTask implements the `peverel task` commands.
+func runTask(ctx context.Context, db config.Database, args []string) error {
+	if len(args) == 0 {
+		return errors.New(taskUsage)
+	}
+
+	store, err := openStore(ctx, db)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	switch args[0] {
+	case "list":
+		return runTaskList(ctx, store, args[1:])
+	case "add":
+		return runTaskAdd(ctx, store, args[1:])
+	case "edit":
+		return runTaskEdit(ctx, store, args[1:])
+	case "done":
+		return runTaskDone(ctx, store, args[1:])
+	case "rm":
+		return runTaskRm(ctx, store, args[1:])
+	default:
+		return errors.New(taskUsage)
+	}
+}
+
+func runTaskList(ctx context.Context, store data.Store, args []string) error {
+	fs := flag.NewFlagSet("task list", flag.ContinueOnError)
+	group := fs.String("group", "", "only the tasks of the group, none for the tasks without group")
+	assignee := fs.String("assignee", "", "only the tasks assigned to the member, none for the unassigned tasks")
+	days := fs.Int("days", -1, "only the tasks due within this number of days")
+	expired := fs.Bool("expired", true, "with -days, include the overdue tasks")
+	done := fs.Bool("done", false, "include the completed one-off tasks")
+	format := fs.String("format", formatTable, "output format, table or json")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 {
+		return errors.New(taskUsage)
+	}
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+
+	filter := data.TaskFilter{Group: data.AnyGroup, Assignee: data.AnyMember, Expired: *expired, Done: *done}
+	var err error
+	if *group != "" {
+		if filter.Group, err = resolveGroup(ctx, store, *group); err != nil {
+			return err
+		}
+	}
+	if *assignee != "" {
+		if filter.Assignee, err = resolveMember(ctx, store, *assignee); err != nil {
+			return err
+		}
+	}
+	if *days >= 0 {
+		filter.Days = days
+	}
+
+	tasks, err := store.Tasks(ctx, filter)
+	if err != nil {
+		return err
+	}
+	return printTasks(os.Stdout, *format, tasks...)
+}
+
+func runTaskAdd(ctx context.Context, store data.Store, args []string) error {
+	fs := flag.NewFlagSet("task add", flag.ContinueOnError)
+	tf := newTaskFlags(fs)
+	format := fs.String("format", formatTable, "output format, table or json")
+	positional, err := parseFlags(fs, args)
+	if err != nil || len(positional) != 1 {
+		return errors.New(taskUsage)
+	}
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+
+	in := taskInput{Name: positional[0], GroupId: data.NoGroup, AssigneeId: data.NoMember}
+	if err := tf.apply(ctx, store, fs, &in); err != nil {
+		return err
+	}
+	task, err := in.task()
+	if err != nil {
+		return err
+	}
+	task.LastCompleted = time.Now()
+
+	id, err := store.AddTask(ctx, task)
+	if err != nil {
+		return err
+	}
+	return printTask(ctx, store, *format, id)
+}
+
+func runTaskEdit(ctx context.Context, store data.Store, args []string) error {
+	fs := flag.NewFlagSet("task edit", flag.ContinueOnError)
+	name := fs.String("name", "", "new name of the task")
+	tf := newTaskFlags(fs)
+	format := fs.String("format", formatTable, "output format, table or json")
+	positional, err := parseFlags(fs, args)
+	if err != nil || len(positional) != 1 {
+		return errors.New(taskUsage)
+	}
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+
+	current, err := resolveTask(ctx, store, positional[0])
+	if err != nil {
+		return err
+	}
+
+	// The flags left unset keep the current fields
+	in := newTaskInput(current)
+	if *name != "" {
+		in.Name = *name
+	}
+	if err := tf.apply(ctx, store, fs, &in); err != nil {
+		return err
+	}
+	task, err := in.task()
+	if err != nil {
+		return err
+	}
+
+	if err := store.UpdateTask(ctx, current.Id, task); err != nil {
+		return err
+	}
+	return printTask(ctx, store, *format, current.Id)
+}
+
+func runTaskDone(ctx context.Context, store data.Store, args []string) error {
+	fs := flag.NewFlagSet("task done", flag.ContinueOnError)
+	by := fs.String("by", "", "member credited with the completion")
+	format := fs.String("format", formatTable, "output format, table or json")
+	positional, err := parseFlags(fs, args)
+	if err != nil || len(positional) != 1 {
+		return errors.New(taskUsage)
+	}
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+
+	task, err := resolveTask(ctx, store, positional[0])
+	if err != nil {
+		return err
+	}
+	member, err := resolveMember(ctx, store, *by)
+	if err != nil {
+		return err
+	}
+
+	if err := store.CompleteTask(ctx, task.Id, member); err != nil {
+		return err
+	}
+	return printTask(ctx, store, *format, task.Id)
+}
+
+func runTaskRm(ctx context.Context, store data.Store, args []string) error {
+	if len(args) != 1 {
+		return errors.New(taskUsage)
+	}
+
+	task, err := resolveTask(ctx, store, args[0])
+	if err != nil {
+		return err
+	}
+	if err := store.DeleteTask(ctx, task.Id); err != nil {
+		return err
+	}
+	fmt.Printf("task %d %q removed\n", task.Id, task.Name)
+	return nil
+}
+
+// taskFlags holds the flags setting the fields of a task.
+type taskFlags struct {
+	description  string
+	period       int
+	recurrence   string
+	schedule     string
+	anchor       string
+	due          string
+	group        string
+	assignee     string
+	rotationMode string
+	rotation     string
+}
+
+func newTaskFlags(fs *flag.FlagSet) *taskFlags {
+	f := &taskFlags{}
+	fs.StringVar(&f.description, "description", "", "description of the task")
+	fs.IntVar(&f.period, "period", 0, "days between occurrences, when there is no recurrence")
+	fs.StringVar(&f.recurrence, "recurrence", "", "recurrence rule replacing the period, e.g. FREQ=MONTHLY;BYDAY=1SA")
+	fs.StringVar(&f.schedule, "schedule", "", "floating or fixed")
+	fs.StringVar(&f.anchor, "anchor", "", "first due day of fixed tasks, as YYYY-MM-DD")
+	fs.StringVar(&f.due, "due", "", `due day of a one-off task, as YYYY-MM-DD; "" makes it repeat again`)
+	fs.StringVar(&f.group, "group", "", "group of the task, none for no group")
+	fs.StringVar(&f.assignee, "assignee", "", "member assigned to the task, none for nobody")
+	fs.StringVar(&f.rotationMode, "rotation-mode", "", "none, round-robin or least-recent")
+	fs.StringVar(&f.rotation, "rotation", "", "comma separated members taking turns at the task")
+	return f
+}
+
+// apply sets the fields of the input given on the command line, resolving the groups and members.
+func (f *taskFlags) apply(ctx context.Context, store data.Store, fs *flag.FlagSet, in *taskInput) error {
+	var errs []error
+	fs.Visit(func(fl *flag.Flag) {
+		var err error
+		switch fl.Name {
+		case "description":
+			in.Description = f.description
+		case "period":
+			in.Period = f.period
+		case "recurrence":
+			in.Recurrence = f.recurrence
+		case "schedule":
+			in.Schedule = f.schedule
+		case "anchor":
+			in.Anchor = f.anchor
+		case "due":
+			in.Due = f.due
+		case "group":
+			in.GroupId, err = resolveGroup(ctx, store, f.group)
+		case "assignee":
+			in.AssigneeId, err = resolveMember(ctx, store, f.assignee)
+		case "rotation-mode":
+			in.RotationMode = f.rotationMode
+		case "rotation":
+			in.Rotation = nil
+			for _, ref := range strings.Split(f.rotation, ",") {
+				if ref = strings.TrimSpace(ref); ref == "" {
+					continue
+				}
+				id, rerr := resolveMember(ctx, store, ref)
+				if rerr != nil {
+					err = rerr
+					break
+				}
+				in.Rotation = append(in.Rotation, id)
+			}
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	})
+	return errors.Join(errs...)
+}
+
+// newTaskInput returns the input describing the task as it is.
+func newTaskInput(t data.Task) taskInput {
+	return taskInput{
+		Name:         t.Name,
+		Description:  t.Description,
+		Period:       t.Period,
+		Recurrence:   t.Recurrence,
+		Schedule:     string(t.Schedule),
+		Anchor:       apiDate(t.Anchor),
+		Due:          apiDate(t.DueDate),
+		GroupId:      t.GroupId,
+		AssigneeId:   t.AssigneeId,
+		RotationMode: string(t.RotationMode),
+		Rotation:     t.Rotation,
+	}
+}
+
+// printTask prints the task specified by the id, as stored.
+func printTask(ctx context.Context, store data.Store, format string, id data.TaskId) error {
+	task, err := store.GetTask(ctx, id)
+	if err != nil {
+		return err
+	}
+	if format == formatJSON {
+		return printJSON(os.Stdout, newAPITask(task))
+	}
+	return printTasks(os.Stdout, format, task)
+}
+
+// printTasks prints the tasks as a table, or as a JSON array in the format of the API.
+func printTasks(w io.Writer, format string, tasks ...data.Task) error {
+	if format == formatJSON {
+		res := make([]apiTask, 0, len(tasks))
+		for _, t := range tasks {
+			res = append(res, newAPITask(t))
+		}
+		return printJSON(w, res)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tNEXT DUE\tWHEN\tREPEATS\tGROUP\tASSIGNEE")
+	for _, t := range tasks {
+		next := apiDate(t.NextDue())
+		if next == "" {
+			next = "-"
+		}
+		when := renderTaskNextTime(t)
+		if t.Done() {
+			when = "done"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", t.Id, t.Name, next, when, t.Frequency(), orDash(t.GroupName), orDash(t.AssigneeName))
+	}
+	return tw.Flush()
+}
+
+// orDash returns s, or "-" if it is empty, to keep the table columns aligned.
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/markor147/peverel/internal/data"
+	"github.com/markor147/peverel/internal/log"
+)
+
+// apiTokenPrefix makes the API tokens easy to recognise, e.g. in leaked files.
+const apiTokenPrefix = "pvl_"
+
+// getAPITokens renders the API tokens of the signed in user.
+func (s *server) getAPITokens(t *template.Template) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.refreshAPITokensList(w, r, t, "")
+	}
+}
+
+// postAPIToken creates an API token for the signed in user and renders the refreshed list,
+// showing the new token once.
+func (s *server) postAPIToken(t *template.Template) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		user, _ := userFromContext(ctx)
+
+		name := strings.TrimSpace(r.FormValue("name"))
+		if name == "" {
+			http.Error(w, "name is required", http.StatusBadRequest)
+			return
+		}
+		scope, err := data.ParseTokenScope(r.FormValue("scope"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		token, err := newToken()
+		if err != nil {
+			log.Logger.Errorf("generate API token: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		token = apiTokenPrefix + token
+
+		id, err := s.store.AddAPIToken(ctx, data.APIToken{
+			UserId:    user.Id,
+			Name:      name,
+			TokenHash: hashToken(token),
+			Scope:     scope,
+			CreatedAt: time.Now(),
+		})
+		if err != nil {
+			log.Logger.Errorf("add API token %q: %v", name, err)
+			http.Error(w, err.Error(), dataErrorStatus(err))
+			return
+		}
+
+		log.Logger.Infof("API token %d created by user %q", id, user.Username)
+		s.refreshAPITokensList(w, r, t, token)
+	}
+}
+
+// deleteAPIToken revokes an API token of the signed in user and renders the refreshed list.
+func (s *server) deleteAPIToken(t *template.Template) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		user, _ := userFromContext(ctx)
+
+		idStr := r.PathValue("id")
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			log.Logger.Errorf("parse API token id %q: %v", idStr, err)
+			http.Error(w, fmt.Sprintf("invalid API token id %q", idStr), http.StatusBadRequest)
+			return
+		}
+
+		if err := s.store.DeleteAPIToken(ctx, user.Id, data.APITokenId(id)); err != nil {
+			log.Logger.Errorf("delete API token with id %d: %v", id, err)
+			http.Error(w, err.Error(), dataErrorStatus(err))
+			return
+		}
+
+		log.Logger.Infof("API token %d revoked by user %q", id, user.Username)
+		s.refreshAPITokensList(w, r, t, "")
+	}
+}
+
+// refreshAPITokensList renders the API tokens of the signed in user.
+// newToken is the token just created, shown once, or "".
+func (s *server) refreshAPITokensList(w http.ResponseWriter, r *http.Request, t *template.Template, newToken string) {
+	user, _ := userFromContext(r.Context())
+	tokens, err := s.store.GetAPITokens(r.Context(), user.Id)
+	if err != nil {
+		log.Logger.Errorf("get API tokens: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := t.ExecuteTemplate(w, "api-tokens-list", map[string]any{
+		"Tokens":   tokens,
+		"NewToken": newToken,
+	}); err != nil {
+		log.Logger.Errorf("execute template %q: %v", "api-tokens-list", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// authenticateToken serves a request of the JSON API or of the calendar feed authenticated by an API token,
+// if the token exists and its scope allows the request.
+// Tokens passed in the URL, fromQuery, must have the calendar scope.
+func (s *server) authenticateToken(w http.ResponseWriter, r *http.Request, next http.Handler, bearer string, fromQuery bool) {
+	if !isAPIRequest(r) && r.URL.Path != calendarPath {
+		http.Error(w, "API tokens are only accepted by the JSON API and the calendar feed", http.StatusUnauthorized)
+		return
+	}
+
+	ctx := r.Context()
+	token, err := s.store.GetAPIToken(ctx, hashToken(bearer))
+	if errors.Is(err, data.ErrNotFound) {
+		log.Logger.Warnf("%s %s: invalid API token", r.Method, r.URL.Path)
+		writeTokenError(w, r, http.StatusUnauthorized, "invalid API token")
+		return
+	}
+	if err != nil {
+		log.Logger.Errorf("get API token: %v", err)
+		writeTokenError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if fromQuery && token.Scope != data.ScopeCalendar {
+		log.Logger.Warnf("%s %s: API token %d with the %q scope passed in the URL", r.Method, r.URL.Path, token.Id, token.Scope)
+		writeTokenError(w, r, http.StatusForbidden, fmt.Sprintf("only tokens with the %q scope are accepted in the URL", data.ScopeCalendar))
+		return
+	}
+	if !scopeAllows(token.Scope, r) {
+		writeTokenError(w, r, http.StatusForbidden, fmt.Sprintf("the %q scope does not allow %s %s", token.Scope, r.Method, r.URL.Path))
+		return
+	}
+
+	user, err := s.store.GetUser(ctx, token.UserId)
+	if err != nil {
+		log.Logger.Errorf("get user of API token %d: %v", token.Id, err)
+		writeTokenError(w, r, dataErrorStatus(err), err.Error())
+		return
+	}
+	if err := s.store.TouchAPIToken(ctx, token.Id, time.Now()); err != nil {
+		log.Logger.Warnf("touch API token %d: %v", token.Id, err)
+	}
+
+	ctx = context.WithValue(ctx, userContextKey, user)
+	ctx = context.WithValue(ctx, apiTokenContextKey, token)
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// === Helpers ===
+
+// writeTokenError writes the error of a request authenticated by an API token,
+// as JSON for the API and as plain text for the calendar feed.
+func writeTokenError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	if isAPIRequest(r) {
+		writeAPIError(w, status, message)
+		return
+	}
+	http.Error(w, message, status)
+}
+
+// bearerToken returns the API token of the Authorization: Bearer header of the request,
+// or of the token query parameter for the calendar feed, in which case fromQuery is true.
+func bearerToken(r *http.Request) (token string, fromQuery bool, ok bool) {
+	if r.URL.Path == calendarPath {
+		if token := r.URL.Query().Get("token"); token != "" {
+			return token, true, true
+		}
+	}
+
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false, false
+	}
+	token = strings.TrimSpace(token)
+	return token, false, token != ""
+}
+
+// scopeAllows reports whether an API token with the scope may serve the request.
+// The calendar scope only reads the calendar feed, every other scope reads everything.
+// The complete scope also completes, skips and snoozes tasks,
+// the day-to-day actions of the tasks table, and no scope changes them otherwise.
+func scopeAllows(scope data.TokenScope, r *http.Request) bool {
+	read := r.Method == http.MethodGet || r.Method == http.MethodHead
+	if scope == data.ScopeCalendar {
+		return read && r.URL.Path == calendarPath
+	}
+	if read {
+		return true
+	}
+	if scope != data.ScopeComplete {
+		return false
+	}
+	switch taskAction(r.URL.Path) {
+	case "complete", "skip":
+		return r.Method == http.MethodPost
+	case "snooze":
+		return r.Method == http.MethodPost || r.Method == http.MethodDelete
+	default:
+		return false
+	}
+}
+
+// taskAction returns the action of the API route acting on a task, such as "complete", or "".
+func taskAction(path string) string {
+	rest, ok := strings.CutPrefix(path, "/api/v1/tasks/")
+	if !ok {
+		return ""
+	}
+	id, action, ok := strings.Cut(rest, "/")
+	if !ok || id == "" {
+		return ""
+	}
+	return action
+}
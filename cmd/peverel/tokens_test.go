@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/markor147/peverel/internal/data"
+)
+
+// addAPIToken adds an API token of the test user with the scope and returns it.
+func (ts *testServer) addAPIToken(t *testing.T, scope data.TokenScope) string {
+	t.Helper()
+	token := fmt.Sprintf("%stest-%s", apiTokenPrefix, scope)
+	if _, err := ts.store.AddAPIToken(context.Background(), data.APIToken{
+		UserId:    ts.userId,
+		Name:      string(scope),
+		TokenHash: hashToken(token),
+		Scope:     scope,
+		CreatedAt: time.Now(),
+	}); err != nil {
+		t.Fatalf("AddAPIToken: %v", err)
+	}
+	return token
+}
+
+func bearer(token string) http.Header {
+	return http.Header{"Authorization": {"Bearer " + token}}
+}
+
+func TestTokenScopes(t *testing.T) {
+	ts := newTestServer(t)
+	client := ts.newClient(t)
+	tokens := map[data.TokenScope]string{
+		data.ScopeRead:     ts.addAPIToken(t, data.ScopeRead),
+		data.ScopeComplete: ts.addAPIToken(t, data.ScopeComplete),
+		data.ScopeCalendar: ts.addAPIToken(t, data.ScopeCalendar),
+	}
+	id := ts.addTask(t, "Dishes")
+	task := fmt.Sprintf("/api/v1/tasks/%d", id)
+	snooze := `{"days": 2}`
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		want   map[data.TokenScope]int
+	}{
+		{http.MethodGet, "/api/v1/tasks", "", map[data.TokenScope]int{
+			data.ScopeRead: http.StatusOK, data.ScopeComplete: http.StatusOK, data.ScopeCalendar: http.StatusForbidden,
+		}},
+		{http.MethodGet, task + "/history", "", map[data.TokenScope]int{
+			data.ScopeRead: http.StatusOK, data.ScopeComplete: http.StatusOK, data.ScopeCalendar: http.StatusForbidden,
+		}},
+		{http.MethodPost, task + "/complete", "", map[data.TokenScope]int{
+			data.ScopeRead: http.StatusForbidden, data.ScopeComplete: http.StatusOK, data.ScopeCalendar: http.StatusForbidden,
+		}},
+		{http.MethodPost, task + "/skip", "", map[data.TokenScope]int{
+			data.ScopeRead: http.StatusForbidden, data.ScopeComplete: http.StatusOK, data.ScopeCalendar: http.StatusForbidden,
+		}},
+		{http.MethodPost, task + "/snooze", snooze, map[data.TokenScope]int{
+			data.ScopeRead: http.StatusForbidden, data.ScopeComplete: http.StatusOK, data.ScopeCalendar: http.StatusForbidden,
+		}},
+		{http.MethodDelete, task + "/snooze", "", map[data.TokenScope]int{
+			data.ScopeRead: http.StatusForbidden, data.ScopeComplete: http.StatusOK, data.ScopeCalendar: http.StatusForbidden,
+		}},
+		{http.MethodPost, "/api/v1/tasks", `{"name": "Bins", "period": 7}`, map[data.TokenScope]int{
+			data.ScopeRead: http.StatusForbidden, data.ScopeComplete: http.StatusForbidden, data.ScopeCalendar: http.StatusForbidden,
+		}},
+		{http.MethodPut, task, `{"name": "Dishes", "period": 2}`, map[data.TokenScope]int{
+			data.ScopeRead: http.StatusForbidden, data.ScopeComplete: http.StatusForbidden, data.ScopeCalendar: http.StatusForbidden,
+		}},
+		{http.MethodDelete, task, "", map[data.TokenScope]int{
+			data.ScopeRead: http.StatusForbidden, data.ScopeComplete: http.StatusForbidden, data.ScopeCalendar: http.StatusForbidden,
+		}},
+		{http.MethodGet, calendarPath, "", map[data.TokenScope]int{
+			data.ScopeRead: http.StatusOK, data.ScopeComplete: http.StatusOK, data.ScopeCalendar: http.StatusOK,
+		}},
+	}
+	for _, tt := range tests {
+		for _, scope := range []data.TokenScope{data.ScopeRead, data.ScopeComplete, data.ScopeCalendar} {
+			resp, body := ts.do(t, client, tt.method, tt.path, strings.NewReader(tt.body), bearer(tokens[scope]))
+			if resp.StatusCode != tt.want[scope] {
+				t.Errorf("%s %s with the %s scope: status %d %s, want %d", tt.method, tt.path, scope, resp.StatusCode, body, tt.want[scope])
+			}
+		}
+	}
+
+	// The task is still there after all the refused changes
+	if _, err := ts.store.GetTask(context.Background(), id); err != nil {
+		t.Errorf("GetTask: %v", err)
+	}
+}
+
+func TestTokenRejected(t *testing.T) {
+	ts := newTestServer(t)
+	client := ts.newClient(t)
+	token := ts.addAPIToken(t, data.ScopeComplete)
+
+	resp, body := ts.do(t, client, http.MethodGet, "/api/v1/tasks", nil, bearer(apiTokenPrefix+"unknown"))
+	if resp.StatusCode != http.StatusUnauthorized || !strings.Contains(body, `"unauthorized"`) {
+		t.Errorf("unknown token: %d %s, want a JSON 401", resp.StatusCode, body)
+	}
+
+	// The tokens do not open the web UI
+	for _, path := range []string{"/", "/settings", "/tasks"} {
+		if resp, _ := ts.do(t, client, http.MethodGet, path, nil, bearer(token)); resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("GET %s with a token: status %d, want 401", path, resp.StatusCode)
+		}
+	}
+
+	// A revoked token is refused
+	tokens, err := ts.store.GetAPITokens(context.Background(), ts.userId)
+	if err != nil || len(tokens) != 1 {
+		t.Fatalf("GetAPITokens = %v, %v, want one token", tokens, err)
+	}
+	if err := ts.store.DeleteAPIToken(context.Background(), ts.userId, tokens[0].Id); err != nil {
+		t.Fatalf("DeleteAPIToken: %v", err)
+	}
+	if resp, _ := ts.do(t, client, http.MethodGet, "/api/v1/tasks", nil, bearer(token)); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("revoked token: status %d, want 401", resp.StatusCode)
+	}
+}
+
+func TestTokenSkipsCSRF(t *testing.T) {
+	ts := newTestServer(t)
+	client := ts.newClient(t)
+	token := ts.addAPIToken(t, data.ScopeComplete)
+	id := ts.addTask(t, "Dishes")
+
+	path := fmt.Sprintf("/api/v1/tasks/%d/complete", id)
+	if resp, body := ts.do(t, client, http.MethodPost, path, nil, bearer(token)); resp.StatusCode != http.StatusOK {
+		t.Errorf("POST %s with a token and no CSRF token: %d %s, want 200", path, resp.StatusCode, body)
+	}
+	tokens, err := ts.store.GetAPITokens(context.Background(), ts.userId)
+	if err != nil || len(tokens) != 1 || tokens[0].LastUsedAt.IsZero() {
+		t.Errorf("GetAPITokens = %+v, %v, want the token used", tokens, err)
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/markor147/peverel/internal/config"
+	"github.com/markor147/peverel/internal/log"
+	"github.com/markor147/peverel/internal/transfer"
+)
+
+const (
+	exportUsage = "usage: peverel export [-format json|csv] [-o file]"
+	importUsage = "usage: peverel import [-format json|csv] [-replace] [-dry-run] <file|->"
+)
+
+// maxImportSize bounds the size of the files uploaded from the settings page.
+const maxImportSize = 10 << 20
+
+// runExport implements the `peverel export` command, writing every task
+// with its completion history to a file or to the standard output.
+func runExport(ctx context.Context, db config.Database, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	formatName := fs.String("format", "", "json or csv, from the extension of the output file by default, else json")
+	output := fs.String("o", "", "output file, the standard output by default")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 {
+		return errors.New(exportUsage)
+	}
+
+	format, err := transferFormat(*formatName, *output)
+	if err != nil {
+		return err
+	}
+
+	store, err := openStore(ctx, db)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	doc, err := transfer.Export(ctx, store)
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		return transfer.Write(os.Stdout, doc, format)
+	}
+	f, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if err := transfer.Write(f, doc, format); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "exported %d tasks to %s\n", len(doc.Tasks), *output)
+	return nil
+}
+
+// runImport implements the `peverel import` command, reading the tasks from a file
+// or from the standard input, and printing what was imported.
+func runImport(ctx context.Context, db config.Database, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	formatName := fs.String("format", "", "json or csv, from the extension of the file by default")
+	replace := fs.Bool("replace", false, "delete every task before importing, instead of merging")
+	dryRun := fs.Bool("dry-run", false, "only report what would be imported and the conflicts")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 1 {
+		return errors.New(importUsage)
+	}
+	input := fs.Arg(0)
+
+	var (
+		format transfer.Format
+		err    error
+	)
+	if input == "-" {
+		if *formatName == "" {
+			return errors.New("-format is required to read the standard input")
+		}
+		format, err = transfer.ParseFormat(*formatName)
+	} else {
+		format, err = transferFormat(*formatName, input)
+	}
+	if err != nil {
+		return err
+	}
+
+	var r io.Reader = os.Stdin
+	if input != "-" {
+		f, err := os.Open(input)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	doc, err := transfer.Read(r, format)
+	if err != nil {
+		return err
+	}
+
+	store, err := openStore(ctx, db)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	report, _, err := transfer.Import(ctx, store, doc, transfer.Options{Replace: *replace, DryRun: *dryRun})
+	if err != nil {
+		return err
+	}
+	fmt.Print(report)
+	return nil
+}
+
+// getExport downloads every task with its completion history, in the format of the query.
+func (s *server) getExport(w http.ResponseWriter, r *http.Request) {
+	formatName := r.URL.Query().Get("format")
+	if formatName == "" {
+		formatName = string(transfer.JSON)
+	}
+	format, err := transfer.ParseFormat(formatName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	doc, err := transfer.Export(r.Context(), s.store)
+	if err != nil {
+		log.Logger.Errorf("export tasks: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	contentType := "application/json"
+	if format == transfer.CSV {
+		contentType = "text/csv; charset=utf-8"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="peverel-%s.%s"`, time.Now().Format("2006-01-02"), format))
+	if err := transfer.Write(w, doc, format); err != nil {
+		log.Logger.Errorf("write export: %v", err)
+	}
+}
+
+// postImport imports the uploaded file and renders the report.
+func (s *server) postImport(t *template.Template) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, fmt.Sprintf("read uploaded file: %v", err), http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+
+		format, err := transfer.FormatOf(header.Filename)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		doc, err := transfer.Read(file, format)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		opts := transfer.Options{
+			Replace: r.FormValue("mode") == "replace",
+			DryRun:  r.FormValue("dry_run") != "",
+		}
+		report, ids, err := transfer.Import(ctx, s.store, doc, opts)
+		if err != nil {
+			log.Logger.Errorf("import %q: %v", header.Filename, err)
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		if !opts.DryRun {
+			log.Logger.Infof("imported %d tasks from %q", len(ids), header.Filename)
+			for _, id := range report.DeletedIds {
+				s.events.publish(taskEvent{Type: taskDeleted, TaskId: id})
+			}
+		}
+		for _, id := range ids {
+			s.events.publish(taskEvent{Type: taskCreated, TaskId: id})
+		}
+
+		if err := t.ExecuteTemplate(w, "import-report", report); err != nil {
+			log.Logger.Errorf("execute template %q: %v", "import-report", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// transferFormat returns the format given by name, else the one of the file extension, else JSON.
+func transferFormat(name, filename string) (transfer.Format, error) {
+	if name != "" {
+		return transfer.ParseFormat(name)
+	}
+	if filename != "" {
+		return transfer.FormatOf(filename)
+	}
+	return transfer.JSON, nil
+}
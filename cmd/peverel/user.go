@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/markor147/peverel/internal/config"
+	data "github.com/markor147/peverel/internal/data"
+)
+
+const userUsage = "usage: peverel user add|passwd|rm <username> | peverel user list"
+
+// minPasswordLength is the minimum length of the passwords set from the command line.
+const minPasswordLength = 8
+
+// runUser implements the `peverel user` commands managing the accounts of the web UI.
+// Passwords are read from the first line of the standard input.
+func runUser(ctx context.Context, db config.Database, args []string) error {
+	if len(args) == 0 {
+		return errors.New(userUsage)
+	}
+
+	store, err := openStore(ctx, db)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	if args[0] == "list" {
+		if len(args) != 1 {
+			return errors.New(userUsage)
+		}
+		users, err := store.GetUsers(ctx)
+		if err != nil {
+			return err
+		}
+		return printUsers(os.Stdout, users)
+	}
+
+	if len(args) != 2 {
+		return errors.New(userUsage)
+	}
+	username := strings.TrimSpace(args[1])
+	if username == "" {
+		return errors.New("username is required")
+	}
+
+	switch args[0] {
+	case "add":
+		hash, err := readPasswordHash(os.Stdin, username)
+		if err != nil {
+			return err
+		}
+		if _, err := store.AddUser(ctx, data.User{
+			Username:     username,
+			PasswordHash: hash,
+			CreatedAt:    time.Now(),
+		}); err != nil {
+			return err
+		}
+		fmt.Printf("user %q added\n", username)
+		return nil
+	case "passwd":
+		user, err := store.GetUserByName(ctx, username)
+		if err != nil {
+			return err
+		}
+		hash, err := readPasswordHash(os.Stdin, username)
+		if err != nil {
+			return err
+		}
+		if err := store.UpdateUserPassword(ctx, user.Id, hash); err != nil {
+			return err
+		}
+		fmt.Printf("password of user %q updated\n", username)
+		return nil
+	case "rm":
+		user, err := store.GetUserByName(ctx, username)
+		if err != nil {
+			return err
+		}
+		if err := store.DeleteUser(ctx, user.Id); err != nil {
+			return err
+		}
+		fmt.Printf("user %q removed\n", username)
+		return nil
+	default:
+		return errors.New(userUsage)
+	}
+}
+
+// readPasswordHash reads a password from the first line of r and hashes it.
+func readPasswordHash(r io.Reader, username string) (string, error) {
+	fmt.Fprintf(os.Stderr, "password for %s: ", username)
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("read password: %w", err)
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if len(password) < minPasswordLength {
+		return "", fmt.Errorf("password must be at least %d characters long", minPasswordLength)
+	}
+	return hashPassword(password)
+}
+
+func printUsers(w io.Writer, users []data.User) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tUSERNAME\tCREATED AT")
+	for _, u := range users {
+		fmt.Fprintf(tw, "%d\t%s\t%s\n", u.Id, u.Username, u.CreatedAt.Local().Format(time.DateTime))
+	}
+	return tw.Flush()
+}
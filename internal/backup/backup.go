@@ -0,0 +1,223 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/markor147/peverel/internal/data"
+)
+
+/*
+=== BACKUPS ===
+A backup is a copy of the database written by VACUUM INTO, so the server keeps
+running while it is taken, in a file of the backups directory named after the
+time of the backup: peverel-20261018T090000Z.db. Every backup is checked with
+PRAGMA integrity_check before it is kept, and only the newest ones are kept.
+Restoring a backup replaces the database file, the server must be stopped.
+==================================
+*/
+
+const (
+	filePrefix = "peverel-"
+	fileSuffix = ".db"
+	timeLayout = "20060102T150405Z"
+)
+
+// Backup is a backup file of the backups directory.
+type Backup struct {
+	Path      string
+	CreatedAt time.Time
+	Size      int64
+}
+
+// Run writes a backup of the store in dir, creating the directory if needed,
+// checks its integrity, and removes the oldest backups so that only keep remain.
+// A keep lower than one keeps every backup. It returns the new backup and the removed ones.
+func Run(ctx context.Context, store *data.SQLiteStore, dir string, keep int) (Backup, []Backup, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return Backup{}, nil, fmt.Errorf("create backups directory: %w", err)
+	}
+
+	now := time.Now().UTC()
+	path := filepath.Join(dir, filePrefix+now.Format(timeLayout)+fileSuffix)
+	if _, err := os.Stat(path); err == nil {
+		return Backup{}, nil, fmt.Errorf("backup %q already exists", path)
+	}
+
+	// Written under a temporary name, so that a failed backup is never listed.
+	tmp := path + ".tmp"
+	_ = os.Remove(tmp)
+	if err := store.Backup(ctx, tmp); err != nil {
+		_ = os.Remove(tmp)
+		return Backup{}, nil, err
+	}
+	if err := data.CheckIntegrity(ctx, tmp); err != nil {
+		_ = os.Remove(tmp)
+		return Backup{}, nil, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return Backup{}, nil, fmt.Errorf("rename backup: %w", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return Backup{}, nil, fmt.Errorf("stat backup: %w", err)
+	}
+	b := Backup{Path: path, CreatedAt: now.Truncate(time.Second), Size: info.Size()}
+
+	removed, err := Prune(dir, keep)
+	return b, removed, err
+}
+
+// List returns the backups of dir, newest first. Other files are ignored.
+func List(dir string) ([]Backup, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("list backups: %w", err)
+	}
+
+	var res []Backup
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, filePrefix) || !strings.HasSuffix(name, fileSuffix) {
+			continue
+		}
+		at, err := time.Parse(timeLayout, strings.TrimSuffix(strings.TrimPrefix(name, filePrefix), fileSuffix))
+		if err != nil {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, fmt.Errorf("list backups: %w", err)
+		}
+		res = append(res, Backup{Path: filepath.Join(dir, name), CreatedAt: at, Size: info.Size()})
+	}
+
+	slices.SortFunc(res, func(a, b Backup) int { return b.CreatedAt.Compare(a.CreatedAt) })
+	return res, nil
+}
+
+// Latest returns the newest backup of dir.
+func Latest(dir string) (Backup, error) {
+	backups, err := List(dir)
+	if err != nil {
+		return Backup{}, err
+	}
+	if len(backups) == 0 {
+		return Backup{}, fmt.Errorf("no backup in %q", dir)
+	}
+	return backups[0], nil
+}
+
+// Prune removes the oldest backups of dir so that only keep remain, and returns the removed ones.
+// A keep lower than one keeps every backup.
+func Prune(dir string, keep int) ([]Backup, error) {
+	if keep < 1 {
+		return nil, nil
+	}
+	backups, err := List(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(backups) <= keep {
+		return nil, nil
+	}
+
+	var removed []Backup
+	for _, b := range backups[keep:] {
+		if err := os.Remove(b.Path); err != nil {
+			return removed, fmt.Errorf("remove backup: %w", err)
+		}
+		removed = append(removed, b)
+	}
+	return removed, nil
+}
+
+// Restore checks the integrity of the backup at src and replaces the database file at dbPath with it.
+// The replaced database is kept next to it with the .pre-restore suffix, whose path is returned,
+// or "" if there was no database. If the backup cannot take its place, the database is put back.
+func Restore(ctx context.Context, src, dbPath string) (string, error) {
+	if err := data.CheckIntegrity(ctx, src); err != nil {
+		return "", err
+	}
+
+	// Copied next to the database first, so that the database is replaced by a rename.
+	tmp := dbPath + ".restore"
+	if err := copyFile(src, tmp); err != nil {
+		_ = os.Remove(tmp)
+		return "", fmt.Errorf("copy backup: %w", err)
+	}
+
+	var previous string
+	if _, err := os.Stat(dbPath); err == nil {
+		previous = dbPath + ".pre-restore"
+		if err := os.Rename(dbPath, previous); err != nil {
+			_ = os.Remove(tmp)
+			return "", fmt.Errorf("move database aside: %w", err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		_ = os.Remove(tmp)
+		return "", fmt.Errorf("stat database: %w", err)
+	}
+	if err := os.Rename(tmp, dbPath); err != nil {
+		_ = os.Remove(tmp)
+		if previous == "" {
+			return "", fmt.Errorf("replace database: %w", err)
+		}
+		// The database is put back, as if the restore had not started.
+		if rerr := os.Rename(previous, dbPath); rerr != nil {
+			return previous, fmt.Errorf("replace database: %w, and put it back from %q: %v", err, previous, rerr)
+		}
+		return "", fmt.Errorf("replace database: %w", err)
+	}
+
+	// The journals of the replaced database must not be replayed on the restored one,
+	// they follow it aside.
+	for _, suffix := range []string{"-journal", "-wal", "-shm"} {
+		journal := dbPath + suffix
+		if _, err := os.Stat(journal); errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		var err error
+		if previous == "" {
+			err = os.Remove(journal)
+		} else {
+			err = os.Rename(journal, previous+suffix)
+		}
+		if err != nil {
+			return previous, fmt.Errorf("move database journal aside: %w", err)
+		}
+	}
+	return previous, nil
+}
+
+// copyFile copies the file src to dst, which is created or truncated, and syncs it to disk.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o640)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
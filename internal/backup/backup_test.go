@@ -0,0 +1,223 @@
+package backup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/markor147/peverel/internal/data"
+)
+
+// touch creates the file with the content, failing the test on error.
+func touch(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o640); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func backupName(at time.Time) string {
+	return filePrefix + at.UTC().Format(timeLayout) + fileSuffix
+}
+
+func TestList(t *testing.T) {
+	dir := t.TempDir()
+	day := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	for _, name := range []string{
+		backupName(day),
+		backupName(day.Add(time.Hour)),
+		backupName(day.Add(-time.Hour)),
+		backupName(day.Add(2*time.Hour)) + ".tmp",
+		"peverel-latest.db",
+		"peverel.db",
+		"notes.txt",
+	} {
+		touch(t, filepath.Join(dir, name), "backup")
+	}
+	if err := os.Mkdir(filepath.Join(dir, backupName(day.Add(3*time.Hour))), 0o750); err != nil {
+		t.Fatal(err)
+	}
+
+	backups, err := List(dir)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	want := []time.Time{day.Add(time.Hour), day, day.Add(-time.Hour)}
+	if len(backups) != len(want) {
+		t.Fatalf("List = %+v, want %d backups", backups, len(want))
+	}
+	for i, b := range backups {
+		if !b.CreatedAt.Equal(want[i]) || b.Path != filepath.Join(dir, backupName(want[i])) || b.Size != int64(len("backup")) {
+			t.Errorf("List[%d] = %+v, want the backup of %v", i, b, want[i])
+		}
+	}
+
+	if _, err := List(filepath.Join(dir, "missing")); err == nil {
+		t.Error("List of a missing directory: no error")
+	}
+}
+
+func TestPrune(t *testing.T) {
+	day := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		keep    int
+		removed int
+	}{
+		{keep: 0, removed: 0},
+		{keep: -1, removed: 0},
+		{keep: 1, removed: 3},
+		{keep: 3, removed: 1},
+		{keep: 4, removed: 0},
+		{keep: 10, removed: 0},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		for i := range 4 {
+			touch(t, filepath.Join(dir, backupName(day.Add(time.Duration(i)*time.Hour))), "backup")
+		}
+		touch(t, filepath.Join(dir, "notes.txt"), "not a backup")
+
+		removed, err := Prune(dir, tt.keep)
+		if err != nil {
+			t.Fatalf("Prune(%d): %v", tt.keep, err)
+		}
+		if len(removed) != tt.removed {
+			t.Errorf("Prune(%d) removed %d backups, want %d", tt.keep, len(removed), tt.removed)
+		}
+		for _, b := range removed {
+			if _, err := os.Stat(b.Path); !os.IsNotExist(err) {
+				t.Errorf("Prune(%d): %s is still there", tt.keep, b.Path)
+			}
+		}
+
+		// The newest backups remain, and the other files are left alone.
+		left, err := List(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(left) != 4-tt.removed || !left[0].CreatedAt.Equal(day.Add(3*time.Hour)) {
+			t.Errorf("Prune(%d) left %+v", tt.keep, left)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "notes.txt")); err != nil {
+			t.Errorf("Prune(%d) removed another file: %v", tt.keep, err)
+		}
+	}
+}
+
+// newDatabase creates a database at path holding tasks named after names.
+func newDatabase(t *testing.T, path string, names ...string) {
+	t.Helper()
+	ctx := context.Background()
+	store, err := data.NewSQLiteStore(ctx, path)
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	defer store.Close()
+	for _, name := range names {
+		if _, err := store.AddTask(ctx, data.Task{Name: name, Period: 7}); err != nil {
+			t.Fatalf("AddTask: %v", err)
+		}
+	}
+}
+
+// taskNames returns the names of the tasks of the database at path.
+func taskNames(t *testing.T, path string) []string {
+	t.Helper()
+	ctx := context.Background()
+	store, err := data.OpenSQLiteStore(ctx, path)
+	if err != nil {
+		t.Fatalf("OpenSQLiteStore: %v", err)
+	}
+	defer store.Close()
+	tasks, err := store.Tasks(ctx, data.TaskFilter{Group: data.AnyGroup, Assignee: data.AnyMember, Done: true})
+	if err != nil {
+		t.Fatalf("Tasks: %v", err)
+	}
+	var names []string
+	for _, task := range tasks {
+		names = append(names, task.Name)
+	}
+	return names
+}
+
+func TestRestore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	src := filepath.Join(dir, "backup.db")
+	dbPath := filepath.Join(dir, "peverel.db")
+	newDatabase(t, src, "From the backup")
+	newDatabase(t, dbPath, "Live")
+
+	// Journals left by the replaced database must not reach the restored one.
+	for _, suffix := range []string{"-journal", "-wal", "-shm"} {
+		touch(t, dbPath+suffix, "journal"+suffix)
+	}
+
+	previous, err := Restore(ctx, src, dbPath)
+	if err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if previous != dbPath+".pre-restore" {
+		t.Errorf("Restore = %q, want %q", previous, dbPath+".pre-restore")
+	}
+	for _, suffix := range []string{"-journal", "-wal", "-shm"} {
+		if _, err := os.Stat(dbPath + suffix); !os.IsNotExist(err) {
+			t.Errorf("%s is still next to the restored database", suffix)
+		}
+		if b, err := os.ReadFile(previous + suffix); err != nil || string(b) != "journal"+suffix {
+			t.Errorf("%s of the replaced database = %q, %v", suffix, b, err)
+		}
+	}
+	if _, err := os.Stat(dbPath + ".restore"); !os.IsNotExist(err) {
+		t.Error("the temporary copy is still there")
+	}
+
+	if names := taskNames(t, dbPath); len(names) != 1 || names[0] != "From the backup" {
+		t.Errorf("restored tasks = %q, want the task of the backup", names)
+	}
+	for _, suffix := range []string{"-journal", "-wal", "-shm"} {
+		_ = os.Remove(previous + suffix)
+	}
+	if names := taskNames(t, previous); len(names) != 1 || names[0] != "Live" {
+		t.Errorf("replaced tasks = %q, want the live task", names)
+	}
+}
+
+func TestRestoreWithoutDatabase(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "backup.db")
+	dbPath := filepath.Join(dir, "peverel.db")
+	newDatabase(t, src, "From the backup")
+	touch(t, dbPath+"-wal", "stale")
+
+	previous, err := Restore(context.Background(), src, dbPath)
+	if err != nil || previous != "" {
+		t.Fatalf("Restore = %q, %v, want no replaced database", previous, err)
+	}
+	if _, err := os.Stat(dbPath + "-wal"); !os.IsNotExist(err) {
+		t.Error("the stale journal is still there")
+	}
+	if names := taskNames(t, dbPath); len(names) != 1 || names[0] != "From the backup" {
+		t.Errorf("restored tasks = %q, want the task of the backup", names)
+	}
+}
+
+func TestRestoreCorruptBackup(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "backup.db")
+	dbPath := filepath.Join(dir, "peverel.db")
+	touch(t, src, "not a database")
+	newDatabase(t, dbPath, "Live")
+
+	if _, err := Restore(context.Background(), src, dbPath); err == nil {
+		t.Fatal("Restore of a corrupt backup: no error")
+	}
+	if names := taskNames(t, dbPath); len(names) != 1 || names[0] != "Live" {
+		t.Errorf("tasks after a failed restore = %q, want the live task", names)
+	}
+	if _, err := os.Stat(dbPath + ".pre-restore"); !os.IsNotExist(err) {
+		t.Error("the database was moved aside by a failed restore")
+	}
+}
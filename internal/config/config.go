@@ -0,0 +1,415 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/markor147/peverel/internal/data"
+	"gopkg.in/yaml.v3"
+)
+
+/*
+=== CONFIGURATION ===
+Both binaries are configured by, in increasing order of precedence:
+  - the YAML file given by the -config flag, see config.example.yml;
+  - the environment variables, named after each field below;
+  - the -log-level, -log-output, -db and -port flags.
+Everything is validated before the binaries start, and the errors name the
+YAML key and the environment variable of the invalid settings.
+==================================
+*/
+
+// Config is the configuration of the server and of the notifier.
+type Config struct {
+	Log      Log      `yaml:"log"`
+	Database Database `yaml:"database"`
+	Server   Server   `yaml:"server"`
+	Backup   Backup   `yaml:"backup"`
+	Notifier Notifier `yaml:"notifier"`
+}
+
+// Log configures the global logger.
+type Log struct {
+	Level  string `yaml:"level"`  // LOG_LEVEL: debug, info, warn, error or off
+	Output string `yaml:"output"` // LOG_OUTPUT: stdout, stderr or a file path
+}
+
+// Database configures the SQLite database.
+type Database struct {
+	Path         string        `yaml:"path"`          // DB_CONN_STRING
+	QueryTimeout time.Duration `yaml:"query_timeout"` // DB_QUERY_TIMEOUT, 0 disables the per-query deadline
+}
+
+// Server configures the web server.
+type Server struct {
+	Port int `yaml:"port"` // SERVER_PORT
+}
+
+// Backup configures the backups taken by `peverel backup` and by the server.
+type Backup struct {
+	Dir      string        `yaml:"dir"`      // BACKUP_DIR, the backups directory next to the database by default
+	Keep     int           `yaml:"keep"`     // BACKUP_KEEP, 0 keeps every backup
+	Interval time.Duration `yaml:"interval"` // BACKUP_INTERVAL of the scheduled backups, 0 disables them
+}
+
+// Notifier configures when and through which channels the notifier sends the expired tasks.
+type Notifier struct {
+	ScheduledTime  string    `yaml:"scheduled_time"`  // SCHEDULED_TIME, as 15:04-07; empty sends once and exits
+	ScheduledHours int       `yaml:"scheduled_hours"` // SCHEDULED_HOURS between two sends
+	Email          Email     `yaml:"email"`           // an email channel, enabled by its SMTP server
+	Channels       []Channel `yaml:"channels"`
+}
+
+// Channel configures a notification channel. Only the fields of its type are used.
+type Channel struct {
+	Type       string   `yaml:"type"`       // email, webhook, ntfy, gotify or telegram
+	Name       string   `yaml:"name"`       // in the logs, the type by default
+	Template   string   `yaml:"template"`   // file of the message template, the built-in one by default
+	Recipients []string `yaml:"recipients"` // email addresses, webhook URLs, ntfy topics or Telegram chat ids
+	URL        string   `yaml:"url"`        // ntfy, Gotify or Telegram Bot API server
+	Token      string   `yaml:"token"`      // ntfy access token, Gotify application token, Telegram bot token or webhook bearer token
+	Priority   int      `yaml:"priority"`   // ntfy (1 to 5) or Gotify priority, the server default when 0
+	Sender     string   `yaml:"sender"`     // email sender
+	SMTP       SMTP     `yaml:"smtp"`       // email server
+}
+
+// Channel types.
+const (
+	ChannelEmail    = "email"
+	ChannelWebhook  = "webhook"
+	ChannelNtfy     = "ntfy"
+	ChannelGotify   = "gotify"
+	ChannelTelegram = "telegram"
+)
+
+// Email configures the email channel set by the environment variables.
+type Email struct {
+	Sender     string   `yaml:"sender"`     // EMAIL_SENDER
+	Recipients []string `yaml:"recipients"` // EMAIL_RECIPIENTS, comma separated
+	SMTP       SMTP     `yaml:"smtp"`
+}
+
+// SMTP configures the server sending the emails.
+type SMTP struct {
+	Server   string `yaml:"server"`   // SMTP_SERVER
+	Port     int    `yaml:"port"`     // SMTP_PORT
+	Username string `yaml:"username"` // SMTP_USERNAME
+	Password string `yaml:"password"` // SMTP_PASSWORD
+}
+
+// Default returns the configuration used for the settings left unset.
+func Default() Config {
+	return Config{
+		Log:      Log{Level: "info", Output: "stderr"},
+		Database: Database{QueryTimeout: 5 * time.Second},
+		Server:   Server{Port: 8080},
+		Backup:   Backup{Keep: 7},
+		Notifier: Notifier{
+			ScheduledHours: 24,
+			Email:          Email{SMTP: SMTP{Port: 587}},
+		},
+	}
+}
+
+// Load parses the flags of the command line args, reads the file given by -config, if any,
+// overlays the environment variables and the flags, and validates the result.
+// It returns the arguments left after the flags, e.g. a subcommand.
+func Load(name string, args []string, getenv func(string) string) (Config, []string, error) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	path := fs.String("config", "", "YAML configuration file")
+	logLevel := fs.String("log-level", "", "log level, overrides LOG_LEVEL")
+	logOutput := fs.String("log-output", "", "log output, overrides LOG_OUTPUT")
+	db := fs.String("db", "", "database file, overrides DB_CONN_STRING")
+	port := fs.Int("port", 0, "server port, overrides SERVER_PORT")
+	if err := fs.Parse(args); err != nil {
+		return Config{}, nil, err
+	}
+
+	cfg := Default()
+	if *path != "" {
+		if err := cfg.readFile(*path); err != nil {
+			return Config{}, nil, err
+		}
+	}
+	if err := cfg.overlayEnv(getenv); err != nil {
+		return Config{}, nil, err
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "log-level":
+			cfg.Log.Level = *logLevel
+		case "log-output":
+			cfg.Log.Output = *logOutput
+		case "db":
+			cfg.Database.Path = *db
+		case "port":
+			cfg.Server.Port = *port
+		}
+	})
+
+	for i := range cfg.Notifier.Channels {
+		if ch := &cfg.Notifier.Channels[i]; ch.Type == ChannelEmail && ch.SMTP.Port == 0 {
+			ch.SMTP.Port = 587
+		}
+	}
+	if cfg.Backup.Dir == "" && cfg.Database.Path != "" {
+		cfg.Backup.Dir = filepath.Join(filepath.Dir(data.DatabasePath(cfg.Database.Path)), "backups")
+	}
+	if err := cfg.Validate(); err != nil {
+		return Config{}, nil, err
+	}
+	return cfg, fs.Args(), nil
+}
+
+// readFile overlays the settings of the YAML file. Unknown keys are errors, to catch typos.
+func (c *Config) readFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("read config: %w", err)
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("parse config %q: %w", path, err)
+	}
+	return nil
+}
+
+// overlayEnv overlays the environment variables that are set.
+func (c *Config) overlayEnv(getenv func(string) string) error {
+	var errs []error
+	str := func(key string, dst *string) {
+		if v := getenv(key); v != "" {
+			*dst = v
+		}
+	}
+	integer := func(key string, dst *int) {
+		if v := getenv(key); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %q is not an integer", key, v))
+				return
+			}
+			*dst = n
+		}
+	}
+	duration := func(key string, dst *time.Duration) {
+		if v := getenv(key); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %q is not a duration, e.g. 30s or 24h", key, v))
+				return
+			}
+			*dst = d
+		}
+	}
+
+	str("LOG_LEVEL", &c.Log.Level)
+	str("LOG_OUTPUT", &c.Log.Output)
+	str("DB_CONN_STRING", &c.Database.Path)
+	duration("DB_QUERY_TIMEOUT", &c.Database.QueryTimeout)
+	integer("SERVER_PORT", &c.Server.Port)
+	str("BACKUP_DIR", &c.Backup.Dir)
+	integer("BACKUP_KEEP", &c.Backup.Keep)
+	duration("BACKUP_INTERVAL", &c.Backup.Interval)
+	str("SCHEDULED_TIME", &c.Notifier.ScheduledTime)
+	integer("SCHEDULED_HOURS", &c.Notifier.ScheduledHours)
+	str("EMAIL_SENDER", &c.Notifier.Email.Sender)
+	if v := getenv("EMAIL_RECIPIENTS"); v != "" {
+		c.Notifier.Email.Recipients = splitList(v)
+	}
+	str("SMTP_SERVER", &c.Notifier.Email.SMTP.Server)
+	integer("SMTP_PORT", &c.Notifier.Email.SMTP.Port)
+	str("SMTP_USERNAME", &c.Notifier.Email.SMTP.Username)
+	str("SMTP_PASSWORD", &c.Notifier.Email.SMTP.Password)
+
+	return errors.Join(errs...)
+}
+
+// Validate checks the settings shared by every command.
+func (c Config) Validate() error {
+	var errs []error
+	switch c.Log.Level {
+	case "debug", "info", "warn", "error", "off":
+	default:
+		errs = append(errs, invalid("log.level", "LOG_LEVEL", "must be debug, info, warn, error or off, got %q", c.Log.Level))
+	}
+	if c.Log.Output == "" {
+		errs = append(errs, invalid("log.output", "LOG_OUTPUT", "is required, e.g. stderr"))
+	}
+	if c.Database.Path == "" {
+		errs = append(errs, invalid("database.path", "DB_CONN_STRING", "is required, e.g. ./tasks.db"))
+	}
+	if c.Database.QueryTimeout < 0 {
+		errs = append(errs, invalid("database.query_timeout", "DB_QUERY_TIMEOUT", "must not be negative"))
+	}
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		errs = append(errs, invalid("server.port", "SERVER_PORT", "must be between 1 and 65535, got %d", c.Server.Port))
+	}
+	if c.Backup.Keep < 0 {
+		errs = append(errs, invalid("backup.keep", "BACKUP_KEEP", "must not be negative"))
+	}
+	if c.Backup.Interval < 0 {
+		errs = append(errs, invalid("backup.interval", "BACKUP_INTERVAL", "must not be negative"))
+	}
+	return errors.Join(errs...)
+}
+
+// ValidateNotifier checks the settings needed to send the notifications.
+func (c Config) ValidateNotifier() error {
+	var errs []error
+	n := c.Notifier
+	if n.ScheduledTime != "" {
+		if _, err := n.Schedule(); err != nil {
+			errs = append(errs, invalid("notifier.scheduled_time", "SCHEDULED_TIME", "must be a time as 15:04-07, got %q", n.ScheduledTime))
+		}
+		if n.ScheduledHours < 1 {
+			errs = append(errs, invalid("notifier.scheduled_hours", "SCHEDULED_HOURS", "must be greater than zero"))
+		}
+	}
+
+	if n.Email.SMTP.Server != "" {
+		if n.Email.Sender == "" {
+			errs = append(errs, invalid("notifier.email.sender", "EMAIL_SENDER", "is required"))
+		}
+		if len(n.Email.Recipients) == 0 {
+			errs = append(errs, invalid("notifier.email.recipients", "EMAIL_RECIPIENTS", "needs at least one address"))
+		}
+		if n.Email.SMTP.Port < 1 || n.Email.SMTP.Port > 65535 {
+			errs = append(errs, invalid("notifier.email.smtp.port", "SMTP_PORT", "must be between 1 and 65535, got %d", n.Email.SMTP.Port))
+		}
+	}
+	for i, ch := range n.Channels {
+		errs = append(errs, ch.validate(fmt.Sprintf("notifier.channels[%d]", i)))
+	}
+
+	if len(n.AllChannels()) == 0 {
+		errs = append(errs, errors.New("no notification channel: set notifier.channels, or notifier.email.smtp.server (SMTP_SERVER)"))
+	}
+	return errors.Join(errs...)
+}
+
+// validate checks the fields of the channel type, reported under the key.
+func (ch Channel) validate(key string) error {
+	var errs []error
+	problem := func(field, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s.%s %s", key, field, fmt.Sprintf(format, args...)))
+	}
+	requireURL := func(field, u string) {
+		if parsed, err := url.Parse(u); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			problem(field, "must be an http or https URL, got %q", u)
+		}
+	}
+
+	switch ch.Type {
+	case ChannelEmail:
+		if ch.Sender == "" {
+			problem("sender", "is required")
+		}
+		if len(ch.Recipients) == 0 {
+			problem("recipients", "needs at least one address")
+		}
+		if ch.SMTP.Server == "" {
+			problem("smtp.server", "is required")
+		}
+		if ch.SMTP.Port < 1 || ch.SMTP.Port > 65535 {
+			problem("smtp.port", "must be between 1 and 65535, got %d", ch.SMTP.Port)
+		}
+	case ChannelWebhook:
+		if len(ch.Recipients) == 0 {
+			problem("recipients", "needs at least one URL")
+		}
+		for _, r := range ch.Recipients {
+			requireURL("recipients", r)
+		}
+	case ChannelNtfy:
+		requireURL("url", ch.URL)
+		if len(ch.Recipients) == 0 {
+			problem("recipients", "needs at least one topic")
+		}
+		if ch.Priority < 0 || ch.Priority > 5 {
+			problem("priority", "must be between 1 and 5, got %d", ch.Priority)
+		}
+	case ChannelGotify:
+		requireURL("url", ch.URL)
+		if ch.Token == "" {
+			problem("token", "is required, the token of the Gotify application")
+		}
+		if ch.Priority < 0 {
+			problem("priority", "must not be negative")
+		}
+	case ChannelTelegram:
+		if ch.URL != "" {
+			requireURL("url", ch.URL)
+		}
+		if ch.Token == "" {
+			problem("token", "is required, the token of the bot given by @BotFather")
+		}
+		if len(ch.Recipients) == 0 {
+			problem("recipients", "needs at least one chat id")
+		}
+		for _, r := range ch.Recipients {
+			if _, err := strconv.ParseInt(r, 10, 64); err != nil {
+				problem("recipients", "must be chat ids, got %q", r)
+			}
+		}
+	default:
+		problem("type", "must be %s, %s, %s, %s or %s, got %q", ChannelEmail, ChannelWebhook, ChannelNtfy, ChannelGotify, ChannelTelegram, ch.Type)
+	}
+
+	if ch.Template != "" {
+		if _, err := os.Stat(ch.Template); err != nil {
+			problem("template", "cannot be read: %v", err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// AllChannels returns the configured channels, after the email channel of notifier.email
+// when its SMTP server is set.
+func (n Notifier) AllChannels() []Channel {
+	var res []Channel
+	if n.Email.SMTP.Server != "" {
+		res = append(res, Channel{
+			Type:       ChannelEmail,
+			Recipients: n.Email.Recipients,
+			Sender:     n.Email.Sender,
+			SMTP:       n.Email.SMTP,
+		})
+	}
+	return append(res, n.Channels...)
+}
+
+// Schedule parses the scheduled time of the notifications.
+func (n Notifier) Schedule() (time.Time, error) {
+	return time.Parse("15:04-07", n.ScheduledTime)
+}
+
+// === Helpers ===
+
+// invalid describes an invalid setting by its YAML key and its environment variable.
+func invalid(key, env, format string, args ...any) error {
+	return fmt.Errorf("%s (%s) %s", key, env, fmt.Sprintf(format, args...))
+}
+
+// splitList splits a comma separated list, dropping the empty items.
+func splitList(s string) []string {
+	var res []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			res = append(res, item)
+		}
+	}
+	return res
+}
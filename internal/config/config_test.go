@@ -0,0 +1,306 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// env returns a getenv reading the variables of vars.
+func env(vars map[string]string) func(string) string {
+	return func(key string) string { return vars[key] }
+}
+
+// writeFile writes a YAML configuration file and returns its path.
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	yml := writeFile(t, `
+log:
+  level: warn
+  output: stdout
+database:
+  path: /var/lib/peverel/tasks.db
+  query_timeout: 10s
+server:
+  port: 9090
+backup:
+  keep: 3
+notifier:
+  channels:
+    - type: email
+      sender: peverel@example.com
+      recipients: [me@example.com]
+      smtp:
+        server: smtp.example.com
+`)
+
+	tests := []struct {
+		name  string
+		args  []string
+		env   map[string]string
+		check func(t *testing.T, cfg Config)
+	}{
+		{
+			name: "defaults",
+			args: []string{"-db", "tasks.db"},
+			check: func(t *testing.T, cfg Config) {
+				if cfg.Log.Level != "info" || cfg.Log.Output != "stderr" || cfg.Server.Port != 8080 || cfg.Backup.Keep != 7 {
+					t.Errorf("defaults = %+v", cfg)
+				}
+				if cfg.Database.QueryTimeout != 5*time.Second || cfg.Notifier.Email.SMTP.Port != 587 {
+					t.Errorf("defaults = %+v", cfg)
+				}
+				if cfg.Backup.Dir != "backups" {
+					t.Errorf("Backup.Dir = %q, want the backups directory next to the database", cfg.Backup.Dir)
+				}
+			},
+		},
+		{
+			name: "file",
+			args: []string{"-config", yml},
+			check: func(t *testing.T, cfg Config) {
+				if cfg.Log.Level != "warn" || cfg.Log.Output != "stdout" || cfg.Server.Port != 9090 || cfg.Backup.Keep != 3 {
+					t.Errorf("file = %+v", cfg)
+				}
+				if cfg.Database.Path != "/var/lib/peverel/tasks.db" || cfg.Database.QueryTimeout != 10*time.Second {
+					t.Errorf("Database = %+v", cfg.Database)
+				}
+				if cfg.Backup.Dir != "/var/lib/peverel/backups" {
+					t.Errorf("Backup.Dir = %q", cfg.Backup.Dir)
+				}
+				if len(cfg.Notifier.Channels) != 1 || cfg.Notifier.Channels[0].SMTP.Port != 587 {
+					t.Errorf("Channels = %+v, want the email channel on port 587", cfg.Notifier.Channels)
+				}
+			},
+		},
+		{
+			name: "environment over file",
+			args: []string{"-config", yml},
+			env: map[string]string{
+				"LOG_LEVEL":        "debug",
+				"DB_CONN_STRING":   "env.db",
+				"DB_QUERY_TIMEOUT": "0s",
+				"SERVER_PORT":      "7070",
+				"BACKUP_DIR":       "/backups",
+				"EMAIL_RECIPIENTS": "a@example.com, ,b@example.com",
+			},
+			check: func(t *testing.T, cfg Config) {
+				if cfg.Log.Level != "debug" || cfg.Log.Output != "stdout" {
+					t.Errorf("Log = %+v, want the level of the environment and the output of the file", cfg.Log)
+				}
+				if cfg.Database.Path != "env.db" || cfg.Database.QueryTimeout != 0 || cfg.Server.Port != 7070 || cfg.Backup.Dir != "/backups" {
+					t.Errorf("environment = %+v", cfg)
+				}
+				if got := strings.Join(cfg.Notifier.Email.Recipients, " "); got != "a@example.com b@example.com" {
+					t.Errorf("Email.Recipients = %q", got)
+				}
+			},
+		},
+		{
+			name: "flags over environment",
+			args: []string{"-config", yml, "-log-level", "error", "-log-output", "peverel.log", "-db", "flag.db", "-port", "6060", "serve"},
+			env:  map[string]string{"LOG_LEVEL": "debug", "LOG_OUTPUT": "stderr", "DB_CONN_STRING": "env.db", "SERVER_PORT": "7070"},
+			check: func(t *testing.T, cfg Config) {
+				if cfg.Log.Level != "error" || cfg.Log.Output != "peverel.log" || cfg.Database.Path != "flag.db" || cfg.Server.Port != 6060 {
+					t.Errorf("flags = %+v", cfg)
+				}
+			},
+		},
+		{
+			name: "empty SERVER_PORT",
+			args: []string{"-config", yml},
+			env:  map[string]string{"SERVER_PORT": ""},
+			check: func(t *testing.T, cfg Config) {
+				if cfg.Server.Port != 9090 {
+					t.Errorf("Server.Port = %d, want the port of the file", cfg.Server.Port)
+				}
+			},
+		},
+		{
+			name: "empty SERVER_PORT without file",
+			args: []string{"-db", "tasks.db"},
+			env:  map[string]string{"SERVER_PORT": ""},
+			check: func(t *testing.T, cfg Config) {
+				if cfg.Server.Port != 8080 {
+					t.Errorf("Server.Port = %d, want the default port", cfg.Server.Port)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, rest, err := Load("peverel", tt.args, env(tt.env))
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if len(tt.args) > 0 && tt.args[len(tt.args)-1] == "serve" && (len(rest) != 1 || rest[0] != "serve") {
+				t.Errorf("Load returned the arguments %q, want the subcommand", rest)
+			}
+			tt.check(t, cfg)
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		file string
+		env  map[string]string
+		want []string
+	}{
+		{
+			name: "unknown key",
+			file: "server:\n  prot: 8080\n",
+			want: []string{"field prot not found"},
+		},
+		{
+			name: "missing file",
+			args: []string{"-config", "/nonexistent/config.yml"},
+			want: []string{"read config"},
+		},
+		{
+			name: "unknown flag",
+			args: []string{"-verbose"},
+			want: []string{"-verbose"},
+		},
+		{
+			name: "invalid environment",
+			args: []string{"-db", "tasks.db"},
+			env:  map[string]string{"SERVER_PORT": "http", "BACKUP_INTERVAL": "daily"},
+			want: []string{
+				`SERVER_PORT: "http" is not an integer`,
+				`BACKUP_INTERVAL: "daily" is not a duration, e.g. 30s or 24h`,
+			},
+		},
+		{
+			name: "invalid settings",
+			env:  map[string]string{"LOG_LEVEL": "loud", "SERVER_PORT": "70000", "BACKUP_KEEP": "-1"},
+			want: []string{
+				`log.level (LOG_LEVEL) must be debug, info, warn, error or off, got "loud"`,
+				"database.path (DB_CONN_STRING) is required, e.g. ./tasks.db",
+				"server.port (SERVER_PORT) must be between 1 and 65535, got 70000",
+				"backup.keep (BACKUP_KEEP) must not be negative",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := tt.args
+			if tt.file != "" {
+				args = append([]string{"-config", writeFile(t, tt.file)}, args...)
+			}
+			_, _, err := Load("peverel", args, env(tt.env))
+			if err == nil {
+				t.Fatal("Load: no error")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Load error %q lacks %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := Default()
+	valid.Database.Path = "tasks.db"
+
+	tests := []struct {
+		name   string
+		change func(c *Config)
+		want   string
+	}{
+		{"valid", func(c *Config) {}, ""},
+		{"log level", func(c *Config) { c.Log.Level = "trace" }, `log.level (LOG_LEVEL) must be debug, info, warn, error or off, got "trace"`},
+		{"log output", func(c *Config) { c.Log.Output = "" }, "log.output (LOG_OUTPUT) is required, e.g. stderr"},
+		{"database path", func(c *Config) { c.Database.Path = "" }, "database.path (DB_CONN_STRING) is required, e.g. ./tasks.db"},
+		{"query timeout", func(c *Config) { c.Database.QueryTimeout = -time.Second }, "database.query_timeout (DB_QUERY_TIMEOUT) must not be negative"},
+		{"port zero", func(c *Config) { c.Server.Port = 0 }, "server.port (SERVER_PORT) must be between 1 and 65535, got 0"},
+		{"port too high", func(c *Config) { c.Server.Port = 65536 }, "server.port (SERVER_PORT) must be between 1 and 65535, got 65536"},
+		{"backup keep", func(c *Config) { c.Backup.Keep = -1 }, "backup.keep (BACKUP_KEEP) must not be negative"},
+		{"backup interval", func(c *Config) { c.Backup.Interval = -time.Hour }, "backup.interval (BACKUP_INTERVAL) must not be negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid
+			tt.change(&cfg)
+			checkError(t, cfg.Validate(), tt.want)
+		})
+	}
+}
+
+func TestValidateNotifier(t *testing.T) {
+	valid := Default()
+	valid.Notifier.Email = Email{
+		Sender:     "peverel@example.com",
+		Recipients: []string{"me@example.com"},
+		SMTP:       SMTP{Server: "smtp.example.com", Port: 587},
+	}
+
+	tests := []struct {
+		name   string
+		change func(n *Notifier)
+		want   string
+	}{
+		{"valid", func(n *Notifier) {}, ""},
+		{"scheduled", func(n *Notifier) { n.ScheduledTime = "08:30+02" }, ""},
+		{"no channel", func(n *Notifier) { n.Email = Email{} }, "no notification channel: set notifier.channels, or notifier.email.smtp.server (SMTP_SERVER)"},
+		{"scheduled time", func(n *Notifier) { n.ScheduledTime = "8am" }, `notifier.scheduled_time (SCHEDULED_TIME) must be a time as 15:04-07, got "8am"`},
+		{"scheduled hours", func(n *Notifier) { n.ScheduledTime = "08:30+02"; n.ScheduledHours = 0 }, "notifier.scheduled_hours (SCHEDULED_HOURS) must be greater than zero"},
+		{"email sender", func(n *Notifier) { n.Email.Sender = "" }, "notifier.email.sender (EMAIL_SENDER) is required"},
+		{"email recipients", func(n *Notifier) { n.Email.Recipients = nil }, "notifier.email.recipients (EMAIL_RECIPIENTS) needs at least one address"},
+		{"smtp port", func(n *Notifier) { n.Email.SMTP.Port = 0 }, "notifier.email.smtp.port (SMTP_PORT) must be between 1 and 65535, got 0"},
+		{"channel type", func(n *Notifier) { n.Channels = []Channel{{Type: "sms"}} }, `notifier.channels[0].type must be email, webhook, ntfy, gotify or telegram, got "sms"`},
+		{"webhook", func(n *Notifier) {
+			n.Channels = []Channel{{Type: ChannelWebhook, Recipients: []string{"ftp://example.com"}}}
+		}, `notifier.channels[0].recipients must be an http or https URL, got "ftp://example.com"`},
+		{"ntfy", func(n *Notifier) {
+			n.Channels = []Channel{{Type: ChannelNtfy, URL: "https://ntfy.sh", Priority: 6}}
+		}, "notifier.channels[0].recipients needs at least one topic\nnotifier.channels[0].priority must be between 1 and 5, got 6"},
+		{"gotify", func(n *Notifier) {
+			n.Channels = []Channel{{}, {Type: ChannelGotify, URL: "https://gotify.example.com"}}
+		}, "notifier.channels[1].token is required, the token of the Gotify application"},
+		{"telegram", func(n *Notifier) {
+			n.Channels = []Channel{{Type: ChannelTelegram, Token: "123:abc", Recipients: []string{"@me"}}}
+		}, `notifier.channels[0].recipients must be chat ids, got "@me"`},
+		{"email channel", func(n *Notifier) {
+			n.Channels = []Channel{{Type: ChannelEmail, SMTP: SMTP{Port: 587}}}
+		}, "notifier.channels[0].sender is required\nnotifier.channels[0].recipients needs at least one address\nnotifier.channels[0].smtp.server is required"},
+		{"template", func(n *Notifier) {
+			n.Channels = []Channel{{Type: ChannelTelegram, Token: "123:abc", Recipients: []string{"42"}, Template: "/nonexistent.tmpl"}}
+		}, "notifier.channels[0].template cannot be read"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid
+			tt.change(&cfg.Notifier)
+			checkError(t, cfg.ValidateNotifier(), tt.want)
+		})
+	}
+}
+
+// checkError checks that err contains want, or is nil if want is empty.
+func checkError(t *testing.T, err error, want string) {
+	t.Helper()
+	if want == "" {
+		if err != nil {
+			t.Errorf("error %q, want none", err)
+		}
+		return
+	}
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("error %v, want %q", err, want)
+	}
+}
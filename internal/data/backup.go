@@ -0,0 +1,67 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Backup writes a consistent copy of the database to the file at path, which must not exist,
+// without blocking the readers and writers of the database. The copy is compacted by VACUUM INTO.
+// It is not bounded by the query timeout, copying a large database takes a while.
+func (s *SQLiteStore) Backup(ctx context.Context, path string) error {
+	if _, err := s.db.ExecContext(ctx, `VACUUM INTO ?`, path); err != nil {
+		return fmt.Errorf("function Backup: %w", err)
+	}
+	return nil
+}
+
+// CheckIntegrity runs the SQLite integrity check on the database file at path, opened read-only.
+// It returns an error listing the problems found, if any.
+func CheckIntegrity(ctx context.Context, path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("function CheckIntegrity: %w", err)
+	}
+
+	db, err := sql.Open("sqlite3", "file:"+url.PathEscape(path)+"?mode=ro")
+	if err != nil {
+		return fmt.Errorf("function CheckIntegrity: %w", err)
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, `PRAGMA integrity_check`)
+	if err != nil {
+		return fmt.Errorf("function CheckIntegrity: %w", err)
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return fmt.Errorf("function CheckIntegrity: %w", err)
+		}
+		problems = append(problems, line)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("function CheckIntegrity: %w", err)
+	}
+	if len(problems) != 1 || problems[0] != "ok" {
+		return fmt.Errorf("function CheckIntegrity: %q is corrupted: %s", path, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// DatabasePath returns the path of the database file of a connection string,
+// without the "file:" scheme and the parameters.
+func DatabasePath(connStr string) string {
+	path, _, _ := strings.Cut(connStr, "?")
+	path = strings.TrimPrefix(path, "file:")
+	if unescaped, err := url.PathUnescape(path); err == nil {
+		path = unescaped
+	}
+	return path
+}
@@ -0,0 +1,1109 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemStore is a Store keeping everything in memory.
+// It is meant for tests and mirrors the behaviour of SQLiteStore,
+// including the errors returned for missing or conflicting records.
+type MemStore struct {
+	mu sync.Mutex
+
+	tasks       map[TaskId]Task
+	groups      map[GroupId]Group
+	completions map[CompletionId]Completion
+	members     map[MemberId]Member
+	users       map[UserId]User
+	sessions    map[string]Session
+	apiTokens   map[APITokenId]APIToken
+
+	lastTaskId       TaskId
+	lastGroupId      GroupId
+	lastCompletionId CompletionId
+	lastMemberId     MemberId
+	lastUserId       UserId
+	lastAPITokenId   APITokenId
+}
+
+// NewMemStore returns an empty MemStore.
+func NewMemStore() *MemStore {
+	return &MemStore{
+		tasks:       make(map[TaskId]Task),
+		groups:      make(map[GroupId]Group),
+		completions: make(map[CompletionId]Completion),
+		members:     make(map[MemberId]Member),
+		users:       make(map[UserId]User),
+		sessions:    make(map[string]Session),
+		apiTokens:   make(map[APITokenId]APIToken),
+	}
+}
+
+// AddTask inserts a task and returns the new id.
+func (s *MemStore) AddTask(ctx context.Context, task Task) (TaskId, error) {
+	if err := ctx.Err(); err != nil {
+		return -1, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkGroup(task.GroupId); err != nil {
+		return -1, fmt.Errorf("function AddTask: %w", err)
+	}
+	if err := s.checkMember(task.AssigneeId); err != nil {
+		return -1, fmt.Errorf("function AddTask: %w", err)
+	}
+	if err := s.checkRotation(task.Rotation); err != nil {
+		return -1, fmt.Errorf("function AddTask: %w", err)
+	}
+
+	task.SnoozedUntil = time.Time{}
+	task.SkippedUntil = time.Time{}
+	return s.insertTask(task), nil
+}
+
+// CompleteTask records a completion of the task by the member with the current timestamp
+// and wakes it up if it was snoozed, at once.
+func (s *MemStore) CompleteTask(ctx context.Context, id TaskId, by MemberId) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.addCompletion(Completion{TaskId: id, At: time.Now(), MemberId: by}); err != nil {
+		return fmt.Errorf("function CompleteTask: %w", err)
+	}
+	task := s.tasks[id]
+	task.SnoozedUntil = time.Time{}
+	s.tasks[id] = task
+	return nil
+}
+
+// SkipTask skips the next occurrence of the task without completing it, and wakes it up if it was snoozed.
+func (s *MemStore) SkipTask(ctx context.Context, id TaskId) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task, ok := s.tasks[id]
+	if !ok {
+		return fmt.Errorf("function SkipTask: %w", ErrNotFound)
+	}
+	due := s.resolve(task).NextDue()
+	if due.IsZero() {
+		return fmt.Errorf("function SkipTask: task %d is never due again: %w", id, ErrConflict)
+	}
+	task.SkippedUntil = due
+	task.SnoozedUntil = time.Time{}
+	s.tasks[id] = task
+	return nil
+}
+
+// SnoozeTask hides the task from the due lists until the given day. The zero time wakes the task up.
+func (s *MemStore) SnoozeTask(ctx context.Context, id TaskId, until time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task, ok := s.tasks[id]
+	if !ok {
+		return fmt.Errorf("function SnoozeTask: %w", ErrNotFound)
+	}
+	task.SnoozedUntil = truncateDay(until)
+	s.tasks[id] = task
+	return nil
+}
+
+// GetTask retrieves a task by the specified id.
+func (s *MemStore) GetTask(ctx context.Context, id TaskId) (Task, error) {
+	if err := ctx.Err(); err != nil {
+		return Task{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task, ok := s.tasks[id]
+	if !ok {
+		return Task{}, fmt.Errorf("function GetTask: task %d: %w", id, ErrNotFound)
+	}
+	return s.resolve(task), nil
+}
+
+// DeleteTask deletes the task specified by the id, together with its completions.
+func (s *MemStore) DeleteTask(ctx context.Context, id TaskId) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.tasks[id]; !ok {
+		return fmt.Errorf("function DeleteTask: %w", ErrNotFound)
+	}
+	delete(s.tasks, id)
+	for cid, c := range s.completions {
+		if c.TaskId == id {
+			delete(s.completions, cid)
+		}
+	}
+	return nil
+}
+
+// UpdateTask replaces name, description, period and group of the task specified by the id.
+func (s *MemStore) UpdateTask(ctx context.Context, id TaskId, task Task) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, ok := s.tasks[id]
+	if !ok {
+		return fmt.Errorf("function UpdateTask: %w", ErrNotFound)
+	}
+	if err := s.checkGroup(task.GroupId); err != nil {
+		return fmt.Errorf("function UpdateTask: %w", err)
+	}
+	if err := s.checkMember(task.AssigneeId); err != nil {
+		return fmt.Errorf("function UpdateTask: %w", err)
+	}
+	if err := s.checkRotation(task.Rotation); err != nil {
+		return fmt.Errorf("function UpdateTask: %w", err)
+	}
+
+	old.Name = task.Name
+	old.Description = task.Description
+	old.Period = task.Period
+	old.Recurrence = task.Recurrence
+	old.Schedule = scheduleOrDefault(task.Schedule)
+	old.Anchor = memTime(task.Anchor)
+	old.DueDate = memTime(task.DueDate)
+	old.GroupId = task.GroupId
+	old.AssigneeId = task.AssigneeId
+	old.RotationMode = task.RotationMode
+	old.Rotation = append([]MemberId{}, task.Rotation...)
+	s.tasks[id] = old
+	return nil
+}
+
+// Tasks returns all the tasks selected by the filter, sorted by next due date.
+func (s *MemStore) Tasks(ctx context.Context, filter TaskFilter) ([]Task, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	today := Today()
+	res := make([]Task, 0)
+	for _, task := range s.tasks {
+		task = s.resolve(task)
+
+		switch filter.Group {
+		case AnyGroup:
+		case NoGroup:
+			if task.GroupId != NoGroup {
+				continue
+			}
+		default:
+			if task.GroupId != filter.Group {
+				continue
+			}
+		}
+
+		switch filter.Assignee {
+		case AnyMember:
+		case NoMember:
+			if task.AssigneeId != NoMember {
+				continue
+			}
+		default:
+			if task.AssigneeId != filter.Assignee {
+				continue
+			}
+		}
+
+		if !filter.matchesDue(task, today) {
+			continue
+		}
+
+		res = append(res, task)
+	}
+
+	sortByNextDue(res)
+	return res, nil
+}
+
+// Completions returns the completion history of a task, latest first.
+func (s *MemStore) Completions(ctx context.Context, id TaskId) ([]Completion, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res := make([]Completion, 0)
+	for _, c := range s.completions {
+		if c.TaskId == id {
+			res = append(res, s.resolveCompletion(c))
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if !res[i].At.Equal(res[j].At) {
+			return res[i].At.After(res[j].At)
+		}
+		return res[i].Id > res[j].Id
+	})
+	return res, nil
+}
+
+// AddCompletion records a completion of a task, possibly in the past, and returns the new id.
+func (s *MemStore) AddCompletion(ctx context.Context, c Completion) (CompletionId, error) {
+	if err := ctx.Err(); err != nil {
+		return -1, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id, err := s.addCompletion(c)
+	if err != nil {
+		return -1, fmt.Errorf("function AddCompletion: %w", err)
+	}
+	return id, nil
+}
+
+// ImportTasks adds the groups, the members and the tasks with their completion history at once,
+// after deleting every task if replace is set.
+func (s *MemStore) ImportTasks(ctx context.Context, imp Import, replace bool) ([]TaskId, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Check everything first, as the SQLite transaction would roll back
+	lastGroupId, lastMemberId := s.lastGroupId, s.lastMemberId
+	groupIds := make(map[string]GroupId, len(s.groups)+len(imp.Groups))
+	for id, group := range s.groups {
+		groupIds[group.Name] = id
+	}
+	for _, group := range imp.Groups {
+		if _, ok := groupIds[group.Name]; ok {
+			return nil, fmt.Errorf("function ImportTasks: %w: group name %q already used", ErrConflict, group.Name)
+		}
+		lastGroupId++
+		groupIds[group.Name] = lastGroupId
+	}
+	memberIds := make(map[string]MemberId, len(s.members)+len(imp.Members))
+	for id, member := range s.members {
+		memberIds[member.Name] = id
+	}
+	for _, member := range imp.Members {
+		if _, ok := memberIds[member.Name]; ok {
+			return nil, fmt.Errorf("function ImportTasks: %w: member name %q already used", ErrConflict, member.Name)
+		}
+		lastMemberId++
+		memberIds[member.Name] = lastMemberId
+	}
+
+	tasks := imp.Tasks(groupIds, memberIds)
+	newGroup := func(id GroupId) bool { return id > s.lastGroupId && id <= lastGroupId }
+	newMember := func(id MemberId) bool { return id > s.lastMemberId && id <= lastMemberId }
+	checkGroup := func(id GroupId) error {
+		if newGroup(id) {
+			return nil
+		}
+		return s.checkGroup(id)
+	}
+	checkMember := func(id MemberId) error {
+		if newMember(id) {
+			return nil
+		}
+		return s.checkMember(id)
+	}
+	checkRotation := func(rotation []MemberId) error {
+		return s.checkRotation(slices.DeleteFunc(slices.Clone(rotation), newMember))
+	}
+	for _, th := range tasks {
+		if err := checkGroup(th.Task.GroupId); err != nil {
+			return nil, fmt.Errorf("function ImportTasks: task %q: %w", th.Task.Name, err)
+		}
+		if err := checkMember(th.Task.AssigneeId); err != nil {
+			return nil, fmt.Errorf("function ImportTasks: task %q: %w", th.Task.Name, err)
+		}
+		if err := checkRotation(th.Task.Rotation); err != nil {
+			return nil, fmt.Errorf("function ImportTasks: task %q: %w", th.Task.Name, err)
+		}
+		for _, c := range th.Completions {
+			if err := checkMember(c.MemberId); err != nil {
+				return nil, fmt.Errorf("function ImportTasks: task %q: %w", th.Task.Name, err)
+			}
+		}
+	}
+
+	for _, group := range imp.Groups {
+		s.lastGroupId++
+		group.Id = s.lastGroupId
+		s.groups[group.Id] = group
+	}
+	for _, member := range imp.Members {
+		s.lastMemberId++
+		member.Id = s.lastMemberId
+		s.members[member.Id] = member
+	}
+
+	if replace {
+		s.tasks = make(map[TaskId]Task)
+		s.completions = make(map[CompletionId]Completion)
+	}
+
+	ids := make([]TaskId, 0, len(tasks))
+	for _, th := range tasks {
+		id := s.insertTask(th.Task)
+		for _, c := range th.Completions {
+			s.lastCompletionId++
+			c.Id = s.lastCompletionId
+			c.TaskId = id
+			c.At = memTime(c.At)
+			s.completions[c.Id] = c
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// BackdateCompletion moves the completion of the task specified by the id to the given time.
+func (s *MemStore) BackdateCompletion(ctx context.Context, taskId TaskId, id CompletionId, at time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.completions[id]
+	if !ok || c.TaskId != taskId {
+		return fmt.Errorf("function BackdateCompletion: %w", ErrNotFound)
+	}
+	c.At = memTime(at)
+	s.completions[id] = c
+	return nil
+}
+
+// DeleteCompletion deletes the completion of the task specified by the id.
+func (s *MemStore) DeleteCompletion(ctx context.Context, taskId TaskId, id CompletionId) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if c, ok := s.completions[id]; !ok || c.TaskId != taskId {
+		return fmt.Errorf("function DeleteCompletion: %w", ErrNotFound)
+	}
+	delete(s.completions, id)
+	return nil
+}
+
+// AddGroup inserts a group and returns the new id.
+func (s *MemStore) AddGroup(ctx context.Context, group Group) (GroupId, error) {
+	if err := ctx.Err(); err != nil {
+		return -1, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkGroupName(AnyGroup, group.Name); err != nil {
+		return -1, fmt.Errorf("function AddGroup: %w", err)
+	}
+
+	s.lastGroupId++
+	group.Id = s.lastGroupId
+	s.groups[group.Id] = group
+	return group.Id, nil
+}
+
+// GetGroup retrieves the group specified by the id.
+func (s *MemStore) GetGroup(ctx context.Context, id GroupId) (Group, error) {
+	if err := ctx.Err(); err != nil {
+		return Group{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	group, ok := s.groups[id]
+	if !ok {
+		return Group{}, fmt.Errorf("function GetGroup: group %d: %w", id, ErrNotFound)
+	}
+	return group, nil
+}
+
+// GetGroups returns all the groups sorted by name.
+func (s *MemStore) GetGroups(ctx context.Context) ([]Group, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res := make([]Group, 0, len(s.groups))
+	for _, group := range s.groups {
+		res = append(res, group)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res, nil
+}
+
+// UpdateGroup renames the group specified by the id.
+func (s *MemStore) UpdateGroup(ctx context.Context, id GroupId, group Group) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.groups[id]; !ok {
+		return fmt.Errorf("function UpdateGroup: %w", ErrNotFound)
+	}
+	if err := s.checkGroupName(id, group.Name); err != nil {
+		return fmt.Errorf("function UpdateGroup: %w", err)
+	}
+	s.groups[id] = Group{Id: id, Name: group.Name}
+	return nil
+}
+
+// DeleteGroup deletes the group specified by the id, leaving its tasks without a group.
+func (s *MemStore) DeleteGroup(ctx context.Context, id GroupId) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.groups[id]; !ok {
+		return fmt.Errorf("function DeleteGroup: %w", ErrNotFound)
+	}
+	delete(s.groups, id)
+	for tid, task := range s.tasks {
+		if task.GroupId == id {
+			task.GroupId = NoGroup
+			s.tasks[tid] = task
+		}
+	}
+	return nil
+}
+
+// SetRelation assigns a list of tasks to the specified group.
+func (s *MemStore) SetRelation(ctx context.Context, groupId GroupId, taskIds ...TaskId) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, taskId := range taskIds {
+		task, ok := s.tasks[taskId]
+		if !ok {
+			return fmt.Errorf("assign group %d to task %d: %w", groupId, taskId, ErrNotFound)
+		}
+		if err := s.checkGroup(groupId); err != nil {
+			return fmt.Errorf("assign group %d to task %d: %w", groupId, taskId, err)
+		}
+		task.GroupId = groupId
+		s.tasks[taskId] = task
+	}
+	return nil
+}
+
+// UnassignTask removes the assigned group from the specified task.
+func (s *MemStore) UnassignTask(ctx context.Context, id TaskId) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task, ok := s.tasks[id]
+	if !ok {
+		return fmt.Errorf("function UnassignTask: %w", ErrNotFound)
+	}
+	task.GroupId = NoGroup
+	s.tasks[id] = task
+	return nil
+}
+
+// AddMember inserts a member and returns the new id.
+func (s *MemStore) AddMember(ctx context.Context, member Member) (MemberId, error) {
+	if err := ctx.Err(); err != nil {
+		return -1, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkMemberName(AnyMember, member.Name); err != nil {
+		return -1, fmt.Errorf("function AddMember: %w", err)
+	}
+
+	s.lastMemberId++
+	member.Id = s.lastMemberId
+	s.members[member.Id] = member
+	return member.Id, nil
+}
+
+// GetMember retrieves the member specified by the id.
+func (s *MemStore) GetMember(ctx context.Context, id MemberId) (Member, error) {
+	if err := ctx.Err(); err != nil {
+		return Member{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	member, ok := s.members[id]
+	if !ok {
+		return Member{}, fmt.Errorf("function GetMember: member %d: %w", id, ErrNotFound)
+	}
+	return member, nil
+}
+
+// GetMembers returns all the members sorted by name.
+func (s *MemStore) GetMembers(ctx context.Context) ([]Member, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res := make([]Member, 0, len(s.members))
+	for _, member := range s.members {
+		res = append(res, member)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res, nil
+}
+
+// UpdateMember replaces name and email of the member specified by the id.
+func (s *MemStore) UpdateMember(ctx context.Context, id MemberId, member Member) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.members[id]; !ok {
+		return fmt.Errorf("function UpdateMember: %w", ErrNotFound)
+	}
+	if err := s.checkMemberName(id, member.Name); err != nil {
+		return fmt.Errorf("function UpdateMember: %w", err)
+	}
+	s.members[id] = Member{Id: id, Name: member.Name, Email: member.Email}
+	return nil
+}
+
+// DeleteMember deletes the member specified by the id.
+// Their tasks are left unassigned and their completions anonymous.
+func (s *MemStore) DeleteMember(ctx context.Context, id MemberId) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.members[id]; !ok {
+		return fmt.Errorf("function DeleteMember: %w", ErrNotFound)
+	}
+	delete(s.members, id)
+	for tid, task := range s.tasks {
+		if task.AssigneeId == id {
+			task.AssigneeId = NoMember
+		}
+		rotation := make([]MemberId, 0, len(task.Rotation))
+		for _, memberId := range task.Rotation {
+			if memberId != id {
+				rotation = append(rotation, memberId)
+			}
+		}
+		task.Rotation = rotation
+		s.tasks[tid] = task
+	}
+	for cid, c := range s.completions {
+		if c.MemberId == id {
+			c.MemberId = NoMember
+			s.completions[cid] = c
+		}
+	}
+	return nil
+}
+
+// AddUser inserts a user and returns the new id.
+func (s *MemStore) AddUser(ctx context.Context, user User) (UserId, error) {
+	if err := ctx.Err(); err != nil {
+		return -1, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if u.Username == user.Username {
+			return -1, fmt.Errorf("function AddUser: %w: username %q already used", ErrConflict, user.Username)
+		}
+	}
+
+	s.lastUserId++
+	user.Id = s.lastUserId
+	user.CreatedAt = memTime(user.CreatedAt)
+	s.users[user.Id] = user
+	return user.Id, nil
+}
+
+// GetUser retrieves the user specified by the id.
+func (s *MemStore) GetUser(ctx context.Context, id UserId) (User, error) {
+	if err := ctx.Err(); err != nil {
+		return User{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return User{}, fmt.Errorf("function GetUser: user %d: %w", id, ErrNotFound)
+	}
+	return user, nil
+}
+
+// GetUserByName retrieves the user with the given username.
+func (s *MemStore) GetUserByName(ctx context.Context, username string) (User, error) {
+	if err := ctx.Err(); err != nil {
+		return User{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, user := range s.users {
+		if user.Username == username {
+			return user, nil
+		}
+	}
+	return User{}, fmt.Errorf("function GetUserByName: user %q: %w", username, ErrNotFound)
+}
+
+// GetUsers returns all the users sorted by username.
+func (s *MemStore) GetUsers(ctx context.Context) ([]User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res := make([]User, 0, len(s.users))
+	for _, user := range s.users {
+		res = append(res, user)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Username < res[j].Username })
+	return res, nil
+}
+
+// UpdateUserPassword replaces the password hash of the user specified by the id.
+func (s *MemStore) UpdateUserPassword(ctx context.Context, id UserId, passwordHash string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return fmt.Errorf("function UpdateUserPassword: %w", ErrNotFound)
+	}
+	user.PasswordHash = passwordHash
+	s.users[id] = user
+	return nil
+}
+
+// DeleteUser deletes the user specified by the id, together with their sessions and API tokens.
+func (s *MemStore) DeleteUser(ctx context.Context, id UserId) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[id]; !ok {
+		return fmt.Errorf("function DeleteUser: %w", ErrNotFound)
+	}
+	delete(s.users, id)
+	for hash, session := range s.sessions {
+		if session.UserId == id {
+			delete(s.sessions, hash)
+		}
+	}
+	for tokenId, token := range s.apiTokens {
+		if token.UserId == id {
+			delete(s.apiTokens, tokenId)
+		}
+	}
+	return nil
+}
+
+// AddSession stores a new session.
+func (s *MemStore) AddSession(ctx context.Context, session Session) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[session.UserId]; !ok {
+		return fmt.Errorf("function AddSession: user %d: %w", session.UserId, ErrNotFound)
+	}
+	if _, ok := s.sessions[session.TokenHash]; ok {
+		return fmt.Errorf("function AddSession: %w: session already exists", ErrConflict)
+	}
+	session.CreatedAt = memTime(session.CreatedAt)
+	session.ExpiresAt = memTime(session.ExpiresAt)
+	s.sessions[session.TokenHash] = session
+	return nil
+}
+
+// GetSession retrieves the session with the given token hash, unless it has expired.
+func (s *MemStore) GetSession(ctx context.Context, tokenHash string) (Session, error) {
+	if err := ctx.Err(); err != nil {
+		return Session{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[tokenHash]
+	if !ok || !session.ExpiresAt.After(time.Now()) {
+		return Session{}, fmt.Errorf("function GetSession: %w", ErrNotFound)
+	}
+	return session, nil
+}
+
+// DeleteSession deletes the session with the given token hash.
+func (s *MemStore) DeleteSession(ctx context.Context, tokenHash string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.sessions[tokenHash]; !ok {
+		return fmt.Errorf("function DeleteSession: %w", ErrNotFound)
+	}
+	delete(s.sessions, tokenHash)
+	return nil
+}
+
+// DeleteExpiredSessions deletes the sessions expired before now.
+func (s *MemStore) DeleteExpiredSessions(ctx context.Context, now time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, session := range s.sessions {
+		if !session.ExpiresAt.After(now) {
+			delete(s.sessions, hash)
+		}
+	}
+	return nil
+}
+
+// AddAPIToken stores a new API token and returns its id.
+func (s *MemStore) AddAPIToken(ctx context.Context, token APIToken) (APITokenId, error) {
+	if err := ctx.Err(); err != nil {
+		return -1, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[token.UserId]; !ok {
+		return -1, fmt.Errorf("function AddAPIToken: user %d: %w", token.UserId, ErrNotFound)
+	}
+	for _, t := range s.apiTokens {
+		if t.TokenHash == token.TokenHash {
+			return -1, fmt.Errorf("function AddAPIToken: %w: token already exists", ErrConflict)
+		}
+	}
+	s.lastAPITokenId++
+	token.Id = s.lastAPITokenId
+	token.CreatedAt = memTime(token.CreatedAt)
+	token.LastUsedAt = memTime(token.LastUsedAt)
+	s.apiTokens[token.Id] = token
+	return token.Id, nil
+}
+
+// GetAPIToken retrieves the API token with the given token hash.
+func (s *MemStore) GetAPIToken(ctx context.Context, tokenHash string) (APIToken, error) {
+	if err := ctx.Err(); err != nil {
+		return APIToken{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, token := range s.apiTokens {
+		if token.TokenHash == tokenHash {
+			return token, nil
+		}
+	}
+	return APIToken{}, fmt.Errorf("function GetAPIToken: %w", ErrNotFound)
+}
+
+// GetAPITokens returns the API tokens of the user, oldest first.
+func (s *MemStore) GetAPITokens(ctx context.Context, userId UserId) ([]APIToken, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res := make([]APIToken, 0)
+	for _, token := range s.apiTokens {
+		if token.UserId == userId {
+			res = append(res, token)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Id < res[j].Id })
+	return res, nil
+}
+
+// TouchAPIToken records the last use of the API token specified by the id.
+func (s *MemStore) TouchAPIToken(ctx context.Context, id APITokenId, at time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.apiTokens[id]
+	if !ok {
+		return fmt.Errorf("function TouchAPIToken: %w", ErrNotFound)
+	}
+	token.LastUsedAt = memTime(at)
+	s.apiTokens[id] = token
+	return nil
+}
+
+// DeleteAPIToken revokes the API token specified by the id, if it belongs to the user.
+func (s *MemStore) DeleteAPIToken(ctx context.Context, userId UserId, id APITokenId) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.apiTokens[id]
+	if !ok || token.UserId != userId {
+		return fmt.Errorf("function DeleteAPIToken: %w", ErrNotFound)
+	}
+	delete(s.apiTokens, id)
+	return nil
+}
+
+// Close does nothing: a MemStore holds no resources.
+func (s *MemStore) Close() error {
+	return nil
+}
+
+// === Helpers ===
+
+// insertTask stores a new task and returns its id. The caller must hold the lock.
+func (s *MemStore) insertTask(task Task) TaskId {
+	s.lastTaskId++
+	task.Id = s.lastTaskId
+	task.Schedule = scheduleOrDefault(task.Schedule)
+	task.Anchor = memTime(task.Anchor)
+	task.DueDate = memTime(task.DueDate)
+	task.LastCompleted = memTime(task.LastCompleted)
+	task.SnoozedUntil = memTime(task.SnoozedUntil)
+	task.SkippedUntil = memTime(task.SkippedUntil)
+	task.Rotation = append([]MemberId{}, task.Rotation...)
+	s.tasks[task.Id] = task
+	return task.Id
+}
+
+// resolve fills the fields of a task derived from other records,
+// the same way the SQLite queries do. The caller must hold the lock.
+func (s *MemStore) resolve(task Task) Task {
+	if task.GroupId == AnyGroup {
+		task.GroupId = NoGroup
+	}
+	task.GroupName = s.groups[task.GroupId].Name
+	if task.AssigneeId == AnyMember {
+		task.AssigneeId = NoMember
+	}
+	task.AssigneeName = s.members[task.AssigneeId].Name
+	task.Rotation = append(make([]MemberId, 0, len(task.Rotation)), task.Rotation...)
+	task.Completions = 0
+	for _, c := range s.completions {
+		if c.TaskId != task.Id {
+			continue
+		}
+		task.Completions++
+		if c.At.After(task.LastCompleted) {
+			task.LastCompleted = c.At
+		}
+	}
+	return task
+}
+
+// addCompletion records a completion of a task and hands the task
+// to the next member of its rotation, if any. The caller must hold the lock.
+func (s *MemStore) addCompletion(c Completion) (CompletionId, error) {
+	if _, ok := s.tasks[c.TaskId]; !ok {
+		return -1, fmt.Errorf("task %d: %w", c.TaskId, ErrNotFound)
+	}
+	if err := s.checkMember(c.MemberId); err != nil {
+		return -1, fmt.Errorf("task %d: %w", c.TaskId, err)
+	}
+
+	s.lastCompletionId++
+	c.Id = s.lastCompletionId
+	c.At = memTime(c.At)
+	s.completions[c.Id] = c
+	s.advanceRotation(c.TaskId)
+	return c.Id, nil
+}
+
+// advanceRotation hands the task to the next member of its rotation, if any.
+// The caller must hold the lock.
+func (s *MemStore) advanceRotation(id TaskId) {
+	task := s.tasks[id]
+	if task.RotationMode == NoRotation {
+		return
+	}
+	lastDone := make(map[MemberId]time.Time)
+	for _, c := range s.completions {
+		if c.TaskId == id && c.At.After(lastDone[c.MemberId]) {
+			lastDone[c.MemberId] = c.At
+		}
+	}
+	current := task.AssigneeId
+	if current == AnyMember {
+		current = NoMember
+	}
+	task.AssigneeId = nextAssignee(task.RotationMode, task.Rotation, current, lastDone)
+	s.tasks[id] = task
+}
+
+// resolveCompletion fills the member fields of a completion
+// the same way the SQLite queries do. The caller must hold the lock.
+func (s *MemStore) resolveCompletion(c Completion) Completion {
+	if c.MemberId == AnyMember {
+		c.MemberId = NoMember
+	}
+	if member, ok := s.members[c.MemberId]; ok {
+		c.By = member.Name
+	}
+	return c
+}
+
+// checkGroup returns ErrNotFound if the group does not exist. The caller must hold the lock.
+func (s *MemStore) checkGroup(id GroupId) error {
+	if id == NoGroup || id == AnyGroup {
+		return nil
+	}
+	if _, ok := s.groups[id]; !ok {
+		return fmt.Errorf("group %d: %w", id, ErrNotFound)
+	}
+	return nil
+}
+
+// checkGroupName returns ErrConflict if a group other than self already uses the name.
+// The caller must hold the lock.
+func (s *MemStore) checkGroupName(self GroupId, name string) error {
+	for id, group := range s.groups {
+		if id != self && group.Name == name {
+			return fmt.Errorf("%w: group name %q already used", ErrConflict, name)
+		}
+	}
+	return nil
+}
+
+// checkMember returns ErrNotFound if the member does not exist. The caller must hold the lock.
+func (s *MemStore) checkMember(id MemberId) error {
+	if id == NoMember || id == AnyMember {
+		return nil
+	}
+	if _, ok := s.members[id]; !ok {
+		return fmt.Errorf("member %d: %w", id, ErrNotFound)
+	}
+	return nil
+}
+
+// checkRotation returns ErrNotFound if any member of the rotation does not exist.
+// The caller must hold the lock.
+func (s *MemStore) checkRotation(rotation []MemberId) error {
+	for _, id := range rotation {
+		if _, ok := s.members[id]; !ok {
+			return fmt.Errorf("member %d: %w", id, ErrNotFound)
+		}
+	}
+	return nil
+}
+
+// checkMemberName returns ErrConflict if a member other than self already uses the name.
+// The caller must hold the lock.
+func (s *MemStore) checkMemberName(self MemberId, name string) error {
+	for id, member := range s.members {
+		if id != self && member.Name == name {
+			return fmt.Errorf("%w: member name %q already used", ErrConflict, name)
+		}
+	}
+	return nil
+}
+
+// memTime drops what the SQLite store would lose by storing t as RFC3339 UTC.
+func memTime(t time.Time) time.Time {
+	return t.UTC().Truncate(time.Second)
+}
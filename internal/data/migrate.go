@@ -0,0 +1,206 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+=== SCHEMA MIGRATIONS ===
+Every change to the schema is a new file in migrations/ named NNNN_description.sql,
+where NNNN is the next schema version. Applied files must never be edited:
+add a new migration instead. The applied versions are recorded in schema_version.
+The databases created by init.sql before the migrations existed may already have
+the changes of the first migrations: those are recorded as applied without running them.
+==================================
+*/
+
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+// Migration is a numbered schema change embedded in the binary.
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// MigrationStatus reports whether a migration has been applied to the database.
+type MigrationStatus struct {
+	Migration
+	AppliedAt time.Time // zero if the migration is pending
+}
+
+// Applied returns true if the migration has been applied.
+func (s MigrationStatus) Applied() bool {
+	return !s.AppliedAt.IsZero()
+}
+
+const schemaVersionTable = `CREATE TABLE IF NOT EXISTS schema_version (
+  version    INTEGER PRIMARY KEY,
+  name       TEXT NOT NULL,
+  applied_at TEXT NOT NULL                     -- RFC3339 UTC
+);`
+
+// initSchema tells, for the migrations of the schema once created by init.sql,
+// whether a database already has their changes: the query counts their objects.
+var initSchema = map[int]string{
+	2: `SELECT COUNT(*) FROM pragma_table_info('tasks') WHERE name = 'group_id'`,
+	3: `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'completions'`,
+}
+
+// Migrations returns the embedded migrations sorted by version.
+func Migrations() ([]Migration, error) {
+	files, err := fs.Glob(migrationsFS, "migrations/*.sql")
+	if err != nil {
+		return nil, fmt.Errorf("list migrations: %w", err)
+	}
+
+	res := make([]Migration, 0, len(files))
+	for _, file := range files {
+		base := strings.TrimSuffix(strings.TrimPrefix(file, "migrations/"), ".sql")
+		versionStr, name, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("migration %q: name must be NNNN_description.sql", file)
+		}
+		version, err := strconv.Atoi(versionStr)
+		if err != nil {
+			return nil, fmt.Errorf("migration %q: invalid version: %w", file, err)
+		}
+		content, err := migrationsFS.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("read migration %q: %w", file, err)
+		}
+		res = append(res, Migration{Version: version, Name: name, SQL: string(content)})
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].Version < res[j].Version })
+	for i, m := range res {
+		if m.Version != i+1 {
+			return nil, fmt.Errorf("migration %04d_%s: expected version %d", m.Version, m.Name, i+1)
+		}
+	}
+	return res, nil
+}
+
+// MigrationsStatus lists all the embedded migrations and when they were applied.
+func (s *SQLiteStore) MigrationsStatus(ctx context.Context) ([]MigrationStatus, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := s.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		res = append(res, MigrationStatus{Migration: m, AppliedAt: applied[m.Version]})
+	}
+	return res, nil
+}
+
+// Migrate applies the pending migrations, each one in its own transaction,
+// and returns the migrations that have been applied.
+func (s *SQLiteStore) Migrate(ctx context.Context) ([]Migration, error) {
+	statuses, err := s.MigrationsStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]Migration, 0)
+	for _, st := range statuses {
+		if st.Applied() {
+			continue
+		}
+		if err := s.applyMigration(ctx, st.Migration); err != nil {
+			return res, err
+		}
+		res = append(res, st.Migration)
+	}
+	return res, nil
+}
+
+// appliedMigrations returns the applied schema versions and when they were applied.
+func (s *SQLiteStore) appliedMigrations(ctx context.Context) (map[int]time.Time, error) {
+	if _, err := s.db.ExecContext(ctx, schemaVersionTable); err != nil {
+		return nil, fmt.Errorf("create schema_version: %w", err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT version, applied_at FROM schema_version`)
+	if err != nil {
+		return nil, fmt.Errorf("get schema version: %w", err)
+	}
+	defer rows.Close()
+
+	res := make(map[int]time.Time)
+	for rows.Next() {
+		var (
+			version   int
+			appliedAt string
+		)
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("get schema version: %w", err)
+		}
+		res[version], _ = time.Parse(time.RFC3339, appliedAt)
+	}
+	return res, rows.Err()
+}
+
+func (s *SQLiteStore) applyMigration(ctx context.Context, m Migration) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("migration %04d_%s: %w", m.Version, m.Name, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	done, err := inInitSchema(ctx, tx, m)
+	if err != nil {
+		return fmt.Errorf("migration %04d_%s: %w", m.Version, m.Name, err)
+	}
+	if !done {
+		if _, err := tx.ExecContext(ctx, m.SQL); err != nil {
+			return fmt.Errorf("migration %04d_%s: %w", m.Version, m.Name, err)
+		}
+	}
+	if err := recordMigration(ctx, tx, m); err != nil {
+		return fmt.Errorf("migration %04d_%s: %w", m.Version, m.Name, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("migration %04d_%s: %w", m.Version, m.Name, err)
+	}
+	return nil
+}
+
+func recordMigration(ctx context.Context, tx *sql.Tx, m Migration) error {
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO schema_version (version, name, applied_at)
+		VALUES (?, ?, ?)`,
+		m.Version,
+		m.Name,
+		time.Now().UTC().Format(time.RFC3339),
+	)
+	return err
+}
+
+// inInitSchema returns true if the database, created by init.sql, already has the changes of the migration.
+func inInitSchema(ctx context.Context, tx *sql.Tx, m Migration) (bool, error) {
+	query, ok := initSchema[m.Version]
+	if !ok {
+		return false, nil
+	}
+	var count int
+	if err := tx.QueryRowContext(ctx, query).Scan(&count); err != nil {
+		return false, fmt.Errorf("check init.sql schema: %w", err)
+	}
+	return count > 0, nil
+}
@@ -0,0 +1,189 @@
+package data
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// baselineSchema is the schema of the databases created before the migrations existed.
+const baselineSchema = `CREATE TABLE IF NOT EXISTS tasks (
+  id             INTEGER PRIMARY KEY AUTOINCREMENT,
+  name           TEXT NOT NULL,
+  description    TEXT NOT NULL,
+  period         INTEGER NOT NULL,
+  last_completed TEXT NOT NULL
+);`
+
+// initSQLSchema is the schema created by the last init.sql, before the migrations existed:
+// it already has the groups and the completions.
+const initSQLSchema = `CREATE TABLE groups (
+  id     INTEGER PRIMARY KEY AUTOINCREMENT,
+  name   TEXT NOT NULL UNIQUE
+);
+CREATE TABLE tasks (
+  id             INTEGER PRIMARY KEY AUTOINCREMENT,
+  name           TEXT NOT NULL,
+  description    TEXT NOT NULL,
+  period         INTEGER NOT NULL,
+  last_completed TEXT NOT NULL,
+  group_id       INTEGER REFERENCES groups(id) ON DELETE SET NULL
+);
+CREATE TABLE completions (
+  id             INTEGER PRIMARY KEY AUTOINCREMENT,
+  task_id        INTEGER NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+  completed_at   TEXT NOT NULL,
+  completed_by   TEXT NOT NULL DEFAULT '',
+  note           TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX completions_task_id ON completions(task_id, completed_at);`
+
+// openMemorySQLiteStore opens an in-memory database without any schema.
+// The pool is limited to one connection, since every connection to :memory: is a new database.
+func openMemorySQLiteStore(t *testing.T) *SQLiteStore {
+	t.Helper()
+	s, err := OpenSQLiteStore(context.Background(), ":memory:")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	s.db.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = s.Close() })
+	return s
+}
+
+// checkMigrated checks that every migration is recorded, and that migrating again does nothing.
+func checkMigrated(t *testing.T, s *SQLiteStore) {
+	t.Helper()
+	ctx := context.Background()
+
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatalf("Migrations: %v", err)
+	}
+
+	var count, version int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*), MAX(version) FROM schema_version`).Scan(&count, &version); err != nil {
+		t.Fatalf("read schema_version: %v", err)
+	}
+	if count != len(migrations) || version != migrations[len(migrations)-1].Version {
+		t.Errorf("schema_version has %d rows up to version %d, want %d up to %d",
+			count, version, len(migrations), migrations[len(migrations)-1].Version)
+	}
+
+	statuses, err := s.MigrationsStatus(ctx)
+	if err != nil {
+		t.Fatalf("MigrationsStatus: %v", err)
+	}
+	for _, st := range statuses {
+		if !st.Applied() {
+			t.Errorf("migration %04d_%s is pending", st.Version, st.Name)
+		}
+	}
+
+	applied, err := s.Migrate(ctx)
+	if err != nil {
+		t.Fatalf("second Migrate: %v", err)
+	}
+	if len(applied) != 0 {
+		t.Errorf("second Migrate applied %d migrations, want none", len(applied))
+	}
+}
+
+func TestMigrateFresh(t *testing.T) {
+	ctx := context.Background()
+	s := openMemorySQLiteStore(t)
+
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatalf("Migrations: %v", err)
+	}
+	applied, err := s.Migrate(ctx)
+	if err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	if len(applied) != len(migrations) {
+		t.Errorf("Migrate applied %d migrations, want %d", len(applied), len(migrations))
+	}
+	checkMigrated(t, s)
+
+	// The migrated schema is the one expected by the store
+	id, err := s.AddTask(ctx, Task{Name: "Dishes", Period: 3, LastCompleted: time.Now()})
+	if err != nil {
+		t.Fatalf("AddTask: %v", err)
+	}
+	if _, err := s.GetTask(ctx, id); err != nil {
+		t.Errorf("GetTask: %v", err)
+	}
+}
+
+func TestMigrateBaseline(t *testing.T) {
+	ctx := context.Background()
+	s := openMemorySQLiteStore(t)
+
+	if _, err := s.db.ExecContext(ctx, baselineSchema); err != nil {
+		t.Fatalf("create baseline schema: %v", err)
+	}
+	lastCompleted := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	if _, err := s.db.ExecContext(ctx,
+		`INSERT INTO tasks (name, description, period, last_completed) VALUES (?, ?, ?, ?)`,
+		"Water plants", "", 5, lastCompleted.Format(time.RFC3339),
+	); err != nil {
+		t.Fatalf("insert baseline task: %v", err)
+	}
+
+	if _, err := s.Migrate(ctx); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	checkMigrated(t, s)
+
+	// The tasks of the baseline survive the migrations
+	task, err := s.GetTask(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetTask: %v", err)
+	}
+	if task.Name != "Water plants" || task.Period != 5 || !task.LastCompleted.Equal(lastCompleted) {
+		t.Errorf("GetTask = %+v, want the baseline task", task)
+	}
+	if task.GroupId != NoGroup || task.AssigneeId != NoMember || task.Schedule != Floating {
+		t.Errorf("GetTask = %+v, want the defaults of the migrated columns", task)
+	}
+}
+
+func TestMigrateInitSchema(t *testing.T) {
+	ctx := context.Background()
+	s := openMemorySQLiteStore(t)
+
+	if _, err := s.db.ExecContext(ctx, initSQLSchema); err != nil {
+		t.Fatalf("create init.sql schema: %v", err)
+	}
+	lastCompleted := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	completedAt := time.Date(2025, 3, 4, 0, 0, 0, 0, time.UTC)
+	if _, err := s.db.ExecContext(ctx, `INSERT INTO groups (name) VALUES ('Garden')`); err != nil {
+		t.Fatalf("insert init.sql group: %v", err)
+	}
+	if _, err := s.db.ExecContext(ctx,
+		`INSERT INTO tasks (name, description, period, last_completed, group_id) VALUES (?, ?, ?, ?, 1)`,
+		"Water plants", "", 5, lastCompleted.Format(time.RFC3339),
+	); err != nil {
+		t.Fatalf("insert init.sql task: %v", err)
+	}
+	if _, err := s.db.ExecContext(ctx,
+		`INSERT INTO completions (task_id, completed_at) VALUES (1, ?)`, completedAt.Format(time.RFC3339),
+	); err != nil {
+		t.Fatalf("insert init.sql completion: %v", err)
+	}
+
+	if _, err := s.Migrate(ctx); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	checkMigrated(t, s)
+
+	// The groups and the completions of init.sql survive the migrations
+	task, err := s.GetTask(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetTask: %v", err)
+	}
+	if task.GroupName != "Garden" || task.Completions != 1 || !task.LastCompleted.Equal(completedAt) {
+		t.Errorf("GetTask = %+v, want the init.sql task in Garden completed once", task)
+	}
+}
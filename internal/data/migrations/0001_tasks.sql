@@ -0,0 +1,9 @@
+-- Baseline schema. Databases created before migrations existed already have
+-- this table, hence IF NOT EXISTS.
+CREATE TABLE IF NOT EXISTS tasks (
+  id             INTEGER PRIMARY KEY AUTOINCREMENT,
+  name           TEXT NOT NULL,
+  description    TEXT NOT NULL,
+  period         INTEGER NOT NULL,              -- days
+  last_completed TEXT NOT NULL                  -- RFC3339 UTC, used until the first completion
+);
@@ -0,0 +1,9 @@
+CREATE TABLE completions (
+  id             INTEGER PRIMARY KEY AUTOINCREMENT,
+  task_id        INTEGER NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+  completed_at   TEXT NOT NULL,                 -- RFC3339 UTC
+  completed_by   TEXT NOT NULL DEFAULT '',
+  note           TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX completions_task_id ON completions(task_id, completed_at);
@@ -0,0 +1,2 @@
+ALTER TABLE tasks ADD COLUMN schedule TEXT NOT NULL DEFAULT 'floating'; -- floating or fixed
+ALTER TABLE tasks ADD COLUMN anchor TEXT NOT NULL DEFAULT '';          -- RFC3339 UTC, first due day of fixed tasks
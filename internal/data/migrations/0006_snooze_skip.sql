@@ -0,0 +1,2 @@
+ALTER TABLE tasks ADD COLUMN snoozed_until TEXT NOT NULL DEFAULT ''; -- RFC3339 UTC, hidden from the due lists until then
+ALTER TABLE tasks ADD COLUMN skipped_until TEXT NOT NULL DEFAULT ''; -- RFC3339 UTC, last skipped occurrence
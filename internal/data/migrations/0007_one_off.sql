@@ -0,0 +1 @@
+ALTER TABLE tasks ADD COLUMN due_date TEXT NOT NULL DEFAULT ''; -- RFC3339 UTC, set for one-off tasks only
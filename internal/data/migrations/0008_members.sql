@@ -0,0 +1,8 @@
+CREATE TABLE members (
+  id     INTEGER PRIMARY KEY AUTOINCREMENT,
+  name   TEXT NOT NULL UNIQUE,
+  email  TEXT NOT NULL DEFAULT ''
+);
+
+ALTER TABLE tasks ADD COLUMN assignee_id INTEGER REFERENCES members(id) ON DELETE SET NULL;     -- nullable
+ALTER TABLE completions ADD COLUMN member_id INTEGER REFERENCES members(id) ON DELETE SET NULL; -- nullable
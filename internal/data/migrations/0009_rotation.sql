@@ -0,0 +1,8 @@
+ALTER TABLE tasks ADD COLUMN rotation TEXT NOT NULL DEFAULT ''; -- '', round-robin or least-recent
+
+CREATE TABLE task_rotation (
+  task_id    INTEGER NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+  position   INTEGER NOT NULL,                -- order of the turns
+  member_id  INTEGER NOT NULL REFERENCES members(id) ON DELETE CASCADE,
+  PRIMARY KEY (task_id, position)
+);
@@ -0,0 +1,15 @@
+CREATE TABLE users (
+  id             INTEGER PRIMARY KEY AUTOINCREMENT,
+  username       TEXT NOT NULL UNIQUE,
+  password_hash  TEXT NOT NULL,                 -- bcrypt
+  created_at     TEXT NOT NULL                  -- RFC3339 UTC
+);
+
+CREATE TABLE sessions (
+  token_hash  TEXT PRIMARY KEY,                 -- hex SHA-256 of the cookie token
+  user_id     INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  created_at  TEXT NOT NULL,                    -- RFC3339 UTC
+  expires_at  TEXT NOT NULL                     -- RFC3339 UTC
+);
+
+CREATE INDEX sessions_expires_at ON sessions(expires_at);
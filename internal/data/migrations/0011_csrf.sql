@@ -0,0 +1,4 @@
+-- Sessions opened before CSRF tokens existed have none, so they are closed.
+DELETE FROM sessions;
+
+ALTER TABLE sessions ADD COLUMN csrf_token TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,11 @@
+CREATE TABLE api_tokens (
+  id            INTEGER PRIMARY KEY AUTOINCREMENT,
+  user_id       INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  name          TEXT NOT NULL,
+  token_hash    TEXT NOT NULL UNIQUE,           -- hex SHA-256 of the bearer token
+  scope         TEXT NOT NULL,                  -- name of a data.TokenScope, see models.go
+  created_at    TEXT NOT NULL,                  -- RFC3339 UTC
+  last_used_at  TEXT NOT NULL DEFAULT ''        -- RFC3339 UTC, '' if never used
+);
+
+CREATE INDEX api_tokens_user_id ON api_tokens(user_id);
@@ -1,20 +1,278 @@
 package data
 
-import "time"
+import (
+	"fmt"
+	"time"
+
+	"github.com/markor147/peverel/internal/recur"
+)
 
 type Task struct {
 	Id            TaskId
 	Name          string
 	Description   string
-	Period        int
+	Period        int    // days, used when Recurrence is empty
+	Recurrence    string // RRULE such as "FREQ=MONTHLY;BYDAY=1SA"
+	Schedule      Schedule
+	Anchor        time.Time // first due day of fixed tasks
+	DueDate       time.Time // due day of one-off tasks, zero for recurring tasks
 	LastCompleted time.Time
+	Completions   int       // number of recorded completions
+	SnoozedUntil  time.Time // the task is left out of the due lists before this day
+	SkippedUntil  time.Time // last occurrence skipped without completing the task
+	GroupId       GroupId   // NoGroup if the task is not assigned to any group
+	GroupName     string
+	AssigneeId    MemberId // NoMember if nobody is assigned to the task
+	AssigneeName  string
+	RotationMode  RotationMode
+	Rotation      []MemberId // members taking turns at the task, in order
 }
 
 type TaskId int
 
-/*type Group struct {
+// Schedule tells how the next due date of a task is computed.
+type Schedule string
+
+const (
+	// Floating tasks are due one recurrence after their last completion,
+	// so completing late pushes back every later occurrence.
+	Floating Schedule = "floating"
+	// Fixed tasks are due on the occurrences of their rule counted from the anchor,
+	// whenever they were last completed.
+	Fixed Schedule = "fixed"
+)
+
+// ParseSchedule parses a schedule mode. The empty string stands for Floating.
+func ParseSchedule(s string) (Schedule, error) {
+	switch Schedule(s) {
+	case "", Floating:
+		return Floating, nil
+	case Fixed:
+		return Fixed, nil
+	default:
+		return "", fmt.Errorf("invalid schedule %q", s)
+	}
+}
+
+// RotationMode tells how the assignee of a task changes at each completion.
+type RotationMode string
+
+const (
+	// NoRotation keeps the assignee unchanged.
+	NoRotation RotationMode = ""
+	// RoundRobin hands the task to the member following the assignee in the rotation.
+	RoundRobin RotationMode = "round-robin"
+	// LeastRecent hands the task to the member of the rotation who did it least recently.
+	LeastRecent RotationMode = "least-recent"
+)
+
+// ParseRotationMode parses a rotation mode. The empty string and "none" stand for NoRotation.
+func ParseRotationMode(s string) (RotationMode, error) {
+	switch RotationMode(s) {
+	case "", "none":
+		return NoRotation, nil
+	case RoundRobin:
+		return RoundRobin, nil
+	case LeastRecent:
+		return LeastRecent, nil
+	default:
+		return "", fmt.Errorf("invalid rotation mode %q", s)
+	}
+}
+
+// OneOff reports whether the task is done once by its due date rather than repeatedly.
+func (t Task) OneOff() bool {
+	return !t.DueDate.IsZero()
+}
+
+// Done reports whether the task is a one-off task that has been completed.
+func (t Task) Done() bool {
+	return t.OneOff() && t.Completions > 0
+}
+
+// Rule returns the recurrence rule of the task:
+// the parsed Recurrence, or every Period days if it is empty.
+func (t Task) Rule() (recur.Rule, error) {
+	if t.Recurrence == "" {
+		return recur.Days(t.Period), nil
+	}
+	rule, err := recur.Parse(t.Recurrence)
+	if err != nil {
+		return recur.Rule{}, fmt.Errorf("task %d: %w", t.Id, err)
+	}
+	return rule, nil
+}
+
+// NextDue returns the day the task is due next, at midnight UTC.
+// Floating tasks are due on the first occurrence of their rule after the last completion.
+// Fixed tasks are due on the first occurrence of their rule counted from the anchor
+// after the last completion, or on the anchor itself if they were not completed since.
+// Skipped occurrences count as completions for this purpose.
+// One-off tasks are due on their due date until they are completed or skipped.
+// It returns the zero time if the rule is invalid or never occurs again.
+func (t Task) NextDue() time.Time {
+	if t.OneOff() {
+		if t.Done() || !t.SkippedUntil.Before(t.DueDate) {
+			return time.Time{}
+		}
+		return t.DueDate
+	}
+
+	rule, err := t.Rule()
+	if err != nil {
+		return time.Time{}
+	}
+
+	after := t.LastCompleted
+	if t.SkippedUntil.After(after) {
+		after = t.SkippedUntil
+	}
+	if t.Schedule != Fixed || t.Anchor.IsZero() {
+		return rule.Next(t.LastCompleted, after)
+	}
+
+	if after.Before(t.Anchor) {
+		after = t.Anchor.AddDate(0, 0, -1)
+	}
+	return rule.Next(t.Anchor, after)
+}
+
+// Snoozed reports whether the task is snoozed today.
+func (t Task) Snoozed() bool {
+	return t.SnoozedUntil.After(Today())
+}
+
+// Frequency describes how often the task repeats, e.g. "every 3 days".
+func (t Task) Frequency() string {
+	if t.OneOff() {
+		return "once"
+	}
+	rule, err := t.Rule()
+	if err != nil {
+		return t.Recurrence
+	}
+	return rule.Describe()
+}
+
+// Today returns midnight UTC of the current day, the reference day of NextDue.
+func Today() time.Time {
+	return truncateDay(time.Now())
+}
+
+// truncateDay returns midnight UTC of the day of t in UTC.
+func truncateDay(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+type Group struct {
 	Id   GroupId
 	Name string
-}*/
+}
+
+type GroupId int
+
+const (
+	// AnyGroup matches every group in a TaskFilter.
+	AnyGroup GroupId = 0
+	// NoGroup is the GroupId of tasks not assigned to any group.
+	NoGroup GroupId = -1
+)
+
+// TaskHistory is a task together with its completion history, as moved by imports and exports.
+type TaskHistory struct {
+	Task        Task
+	Completions []Completion
+}
+
+// Import is what ImportTasks adds to the store in a single transaction:
+// the new groups and members, then the tasks that may refer to them.
+type Import struct {
+	Groups  []Group  // created before the tasks, their ids are ignored
+	Members []Member // created before the tasks, their ids are ignored
+	// Tasks returns the tasks to add given the ids of every group and member by name,
+	// those created by the import included.
+	Tasks func(groupIds map[string]GroupId, memberIds map[string]MemberId) []TaskHistory
+}
+
+// Member is a person of the household who can be assigned tasks.
+type Member struct {
+	Id    MemberId
+	Name  string
+	Email string // where the notifier sends the tasks assigned to the member, if set
+}
+
+type MemberId int
+
+const (
+	// AnyMember matches every assignee in a TaskFilter.
+	AnyMember MemberId = 0
+	// NoMember is the MemberId of tasks assigned to nobody and of completions by unknown people.
+	NoMember MemberId = -1
+)
+
+// Completion records a single time a task was done.
+type Completion struct {
+	Id       CompletionId
+	TaskId   TaskId
+	At       time.Time
+	MemberId MemberId // NoMember if the completion was not recorded by a member
+	By       string   // free text, replaced by the name of the member when MemberId is set
+	Note     string
+}
+
+type CompletionId int
+
+// User is an account allowed to sign in to the web UI.
+type User struct {
+	Id           UserId
+	Username     string
+	PasswordHash string // bcrypt
+	CreatedAt    time.Time
+}
+
+type UserId int
+
+// Session is a signed in browser. Only the hash of its cookie token is stored.
+type Session struct {
+	TokenHash string
+	UserId    UserId
+	CSRFToken string // sent back by the browser with every mutation
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
 
-// type GroupId int
+// APIToken is a long-lived token used by scripts to call the JSON API on behalf of a user.
+// Only its hash is stored.
+type APIToken struct {
+	Id         APITokenId
+	UserId     UserId
+	Name       string
+	TokenHash  string
+	Scope      TokenScope
+	CreatedAt  time.Time
+	LastUsedAt time.Time // zero if the token was never used
+}
+
+type APITokenId int
+
+// TokenScope tells what an API token is allowed to do.
+// The valid scopes are read, complete and calendar, the constants below;
+// the scope column of api_tokens stores their names.
+type TokenScope string
+
+const (
+	ScopeRead     TokenScope = "read"     // read the tasks and their history
+	ScopeComplete TokenScope = "complete" // read, and complete, skip or snooze tasks
+	ScopeCalendar TokenScope = "calendar" // read the calendar feed only, the one scope accepted in its URL
+)
+
+// ParseTokenScope parses the name of a token scope.
+func ParseTokenScope(s string) (TokenScope, error) {
+	switch TokenScope(s) {
+	case ScopeRead, ScopeComplete, ScopeCalendar:
+		return TokenScope(s), nil
+	default:
+		return "", fmt.Errorf("invalid token scope %q", s)
+	}
+}
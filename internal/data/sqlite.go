@@ -0,0 +1,1409 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/markor147/peverel/internal/log"
+	"github.com/mattn/go-sqlite3"
+)
+
+// lastCompletedExpr is the time of the latest completion of the task t,
+// falling back to the baseline stored in the tasks table.
+const lastCompletedExpr = `COALESCE((SELECT MAX(c.completed_at) FROM completions c WHERE c.task_id = t.id), t.last_completed)`
+
+// completionsExpr is the number of recorded completions of the task t.
+const completionsExpr = `(SELECT COUNT(*) FROM completions c WHERE c.task_id = t.id)`
+
+// rotationExpr is the comma separated list of the members in the rotation of the task t, in order.
+const rotationExpr = `(SELECT GROUP_CONCAT(member_id) FROM (SELECT r.member_id FROM task_rotation r WHERE r.task_id = t.id ORDER BY r.position))`
+
+// taskColumns is the projection scanned by scanTask.
+const taskColumns = `t.id, t.name, t.description, t.period, t.recurrence, t.schedule, t.anchor, t.due_date, ` + lastCompletedExpr + `, ` + completionsExpr + `, t.snoozed_until, t.skipped_until, t.group_id, COALESCE(g.name, ''), t.assignee_id, COALESCE(m.name, ''), t.rotation, COALESCE(` + rotationExpr + `, '')
+	FROM tasks t
+	LEFT JOIN groups g ON g.id = t.group_id
+	LEFT JOIN members m ON m.id = t.assignee_id`
+
+// userColumns is the projection scanned by scanUser.
+const userColumns = `id, username, password_hash, created_at
+	FROM users`
+
+// apiTokenColumns is the projection scanned by scanAPIToken.
+const apiTokenColumns = `id, user_id, name, token_hash, scope, created_at, last_used_at
+	FROM api_tokens`
+
+// SQLiteStore is the Store backed by a SQLite database.
+type SQLiteStore struct {
+	db           *sql.DB
+	queryTimeout time.Duration
+}
+
+// SQLiteOption configures a SQLiteStore.
+type SQLiteOption func(*SQLiteStore)
+
+// WithQueryTimeout bounds the duration of every query, on top of the deadline
+// of the context passed by the caller. A zero duration disables the bound.
+func WithQueryTimeout(d time.Duration) SQLiteOption {
+	return func(s *SQLiteStore) {
+		s.queryTimeout = d
+	}
+}
+
+// NewSQLiteStore opens or creates a SQLite DB file and applies the pending migrations.
+// Example connStr: "./tasks.db"
+func NewSQLiteStore(ctx context.Context, connStr string, opts ...SQLiteOption) (*SQLiteStore, error) {
+	s, err := OpenSQLiteStore(ctx, connStr, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := s.Migrate(ctx)
+	if err != nil {
+		_ = s.Close()
+		return nil, err
+	}
+	for _, m := range applied {
+		log.Logger.Infof("applied migration %04d_%s", m.Version, m.Name)
+	}
+	return s, nil
+}
+
+// OpenSQLiteStore opens or creates a SQLite DB file without touching its schema.
+func OpenSQLiteStore(ctx context.Context, connStr string, opts ...SQLiteOption) (*SQLiteStore, error) {
+	log.Logger.Debugf("opening database connection %q", connStr)
+
+	if connStr == "" {
+		return nil, errors.New("connStr is empty")
+	}
+
+	db, err := sql.Open("sqlite3", withPragmas(connStr))
+	if err != nil {
+		return nil, fmt.Errorf("open sqlite: %w", err)
+	}
+
+	// Enforce foreign keys and set busy timeout.
+	if _, err := db.ExecContext(ctx, `PRAGMA foreign_keys=ON; PRAGMA busy_timeout=5000;`); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("pragma: %w", err)
+	}
+
+	// Actually test the connection. sql.Open alone does not.
+	if err := db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("ping: %w", err)
+	}
+
+	s := &SQLiteStore{db: db}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s, nil
+}
+
+// Close closes the underlying database.
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// AddTask inserts a task and returns the new id.
+func (s *SQLiteStore) AddTask(ctx context.Context, task Task) (TaskId, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return -1, fmt.Errorf("function AddTask: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	task.SnoozedUntil = time.Time{}
+	task.SkippedUntil = time.Time{}
+	id, err := insertTask(ctx, tx, task)
+	if err != nil {
+		return -1, fmt.Errorf("function AddTask: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return -1, fmt.Errorf("function AddTask: %w", err)
+	}
+
+	return id, nil
+}
+
+// CompleteTask records a completion of the task by the member with the current timestamp
+// and wakes it up if it was snoozed, in a single transaction.
+func (s *SQLiteStore) CompleteTask(ctx context.Context, id TaskId, by MemberId) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("function CompleteTask: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := insertCompletion(ctx, tx, Completion{TaskId: id, At: time.Now(), MemberId: by}); err != nil {
+		return fmt.Errorf("function CompleteTask: task %d: %w", id, err)
+	}
+	res, err := tx.ExecContext(ctx, "UPDATE tasks SET snoozed_until='' WHERE id=?", id)
+	if err != nil {
+		return fmt.Errorf("function CompleteTask: %w", err)
+	}
+	if err := checkAffected(res, "function CompleteTask"); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("function CompleteTask: %w", err)
+	}
+	return nil
+}
+
+// SkipTask skips the next occurrence of the task without completing it, and wakes it up if it was snoozed.
+// The next due date is read and moved in a single transaction.
+func (s *SQLiteStore) SkipTask(ctx context.Context, id TaskId) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("function SkipTask: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	task, err := scanTask(tx.QueryRowContext(ctx, `SELECT `+taskColumns+` WHERE t.id=?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("function SkipTask: task %d: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("function SkipTask: %w", err)
+	}
+	due := task.NextDue()
+	if due.IsZero() {
+		return fmt.Errorf("function SkipTask: task %d is never due again: %w", id, ErrConflict)
+	}
+
+	res, err := tx.ExecContext(ctx,
+		`UPDATE tasks 
+		SET skipped_until=?, snoozed_until=''
+		WHERE id=?`,
+		formatTime(due), id,
+	)
+	if err != nil {
+		return fmt.Errorf("function SkipTask: %w", err)
+	}
+	if err := checkAffected(res, "function SkipTask"); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("function SkipTask: %w", err)
+	}
+	return nil
+}
+
+// SnoozeTask hides the task from the due lists until the given day. The zero time wakes the task up.
+func (s *SQLiteStore) SnoozeTask(ctx context.Context, id TaskId, until time.Time) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE tasks 
+		SET snoozed_until=?
+		WHERE id=?`,
+		formatTime(truncateDay(until)), id,
+	)
+	if err != nil {
+		return fmt.Errorf("function SnoozeTask: %w", err)
+	}
+	return checkAffected(res, "function SnoozeTask")
+}
+
+// GetTask retrieves a task by the specified id and returns a pointer to the parsed Task object.
+func (s *SQLiteStore) GetTask(ctx context.Context, id TaskId) (Task, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	task, err := scanTask(s.db.QueryRowContext(ctx, `SELECT `+taskColumns+` WHERE t.id=?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Task{}, fmt.Errorf("function GetTask: task %d: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return Task{}, fmt.Errorf("function GetTask: %w", err)
+	}
+	return task, nil
+}
+
+// DeleteTask deletes the task specified by the id.
+func (s *SQLiteStore) DeleteTask(ctx context.Context, id TaskId) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx,
+		`DELETE FROM tasks 
+		WHERE id=?`,
+		id,
+	)
+	if err != nil {
+		return fmt.Errorf("function DeleteTask: %w", err)
+	}
+	return checkAffected(res, "function DeleteTask")
+}
+
+// UpdateTask replace the task specified by the given id with the task provided by the given pointer.
+func (s *SQLiteStore) UpdateTask(ctx context.Context, id TaskId, task Task) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("function UpdateTask: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx,
+		`UPDATE tasks 
+		SET name=?, description=?, period=?, recurrence=?, schedule=?, anchor=?, due_date=?, group_id=?, assignee_id=?, rotation=?
+		WHERE id=?`,
+		task.Name, task.Description, task.Period, task.Recurrence,
+		scheduleOrDefault(task.Schedule), formatTime(task.Anchor), formatTime(task.DueDate),
+		nullGroup(task.GroupId), nullMember(task.AssigneeId), task.RotationMode,
+		id,
+	)
+	if err != nil {
+		return fmt.Errorf("function UpdateTask: %w", constraintError(err))
+	}
+	if err := checkAffected(res, "function UpdateTask"); err != nil {
+		return err
+	}
+
+	if err := replaceRotation(ctx, tx, id, task.Rotation); err != nil {
+		return fmt.Errorf("function UpdateTask: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("function UpdateTask: %w", err)
+	}
+	return nil
+}
+
+// Tasks returns all the tasks selected by the filter, sorted by next due date.
+func (s *SQLiteStore) Tasks(ctx context.Context, filter TaskFilter) ([]Task, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + taskColumns
+	conds := make([]string, 0)
+	args := make([]any, 0)
+
+	switch filter.Group {
+	case AnyGroup:
+	case NoGroup:
+		conds = append(conds, "t.group_id IS NULL")
+	default:
+		conds = append(conds, "t.group_id = ?")
+		args = append(args, filter.Group)
+	}
+
+	switch filter.Assignee {
+	case AnyMember:
+	case NoMember:
+		conds = append(conds, "t.assignee_id IS NULL")
+	default:
+		conds = append(conds, "t.assignee_id = ?")
+		args = append(args, filter.Assignee)
+	}
+
+	if len(conds) > 0 {
+		query += " WHERE " + joinAND(conds)
+	}
+
+	log.Logger.Debugf("function data.Tasks query: %v", query)
+	log.Logger.Debugf("function data.Tasks args: %v", args)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("sql error while getting filtered tasks: %w", err)
+	}
+	defer rows.Close()
+
+	tasks, err := scanTasks(rows)
+	if err != nil {
+		return nil, fmt.Errorf("sql error while getting filtered tasks: %w", err)
+	}
+
+	// Due dates depend on the recurrence rules, so they are filtered here rather than in SQL.
+	today := Today()
+	res := make([]Task, 0, len(tasks))
+	for _, task := range tasks {
+		if filter.matchesDue(task, today) {
+			res = append(res, task)
+		}
+	}
+	sortByNextDue(res)
+	return res, nil
+}
+
+// Completions returns the completion history of a task, latest first.
+func (s *SQLiteStore) Completions(ctx context.Context, id TaskId) ([]Completion, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT c.id, c.task_id, c.completed_at, c.member_id, COALESCE(m.name, c.completed_by), c.note
+		FROM completions c
+		LEFT JOIN members m ON m.id = c.member_id
+		WHERE c.task_id=?
+		ORDER BY c.completed_at DESC, c.id DESC`,
+		id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("function Completions: %w", err)
+	}
+	defer rows.Close()
+
+	res, err := scanCompletions(rows)
+	if err != nil {
+		return nil, fmt.Errorf("function Completions: %w", err)
+	}
+	return res, nil
+}
+
+// AddCompletion records a completion of a task and returns the new id.
+// The completion time can be in the past to record a forgotten completion.
+func (s *SQLiteStore) AddCompletion(ctx context.Context, c Completion) (CompletionId, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return -1, fmt.Errorf("function AddCompletion: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	id, err := insertCompletion(ctx, tx, c)
+	if err != nil {
+		return -1, fmt.Errorf("function AddCompletion: task %d: %w", c.TaskId, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return -1, fmt.Errorf("function AddCompletion: %w", err)
+	}
+
+	return id, nil
+}
+
+// ImportTasks adds the groups, the members and the tasks with their completion history in a single transaction,
+// after deleting every task if replace is set.
+func (s *SQLiteStore) ImportTasks(ctx context.Context, imp Import, replace bool) ([]TaskId, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("function ImportTasks: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, group := range imp.Groups {
+		if _, err := tx.ExecContext(ctx, `INSERT into groups (name) VALUES (?)`, group.Name); err != nil {
+			return nil, fmt.Errorf("function ImportTasks: group %q: %w", group.Name, constraintError(err))
+		}
+	}
+	for _, member := range imp.Members {
+		if _, err := tx.ExecContext(ctx, `INSERT into members (name, email) VALUES (?, ?)`, member.Name, member.Email); err != nil {
+			return nil, fmt.Errorf("function ImportTasks: member %q: %w", member.Name, constraintError(err))
+		}
+	}
+	groupIds := make(map[string]GroupId)
+	if err := scanIds(ctx, tx, `SELECT id, name FROM groups`, groupIds); err != nil {
+		return nil, fmt.Errorf("function ImportTasks: %w", err)
+	}
+	memberIds := make(map[string]MemberId)
+	if err := scanIds(ctx, tx, `SELECT id, name FROM members`, memberIds); err != nil {
+		return nil, fmt.Errorf("function ImportTasks: %w", err)
+	}
+	tasks := imp.Tasks(groupIds, memberIds)
+
+	if replace {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM tasks"); err != nil {
+			return nil, fmt.Errorf("function ImportTasks: %w", err)
+		}
+	}
+
+	ids := make([]TaskId, 0, len(tasks))
+	for _, th := range tasks {
+		id, err := insertTask(ctx, tx, th.Task)
+		if err != nil {
+			return nil, fmt.Errorf("function ImportTasks: task %q: %w", th.Task.Name, err)
+		}
+		for _, c := range th.Completions {
+			if _, err := tx.ExecContext(ctx,
+				`INSERT into completions (task_id, completed_at, member_id, completed_by, note)
+				VALUES (?, ?, ?, ?, ?)`,
+				id,
+				c.At.UTC().Format(time.RFC3339),
+				nullMember(c.MemberId),
+				c.By,
+				c.Note,
+			); err != nil {
+				return nil, fmt.Errorf("function ImportTasks: task %q: %w", th.Task.Name, constraintError(err))
+			}
+		}
+		ids = append(ids, id)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("function ImportTasks: %w", err)
+	}
+
+	return ids, nil
+}
+
+// BackdateCompletion moves the completion of the task specified by the id to the given time.
+func (s *SQLiteStore) BackdateCompletion(ctx context.Context, taskId TaskId, id CompletionId, at time.Time) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE completions
+		SET completed_at=?
+		WHERE id=? AND task_id=?`,
+		at.UTC().Format(time.RFC3339),
+		id,
+		taskId,
+	)
+	if err != nil {
+		return fmt.Errorf("function BackdateCompletion: %w", err)
+	}
+	return checkAffected(res, "function BackdateCompletion")
+}
+
+// DeleteCompletion deletes the completion of the task specified by the id,
+// e.g. to undo a task marked as completed by mistake.
+func (s *SQLiteStore) DeleteCompletion(ctx context.Context, taskId TaskId, id CompletionId) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx,
+		`DELETE FROM completions
+		WHERE id=? AND task_id=?`,
+		id,
+		taskId,
+	)
+	if err != nil {
+		return fmt.Errorf("function DeleteCompletion: %w", err)
+	}
+	return checkAffected(res, "function DeleteCompletion")
+}
+
+// AddGroup inserts a group and returns the new id.
+func (s *SQLiteStore) AddGroup(ctx context.Context, group Group) (GroupId, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx,
+		`INSERT into groups (name)
+		VALUES (?)`,
+		group.Name,
+	)
+	if err != nil {
+		return -1, fmt.Errorf("function AddGroup: %w", constraintError(err))
+	}
+
+	lid, err := res.LastInsertId()
+	if err != nil {
+		return -1, fmt.Errorf("function AddGroup: %w", err)
+	}
+
+	return GroupId(lid), nil
+}
+
+// GetGroup retrieves the group specified by the id.
+func (s *SQLiteStore) GetGroup(ctx context.Context, id GroupId) (Group, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var name string
+	err := s.db.QueryRowContext(ctx,
+		`SELECT name
+		FROM groups
+		WHERE id=?`,
+		id,
+	).Scan(&name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Group{}, fmt.Errorf("function GetGroup: group %d: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return Group{}, fmt.Errorf("function GetGroup: %w", err)
+	}
+
+	return Group{
+		Id:   id,
+		Name: name,
+	}, nil
+}
+
+// GetGroups returns all the groups sorted by name.
+func (s *SQLiteStore) GetGroups(ctx context.Context) ([]Group, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, "SELECT id, name FROM groups ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("function GetGroups: %w", err)
+	}
+	defer rows.Close()
+
+	res, err := scanGroups(rows)
+	if err != nil {
+		return nil, fmt.Errorf("function GetGroups: %w", err)
+	}
+	return res, nil
+}
+
+// UpdateGroup renames the group specified by the id.
+func (s *SQLiteStore) UpdateGroup(ctx context.Context, id GroupId, group Group) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE groups
+		SET name=?
+		WHERE id=?`,
+		group.Name,
+		id,
+	)
+	if err != nil {
+		return fmt.Errorf("function UpdateGroup: %w", constraintError(err))
+	}
+	return checkAffected(res, "function UpdateGroup")
+}
+
+// DeleteGroup deletes the group specified by the id.
+// The tasks assigned to it are left without a group.
+func (s *SQLiteStore) DeleteGroup(ctx context.Context, id GroupId) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx,
+		`DELETE FROM groups
+		WHERE id=?`,
+		id,
+	)
+	if err != nil {
+		return fmt.Errorf("function DeleteGroup: %w", err)
+	}
+	return checkAffected(res, "function DeleteGroup")
+}
+
+// SetRelation assign a list of tasks to the specified group.
+// Both the group and the tasks are specified by their ids.
+func (s *SQLiteStore) SetRelation(ctx context.Context, groupId GroupId, taskIds ...TaskId) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	for _, taskId := range taskIds {
+		res, err := s.db.ExecContext(ctx, "UPDATE tasks SET group_id=? WHERE id=?", nullGroup(groupId), taskId)
+		if err != nil {
+			return fmt.Errorf("assign group %d to task %d: %w", groupId, taskId, constraintError(err))
+		}
+		if err := checkAffected(res, fmt.Sprintf("assign group %d to task %d", groupId, taskId)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UnassignTask removes the assigned group from the specified task.
+// The task is specified by its id.
+func (s *SQLiteStore) UnassignTask(ctx context.Context, id TaskId) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE tasks
+		SET group_id=NULL
+		WHERE id=?`,
+		id,
+	)
+	if err != nil {
+		return fmt.Errorf("function UnassignTask: %w", err)
+	}
+	return checkAffected(res, "function UnassignTask")
+}
+
+// AddMember inserts a member and returns the new id.
+func (s *SQLiteStore) AddMember(ctx context.Context, member Member) (MemberId, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx,
+		`INSERT into members (name, email)
+		VALUES (?, ?)`,
+		member.Name,
+		member.Email,
+	)
+	if err != nil {
+		return -1, fmt.Errorf("function AddMember: %w", constraintError(err))
+	}
+
+	lid, err := res.LastInsertId()
+	if err != nil {
+		return -1, fmt.Errorf("function AddMember: %w", err)
+	}
+
+	return MemberId(lid), nil
+}
+
+// GetMember retrieves the member specified by the id.
+func (s *SQLiteStore) GetMember(ctx context.Context, id MemberId) (Member, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	member := Member{Id: id}
+	err := s.db.QueryRowContext(ctx,
+		`SELECT name, email
+		FROM members
+		WHERE id=?`,
+		id,
+	).Scan(&member.Name, &member.Email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Member{}, fmt.Errorf("function GetMember: member %d: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return Member{}, fmt.Errorf("function GetMember: %w", err)
+	}
+	return member, nil
+}
+
+// GetMembers returns all the members sorted by name.
+func (s *SQLiteStore) GetMembers(ctx context.Context) ([]Member, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, "SELECT id, name, email FROM members ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("function GetMembers: %w", err)
+	}
+	defer rows.Close()
+
+	res, err := scanMembers(rows)
+	if err != nil {
+		return nil, fmt.Errorf("function GetMembers: %w", err)
+	}
+	return res, nil
+}
+
+// UpdateMember replaces name and email of the member specified by the id.
+func (s *SQLiteStore) UpdateMember(ctx context.Context, id MemberId, member Member) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE members
+		SET name=?, email=?
+		WHERE id=?`,
+		member.Name,
+		member.Email,
+		id,
+	)
+	if err != nil {
+		return fmt.Errorf("function UpdateMember: %w", constraintError(err))
+	}
+	return checkAffected(res, "function UpdateMember")
+}
+
+// DeleteMember deletes the member specified by the id.
+// Their tasks are left unassigned and their completions anonymous.
+func (s *SQLiteStore) DeleteMember(ctx context.Context, id MemberId) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx,
+		`DELETE FROM members
+		WHERE id=?`,
+		id,
+	)
+	if err != nil {
+		return fmt.Errorf("function DeleteMember: %w", err)
+	}
+	return checkAffected(res, "function DeleteMember")
+}
+
+// AddUser inserts a user and returns the new id.
+func (s *SQLiteStore) AddUser(ctx context.Context, user User) (UserId, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx,
+		`INSERT into users (username, password_hash, created_at)
+		VALUES (?, ?, ?)`,
+		user.Username,
+		user.PasswordHash,
+		user.CreatedAt.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return -1, fmt.Errorf("function AddUser: %w", constraintError(err))
+	}
+
+	lid, err := res.LastInsertId()
+	if err != nil {
+		return -1, fmt.Errorf("function AddUser: %w", err)
+	}
+
+	return UserId(lid), nil
+}
+
+// GetUser retrieves the user specified by the id.
+func (s *SQLiteStore) GetUser(ctx context.Context, id UserId) (User, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	user, err := scanUser(s.db.QueryRowContext(ctx, `SELECT `+userColumns+` WHERE id=?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return User{}, fmt.Errorf("function GetUser: user %d: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return User{}, fmt.Errorf("function GetUser: %w", err)
+	}
+	return user, nil
+}
+
+// GetUserByName retrieves the user with the given username.
+func (s *SQLiteStore) GetUserByName(ctx context.Context, username string) (User, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	user, err := scanUser(s.db.QueryRowContext(ctx, `SELECT `+userColumns+` WHERE username=?`, username))
+	if errors.Is(err, sql.ErrNoRows) {
+		return User{}, fmt.Errorf("function GetUserByName: user %q: %w", username, ErrNotFound)
+	}
+	if err != nil {
+		return User{}, fmt.Errorf("function GetUserByName: %w", err)
+	}
+	return user, nil
+}
+
+// GetUsers returns all the users sorted by username.
+func (s *SQLiteStore) GetUsers(ctx context.Context) ([]User, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `SELECT `+userColumns+` ORDER BY username`)
+	if err != nil {
+		return nil, fmt.Errorf("function GetUsers: %w", err)
+	}
+	defer rows.Close()
+
+	res := make([]User, 0)
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("function GetUsers: %w", err)
+		}
+		res = append(res, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("function GetUsers: %w", err)
+	}
+	return res, nil
+}
+
+// UpdateUserPassword replaces the password hash of the user specified by the id.
+func (s *SQLiteStore) UpdateUserPassword(ctx context.Context, id UserId, passwordHash string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE users
+		SET password_hash=?
+		WHERE id=?`,
+		passwordHash,
+		id,
+	)
+	if err != nil {
+		return fmt.Errorf("function UpdateUserPassword: %w", err)
+	}
+	return checkAffected(res, "function UpdateUserPassword")
+}
+
+// DeleteUser deletes the user specified by the id, together with their sessions and API tokens.
+func (s *SQLiteStore) DeleteUser(ctx context.Context, id UserId) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx,
+		`DELETE FROM users
+		WHERE id=?`,
+		id,
+	)
+	if err != nil {
+		return fmt.Errorf("function DeleteUser: %w", err)
+	}
+	return checkAffected(res, "function DeleteUser")
+}
+
+// AddSession stores a new session.
+func (s *SQLiteStore) AddSession(ctx context.Context, session Session) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if _, err := s.db.ExecContext(ctx,
+		`INSERT into sessions (token_hash, user_id, csrf_token, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?)`,
+		session.TokenHash,
+		session.UserId,
+		session.CSRFToken,
+		session.CreatedAt.UTC().Format(time.RFC3339),
+		session.ExpiresAt.UTC().Format(time.RFC3339),
+	); err != nil {
+		return fmt.Errorf("function AddSession: %w", constraintError(err))
+	}
+	return nil
+}
+
+// GetSession retrieves the session with the given token hash, unless it has expired.
+func (s *SQLiteStore) GetSession(ctx context.Context, tokenHash string) (Session, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var (
+		session   = Session{TokenHash: tokenHash}
+		createdAt string
+		expiresAt string
+	)
+	err := s.db.QueryRowContext(ctx,
+		`SELECT user_id, csrf_token, created_at, expires_at
+		FROM sessions
+		WHERE token_hash=? AND expires_at > ?`,
+		tokenHash,
+		time.Now().UTC().Format(time.RFC3339),
+	).Scan(&session.UserId, &session.CSRFToken, &createdAt, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Session{}, fmt.Errorf("function GetSession: %w", ErrNotFound)
+	}
+	if err != nil {
+		return Session{}, fmt.Errorf("function GetSession: %w", err)
+	}
+	session.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	session.ExpiresAt, _ = time.Parse(time.RFC3339, expiresAt)
+	return session, nil
+}
+
+// DeleteSession deletes the session with the given token hash.
+func (s *SQLiteStore) DeleteSession(ctx context.Context, tokenHash string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx,
+		`DELETE FROM sessions
+		WHERE token_hash=?`,
+		tokenHash,
+	)
+	if err != nil {
+		return fmt.Errorf("function DeleteSession: %w", err)
+	}
+	return checkAffected(res, "function DeleteSession")
+}
+
+// DeleteExpiredSessions deletes the sessions expired before now.
+func (s *SQLiteStore) DeleteExpiredSessions(ctx context.Context, now time.Time) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if _, err := s.db.ExecContext(ctx,
+		`DELETE FROM sessions
+		WHERE expires_at <= ?`,
+		now.UTC().Format(time.RFC3339),
+	); err != nil {
+		return fmt.Errorf("function DeleteExpiredSessions: %w", err)
+	}
+	return nil
+}
+
+// AddAPIToken stores a new API token and returns its id.
+func (s *SQLiteStore) AddAPIToken(ctx context.Context, token APIToken) (APITokenId, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx,
+		`INSERT into api_tokens (user_id, name, token_hash, scope, created_at, last_used_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		token.UserId,
+		token.Name,
+		token.TokenHash,
+		token.Scope,
+		token.CreatedAt.UTC().Format(time.RFC3339),
+		formatTime(token.LastUsedAt),
+	)
+	if err != nil {
+		return -1, fmt.Errorf("function AddAPIToken: %w", constraintError(err))
+	}
+
+	lid, err := res.LastInsertId()
+	if err != nil {
+		return -1, fmt.Errorf("function AddAPIToken: %w", err)
+	}
+
+	return APITokenId(lid), nil
+}
+
+// GetAPIToken retrieves the API token with the given token hash.
+func (s *SQLiteStore) GetAPIToken(ctx context.Context, tokenHash string) (APIToken, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	token, err := scanAPIToken(s.db.QueryRowContext(ctx, `SELECT `+apiTokenColumns+` WHERE token_hash=?`, tokenHash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return APIToken{}, fmt.Errorf("function GetAPIToken: %w", ErrNotFound)
+	}
+	if err != nil {
+		return APIToken{}, fmt.Errorf("function GetAPIToken: %w", err)
+	}
+	return token, nil
+}
+
+// GetAPITokens returns the API tokens of the user, oldest first.
+func (s *SQLiteStore) GetAPITokens(ctx context.Context, userId UserId) ([]APIToken, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `SELECT `+apiTokenColumns+` WHERE user_id=? ORDER BY id`, userId)
+	if err != nil {
+		return nil, fmt.Errorf("function GetAPITokens: %w", err)
+	}
+	defer rows.Close()
+
+	res := make([]APIToken, 0)
+	for rows.Next() {
+		token, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, fmt.Errorf("function GetAPITokens: %w", err)
+		}
+		res = append(res, token)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("function GetAPITokens: %w", err)
+	}
+	return res, nil
+}
+
+// TouchAPIToken records the last use of the API token specified by the id.
+func (s *SQLiteStore) TouchAPIToken(ctx context.Context, id APITokenId, at time.Time) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE api_tokens
+		SET last_used_at=?
+		WHERE id=?`,
+		formatTime(at),
+		id,
+	)
+	if err != nil {
+		return fmt.Errorf("function TouchAPIToken: %w", err)
+	}
+	return checkAffected(res, "function TouchAPIToken")
+}
+
+// DeleteAPIToken revokes the API token specified by the id, if it belongs to the user.
+func (s *SQLiteStore) DeleteAPIToken(ctx context.Context, userId UserId, id APITokenId) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx,
+		`DELETE FROM api_tokens
+		WHERE id=? AND user_id=?`,
+		id,
+		userId,
+	)
+	if err != nil {
+		return fmt.Errorf("function DeleteAPIToken: %w", err)
+	}
+	return checkAffected(res, "function DeleteAPIToken")
+}
+
+// === Helpers ===
+
+// insertTask inserts a task with its rotation and returns the new id.
+func insertTask(ctx context.Context, tx *sql.Tx, task Task) (TaskId, error) {
+	res, err := tx.ExecContext(ctx,
+		`INSERT into tasks (name, description, period, recurrence, schedule, anchor, due_date, last_completed, snoozed_until, skipped_until, group_id, assignee_id, rotation)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		task.Name,
+		task.Description,
+		task.Period,
+		task.Recurrence,
+		scheduleOrDefault(task.Schedule),
+		formatTime(task.Anchor),
+		formatTime(task.DueDate),
+		task.LastCompleted.UTC().Format(time.RFC3339),
+		formatTime(task.SnoozedUntil),
+		formatTime(task.SkippedUntil),
+		nullGroup(task.GroupId),
+		nullMember(task.AssigneeId),
+		task.RotationMode,
+	)
+	if err != nil {
+		return -1, constraintError(err)
+	}
+
+	lid, err := res.LastInsertId()
+	if err != nil {
+		return -1, err
+	}
+
+	if err := replaceRotation(ctx, tx, TaskId(lid), task.Rotation); err != nil {
+		return -1, err
+	}
+	return TaskId(lid), nil
+}
+
+// replaceRotation replaces the members in the rotation of the task with the given ones, in order.
+func replaceRotation(ctx context.Context, tx *sql.Tx, id TaskId, rotation []MemberId) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM task_rotation WHERE task_id=?", id); err != nil {
+		return err
+	}
+	for pos, memberId := range rotation {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT into task_rotation (task_id, position, member_id)
+			VALUES (?, ?, ?)`,
+			id, pos, memberId,
+		); err != nil {
+			return constraintError(err)
+		}
+	}
+	return nil
+}
+
+// insertCompletion records a completion of a task and hands the task
+// to the next member of its rotation, if any.
+func insertCompletion(ctx context.Context, tx *sql.Tx, c Completion) (CompletionId, error) {
+	res, err := tx.ExecContext(ctx,
+		`INSERT into completions (task_id, completed_at, member_id, completed_by, note)
+		VALUES (?, ?, ?, ?, ?)`,
+		c.TaskId,
+		c.At.UTC().Format(time.RFC3339),
+		nullMember(c.MemberId),
+		c.By,
+		c.Note,
+	)
+	if err != nil {
+		return -1, constraintError(err)
+	}
+
+	lid, err := res.LastInsertId()
+	if err != nil {
+		return -1, err
+	}
+
+	if err := advanceRotation(ctx, tx, c.TaskId); err != nil {
+		return -1, err
+	}
+	return CompletionId(lid), nil
+}
+
+// advanceRotation hands the task to the next member of its rotation, if any.
+func advanceRotation(ctx context.Context, tx *sql.Tx, id TaskId) error {
+	var (
+		mode       RotationMode
+		assigneeId sql.NullInt64
+	)
+	if err := tx.QueryRowContext(ctx, "SELECT rotation, assignee_id FROM tasks WHERE id=?", id).Scan(&mode, &assigneeId); err != nil {
+		return err
+	}
+	if mode == NoRotation {
+		return nil
+	}
+	current := NoMember
+	if assigneeId.Valid {
+		current = MemberId(assigneeId.Int64)
+	}
+
+	rows, err := tx.QueryContext(ctx,
+		`SELECT r.member_id, COALESCE(MAX(c.completed_at), '')
+		FROM task_rotation r
+		LEFT JOIN completions c ON c.task_id = r.task_id AND c.member_id = r.member_id
+		WHERE r.task_id=?
+		GROUP BY r.position, r.member_id
+		ORDER BY r.position`,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	rotation := make([]MemberId, 0)
+	lastDone := make(map[MemberId]time.Time)
+	for rows.Next() {
+		var (
+			memberId MemberId
+			at       string
+		)
+		if err := rows.Scan(&memberId, &at); err != nil {
+			return err
+		}
+		rotation = append(rotation, memberId)
+		lastDone[memberId], _ = time.Parse(time.RFC3339, at)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	next := nextAssignee(mode, rotation, current, lastDone)
+	if next == current {
+		return nil
+	}
+	_, err = tx.ExecContext(ctx, "UPDATE tasks SET assignee_id=? WHERE id=?", nullMember(next), id)
+	return err
+}
+
+// withTimeout derives the context of a single query.
+func (s *SQLiteStore) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, s.queryTimeout)
+}
+
+// withPragmas adds the foreign keys and busy timeout settings to the connection string.
+// A PRAGMA executed through db.Exec only affects one of the pooled connections,
+// while the driver applies these parameters to every connection it opens.
+func withPragmas(connStr string) string {
+	sep := "?"
+	if strings.Contains(connStr, "?") {
+		sep = "&"
+	}
+	return connStr + sep + "_foreign_keys=on&_busy_timeout=5000"
+}
+
+// checkAffected returns ErrNotFound if the statement did not touch any row.
+func checkAffected(res sql.Result, scope string) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", scope, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: %w", scope, ErrNotFound)
+	}
+	return nil
+}
+
+// constraintError wraps UNIQUE constraint violations into ErrConflict
+// and FOREIGN KEY constraint violations into ErrNotFound.
+func constraintError(err error) error {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return err
+	}
+	switch sqliteErr.ExtendedCode {
+	case sqlite3.ErrConstraintUnique:
+		return fmt.Errorf("%w: %v", ErrConflict, err)
+	case sqlite3.ErrConstraintForeignKey:
+		return fmt.Errorf("%w: %v", ErrNotFound, err)
+	default:
+		return err
+	}
+}
+
+// scanIds adds to ids the rows of the query, which selects an id and a name.
+func scanIds[Id ~int](ctx context.Context, tx *sql.Tx, query string, ids map[string]Id) error {
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id   Id
+			name string
+		)
+		if err := rows.Scan(&id, &name); err != nil {
+			return err
+		}
+		ids[name] = id
+	}
+	return rows.Err()
+}
+
+// nullGroup maps NoGroup to a NULL group_id.
+func nullGroup(id GroupId) any {
+	if id == NoGroup || id == AnyGroup {
+		return nil
+	}
+	return id
+}
+
+// nullMember maps NoMember to a NULL member id.
+func nullMember(id MemberId) any {
+	if id == NoMember || id == AnyMember {
+		return nil
+	}
+	return id
+}
+
+// scheduleOrDefault maps the zero Schedule to Floating.
+func scheduleOrDefault(s Schedule) Schedule {
+	if s == "" {
+		return Floating
+	}
+	return s
+}
+
+// formatTime formats t as RFC3339 UTC, or as the empty string if t is zero.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanTask(row rowScanner) (Task, error) {
+	var (
+		task          Task
+		anchor        string
+		dueDate       string
+		lastCompleted string
+		snoozedUntil  string
+		skippedUntil  string
+		groupId       sql.NullInt64
+		assigneeId    sql.NullInt64
+		rotation      string
+	)
+	if err := row.Scan(&task.Id, &task.Name, &task.Description, &task.Period, &task.Recurrence, &task.Schedule, &anchor, &dueDate,
+		&lastCompleted, &task.Completions, &snoozedUntil, &skippedUntil, &groupId, &task.GroupName, &assigneeId, &task.AssigneeName,
+		&task.RotationMode, &rotation); err != nil {
+		return Task{}, err
+	}
+	task.Anchor, _ = time.Parse(time.RFC3339, anchor)
+	task.DueDate, _ = time.Parse(time.RFC3339, dueDate)
+	task.LastCompleted, _ = time.Parse(time.RFC3339, lastCompleted)
+	task.SnoozedUntil, _ = time.Parse(time.RFC3339, snoozedUntil)
+	task.SkippedUntil, _ = time.Parse(time.RFC3339, skippedUntil)
+	task.GroupId = NoGroup
+	if groupId.Valid {
+		task.GroupId = GroupId(groupId.Int64)
+	}
+	task.AssigneeId = NoMember
+	if assigneeId.Valid {
+		task.AssigneeId = MemberId(assigneeId.Int64)
+	}
+	task.Rotation = parseRotation(rotation)
+	return task, nil
+}
+
+// parseRotation parses the comma separated member ids selected by rotationExpr.
+func parseRotation(s string) []MemberId {
+	res := make([]MemberId, 0)
+	for _, idStr := range strings.Split(s, ",") {
+		if id, err := strconv.Atoi(idStr); err == nil {
+			res = append(res, MemberId(id))
+		}
+	}
+	return res
+}
+
+func scanTasks(rows *sql.Rows) ([]Task, error) {
+	res := make([]Task, 0)
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, task)
+	}
+	return res, rows.Err()
+}
+
+func scanGroups(rows *sql.Rows) ([]Group, error) {
+	res := make([]Group, 0)
+	for rows.Next() {
+		var id GroupId
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, err
+		}
+		res = append(res, Group{
+			Id:   id,
+			Name: name,
+		})
+	}
+	return res, rows.Err()
+}
+
+func scanUser(row rowScanner) (User, error) {
+	var (
+		user      User
+		createdAt string
+	)
+	if err := row.Scan(&user.Id, &user.Username, &user.PasswordHash, &createdAt); err != nil {
+		return User{}, err
+	}
+	user.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	return user, nil
+}
+
+func scanAPIToken(row rowScanner) (APIToken, error) {
+	var (
+		token      APIToken
+		createdAt  string
+		lastUsedAt string
+	)
+	if err := row.Scan(&token.Id, &token.UserId, &token.Name, &token.TokenHash, &token.Scope, &createdAt, &lastUsedAt); err != nil {
+		return APIToken{}, err
+	}
+	token.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	token.LastUsedAt, _ = time.Parse(time.RFC3339, lastUsedAt)
+	return token, nil
+}
+
+func scanMembers(rows *sql.Rows) ([]Member, error) {
+	res := make([]Member, 0)
+	for rows.Next() {
+		var m Member
+		if err := rows.Scan(&m.Id, &m.Name, &m.Email); err != nil {
+			return nil, err
+		}
+		res = append(res, m)
+	}
+	return res, rows.Err()
+}
+
+func scanCompletions(rows *sql.Rows) ([]Completion, error) {
+	res := make([]Completion, 0)
+	for rows.Next() {
+		var (
+			c        Completion
+			at       string
+			memberId sql.NullInt64
+		)
+		if err := rows.Scan(&c.Id, &c.TaskId, &at, &memberId, &c.By, &c.Note); err != nil {
+			return nil, err
+		}
+		c.At, _ = time.Parse(time.RFC3339, at)
+		c.MemberId = NoMember
+		if memberId.Valid {
+			c.MemberId = MemberId(memberId.Int64)
+		}
+		res = append(res, c)
+	}
+	return res, rows.Err()
+}
+
+func joinAND(xs []string) string {
+	if len(xs) == 0 {
+		return ""
+	}
+	out := xs[0]
+	for i := 1; i < len(xs); i++ {
+		out += " AND " + xs[i]
+	}
+	return out
+}
@@ -0,0 +1,207 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+)
+
+// ErrNotFound is returned when the requested record does not exist.
+var ErrNotFound = errors.New("not found")
+
+// ErrConflict is returned when a record clashes with an existing one,
+// e.g. a group with an already used name.
+var ErrConflict = errors.New("conflict")
+
+// Store persists tasks, their completions and their groups.
+// SQLiteStore is the implementation used by the binaries,
+// MemStore keeps everything in memory.
+type Store interface {
+	// AddTask inserts a task and returns the new id.
+	AddTask(ctx context.Context, task Task) (TaskId, error)
+	// GetTask retrieves a task by the specified id.
+	GetTask(ctx context.Context, id TaskId) (Task, error)
+	// UpdateTask replaces name, description, schedule, due date, group, assignee and rotation of the task specified by the id.
+	UpdateTask(ctx context.Context, id TaskId, task Task) error
+	// DeleteTask deletes the task specified by the id, together with its completions.
+	DeleteTask(ctx context.Context, id TaskId) error
+	// CompleteTask records a completion of the task by the member with the current timestamp
+	// and wakes it up if it was snoozed. The member can be NoMember.
+	CompleteTask(ctx context.Context, id TaskId, by MemberId) error
+	// SkipTask skips the next occurrence of the task specified by the id without completing it.
+	// It returns ErrConflict if the task is never due again.
+	SkipTask(ctx context.Context, id TaskId) error
+	// SnoozeTask hides the task specified by the id from the due lists until the given day.
+	// The zero time wakes the task up.
+	SnoozeTask(ctx context.Context, id TaskId, until time.Time) error
+	// Tasks returns all the tasks selected by the filter, sorted by next due date.
+	// The tasks never due again come last.
+	Tasks(ctx context.Context, filter TaskFilter) ([]Task, error)
+
+	// Completions returns the completion history of a task, latest first.
+	Completions(ctx context.Context, id TaskId) ([]Completion, error)
+	// AddCompletion records a completion of a task, possibly in the past, and returns the new id.
+	// The task is handed to the next member of its rotation, if any.
+	AddCompletion(ctx context.Context, c Completion) (CompletionId, error)
+	// ImportTasks adds the groups, the members and the tasks of the import, with their completion
+	// history, in a single transaction and returns the ids of the tasks, in order.
+	// With replace, every existing task is deleted first.
+	// Unlike AddTask and AddCompletion, it keeps the snooze and skip days of the tasks,
+	// and the completions do not advance the rotations.
+	ImportTasks(ctx context.Context, imp Import, replace bool) ([]TaskId, error)
+	// BackdateCompletion moves the completion specified by the id to the given time.
+	// It returns ErrNotFound unless the completion belongs to the task.
+	BackdateCompletion(ctx context.Context, taskId TaskId, id CompletionId, at time.Time) error
+	// DeleteCompletion deletes the completion specified by the id.
+	// It returns ErrNotFound unless the completion belongs to the task.
+	DeleteCompletion(ctx context.Context, taskId TaskId, id CompletionId) error
+
+	// AddGroup inserts a group and returns the new id.
+	AddGroup(ctx context.Context, group Group) (GroupId, error)
+	// GetGroup retrieves the group specified by the id.
+	GetGroup(ctx context.Context, id GroupId) (Group, error)
+	// GetGroups returns all the groups sorted by name.
+	GetGroups(ctx context.Context) ([]Group, error)
+	// UpdateGroup renames the group specified by the id.
+	UpdateGroup(ctx context.Context, id GroupId, group Group) error
+	// DeleteGroup deletes the group specified by the id, leaving its tasks without a group.
+	DeleteGroup(ctx context.Context, id GroupId) error
+	// SetRelation assigns a list of tasks to the specified group.
+	SetRelation(ctx context.Context, groupId GroupId, taskIds ...TaskId) error
+	// UnassignTask removes the assigned group from the specified task.
+	UnassignTask(ctx context.Context, id TaskId) error
+
+	// AddMember inserts a member and returns the new id.
+	AddMember(ctx context.Context, member Member) (MemberId, error)
+	// GetMember retrieves the member specified by the id.
+	GetMember(ctx context.Context, id MemberId) (Member, error)
+	// GetMembers returns all the members sorted by name.
+	GetMembers(ctx context.Context) ([]Member, error)
+	// UpdateMember replaces name and email of the member specified by the id.
+	UpdateMember(ctx context.Context, id MemberId, member Member) error
+	// DeleteMember deletes the member specified by the id, leaving their tasks unassigned.
+	DeleteMember(ctx context.Context, id MemberId) error
+
+	// AddUser inserts a user and returns the new id.
+	AddUser(ctx context.Context, user User) (UserId, error)
+	// GetUser retrieves the user specified by the id.
+	GetUser(ctx context.Context, id UserId) (User, error)
+	// GetUserByName retrieves the user with the given username.
+	GetUserByName(ctx context.Context, username string) (User, error)
+	// GetUsers returns all the users sorted by username.
+	GetUsers(ctx context.Context) ([]User, error)
+	// UpdateUserPassword replaces the password hash of the user specified by the id.
+	UpdateUserPassword(ctx context.Context, id UserId, passwordHash string) error
+	// DeleteUser deletes the user specified by the id, together with their sessions and API tokens.
+	DeleteUser(ctx context.Context, id UserId) error
+
+	// AddSession stores a new session.
+	AddSession(ctx context.Context, session Session) error
+	// GetSession retrieves the session with the given token hash.
+	// It returns ErrNotFound if the session does not exist or has expired.
+	GetSession(ctx context.Context, tokenHash string) (Session, error)
+	// DeleteSession deletes the session with the given token hash.
+	DeleteSession(ctx context.Context, tokenHash string) error
+	// DeleteExpiredSessions deletes the sessions expired before now.
+	DeleteExpiredSessions(ctx context.Context, now time.Time) error
+
+	// AddAPIToken stores a new API token and returns its id.
+	AddAPIToken(ctx context.Context, token APIToken) (APITokenId, error)
+	// GetAPIToken retrieves the API token with the given token hash.
+	GetAPIToken(ctx context.Context, tokenHash string) (APIToken, error)
+	// GetAPITokens returns the API tokens of the user, oldest first.
+	GetAPITokens(ctx context.Context, userId UserId) ([]APIToken, error)
+	// TouchAPIToken records the last use of the API token specified by the id.
+	TouchAPIToken(ctx context.Context, id APITokenId, at time.Time) error
+	// DeleteAPIToken revokes the API token specified by the id.
+	// It returns ErrNotFound if the token does not belong to the user.
+	DeleteAPIToken(ctx context.Context, userId UserId, id APITokenId) error
+
+	// Close releases the resources held by the store.
+	Close() error
+}
+
+// TaskFilter selects the tasks returned by Store.Tasks.
+// The zero value selects every active task, that is every task but the completed one-off tasks.
+type TaskFilter struct {
+	Group    GroupId  // AnyGroup matches every task, NoGroup the tasks without a group
+	Assignee MemberId // AnyMember matches every task, NoMember the unassigned tasks
+	Days     *int     // if set, only the tasks due within Days days from today and not snoozed
+	Expired  bool     // with Days, whether to include the tasks already due
+	Done     bool     // whether to include the completed one-off tasks as well
+}
+
+// matchesDue reports whether the task passes the Done, Days and Expired filters.
+func (f TaskFilter) matchesDue(task Task, today time.Time) bool {
+	if task.Done() && !f.Done {
+		return false
+	}
+	if f.Days == nil {
+		return true
+	}
+	if task.SnoozedUntil.After(today) {
+		return false
+	}
+	due := task.NextDue()
+	if due.IsZero() {
+		// never due again
+		return false
+	}
+	if due.After(today.AddDate(0, 0, *f.Days)) {
+		return false
+	}
+	return f.Expired || due.After(today)
+}
+
+// nextAssignee returns who takes the task after a completion, according to the rotation mode.
+// lastDone holds the time of the latest completion of the task by each member.
+// It returns current if the task does not rotate.
+func nextAssignee(mode RotationMode, rotation []MemberId, current MemberId, lastDone map[MemberId]time.Time) MemberId {
+	if len(rotation) == 0 {
+		return current
+	}
+	switch mode {
+	case RoundRobin:
+		for i, id := range rotation {
+			if id == current {
+				return rotation[(i+1)%len(rotation)]
+			}
+		}
+		return rotation[0]
+	case LeastRecent:
+		// Members who never did the task come first, ties are broken by the rotation order
+		next := rotation[0]
+		for _, id := range rotation[1:] {
+			if lastDone[id].Before(lastDone[next]) {
+				next = id
+			}
+		}
+		return next
+	default:
+		return current
+	}
+}
+
+// sortByNextDue sorts the tasks by next due date, then by id.
+func sortByNextDue(tasks []Task) {
+	due := make(map[TaskId]time.Time, len(tasks))
+	for _, t := range tasks {
+		due[t.Id] = t.NextDue()
+	}
+	sort.SliceStable(tasks, func(i, j int) bool {
+		di, dj := due[tasks[i].Id], due[tasks[j].Id]
+		if di.IsZero() != dj.IsZero() {
+			return dj.IsZero()
+		}
+		if !di.Equal(dj) {
+			return di.Before(dj)
+		}
+		return tasks[i].Id < tasks[j].Id
+	})
+}
+
+var (
+	_ Store = (*SQLiteStore)(nil)
+	_ Store = (*MemStore)(nil)
+)
@@ -0,0 +1,341 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// forEachStore runs the test against a fresh MemStore and a fresh in-memory SQLiteStore.
+func forEachStore(t *testing.T, test func(t *testing.T, s Store)) {
+	t.Run("MemStore", func(t *testing.T) {
+		test(t, NewMemStore())
+	})
+	t.Run("SQLiteStore", func(t *testing.T) {
+		s := openMemorySQLiteStore(t)
+		if _, err := s.Migrate(context.Background()); err != nil {
+			t.Fatalf("Migrate: %v", err)
+		}
+		test(t, s)
+	})
+}
+
+func TestCompleteTaskWakesUp(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		id, err := s.AddTask(ctx, Task{Name: "Dishes", Period: 3, LastCompleted: time.Now().AddDate(0, 0, -5)})
+		if err != nil {
+			t.Fatalf("AddTask: %v", err)
+		}
+		if err := s.SnoozeTask(ctx, id, Today().AddDate(0, 0, 2)); err != nil {
+			t.Fatalf("SnoozeTask: %v", err)
+		}
+
+		if err := s.CompleteTask(ctx, id, NoMember); err != nil {
+			t.Fatalf("CompleteTask: %v", err)
+		}
+		task, err := s.GetTask(ctx, id)
+		if err != nil {
+			t.Fatalf("GetTask: %v", err)
+		}
+		if task.Completions != 1 || !task.SnoozedUntil.IsZero() {
+			t.Errorf("after CompleteTask: %d completions, snoozed until %v, want 1 and awake", task.Completions, task.SnoozedUntil)
+		}
+
+		if err := s.CompleteTask(ctx, id+100, NoMember); !errors.Is(err, ErrNotFound) {
+			t.Errorf("CompleteTask of a missing task = %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func TestSkipTask(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		id, err := s.AddTask(ctx, Task{Name: "Water plants", Period: 5, LastCompleted: Today()})
+		if err != nil {
+			t.Fatalf("AddTask: %v", err)
+		}
+		if err := s.SkipTask(ctx, id); err != nil {
+			t.Fatalf("SkipTask: %v", err)
+		}
+		task, err := s.GetTask(ctx, id)
+		if err != nil {
+			t.Fatalf("GetTask: %v", err)
+		}
+		if want := Today().AddDate(0, 0, 10); !task.NextDue().Equal(want) {
+			t.Errorf("after SkipTask: next due %v, want %v", task.NextDue(), want)
+		}
+
+		oneOff, err := s.AddTask(ctx, Task{Name: "Renew passport", DueDate: Today()})
+		if err != nil {
+			t.Fatalf("AddTask: %v", err)
+		}
+		if err := s.SkipTask(ctx, oneOff); err != nil {
+			t.Fatalf("SkipTask: %v", err)
+		}
+		if err := s.SkipTask(ctx, oneOff); !errors.Is(err, ErrConflict) {
+			t.Errorf("SkipTask of a task never due again = %v, want ErrConflict", err)
+		}
+		if err := s.SkipTask(ctx, id+100); !errors.Is(err, ErrNotFound) {
+			t.Errorf("SkipTask of a missing task = %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func TestNextAssignee(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name     string
+		mode     RotationMode
+		rotation []MemberId
+		current  MemberId
+		lastDone map[MemberId]time.Time
+		want     MemberId
+	}{
+		{"no rotation", NoRotation, []MemberId{1, 2}, 1, nil, 1},
+		{"empty rotation", RoundRobin, nil, 3, nil, 3},
+		{"round-robin", RoundRobin, []MemberId{1, 2, 3}, 1, nil, 2},
+		{"round-robin wraps", RoundRobin, []MemberId{1, 2, 3}, 3, nil, 1},
+		{"round-robin from outside", RoundRobin, []MemberId{1, 2, 3}, 4, nil, 1},
+		{"round-robin from nobody", RoundRobin, []MemberId{1, 2, 3}, NoMember, nil, 1},
+		{"least-recent never done first", LeastRecent, []MemberId{1, 2, 3}, 1,
+			map[MemberId]time.Time{1: now}, 2},
+		{"least-recent ties by rotation order", LeastRecent, []MemberId{3, 2, 1}, 1, nil, 3},
+		{"least-recent oldest", LeastRecent, []MemberId{1, 2, 3}, 1,
+			map[MemberId]time.Time{1: now, 2: now.Add(-time.Hour), 3: now.Add(-2 * time.Hour)}, 3},
+		{"least-recent from outside", LeastRecent, []MemberId{1, 2}, 4,
+			map[MemberId]time.Time{1: now, 2: now.Add(-time.Hour)}, 2},
+	}
+	for _, tt := range tests {
+		if got := nextAssignee(tt.mode, tt.rotation, tt.current, tt.lastDone); got != tt.want {
+			t.Errorf("%s: nextAssignee = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+// addMembers adds the members with the names and returns their ids, in order.
+func addMembers(t *testing.T, s Store, names ...string) []MemberId {
+	t.Helper()
+	ids := make([]MemberId, 0, len(names))
+	for _, name := range names {
+		id, err := s.AddMember(context.Background(), Member{Name: name})
+		if err != nil {
+			t.Fatalf("AddMember %q: %v", name, err)
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// checkAssignee checks the assignee of the task.
+func checkAssignee(t *testing.T, s Store, id TaskId, want MemberId, step string) {
+	t.Helper()
+	task, err := s.GetTask(context.Background(), id)
+	if err != nil {
+		t.Fatalf("GetTask: %v", err)
+	}
+	if task.AssigneeId != want {
+		t.Errorf("%s: assignee %d, want %d", step, task.AssigneeId, want)
+	}
+}
+
+func TestRoundRobinRotation(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		m := addMembers(t, s, "Ann", "Bob", "Cat", "Dan")
+		id, err := s.AddTask(ctx, Task{
+			Name: "Bins", Period: 7, LastCompleted: time.Now(),
+			AssigneeId: m[0], RotationMode: RoundRobin, Rotation: m[:3],
+		})
+		if err != nil {
+			t.Fatalf("AddTask: %v", err)
+		}
+
+		if err := s.CompleteTask(ctx, id, m[0]); err != nil {
+			t.Fatalf("CompleteTask: %v", err)
+		}
+		checkAssignee(t, s, id, m[1], "first completion")
+		// The turn passes whoever completes the task
+		if err := s.CompleteTask(ctx, id, NoMember); err != nil {
+			t.Fatalf("CompleteTask: %v", err)
+		}
+		checkAssignee(t, s, id, m[2], "second completion")
+		if err := s.CompleteTask(ctx, id, m[2]); err != nil {
+			t.Fatalf("CompleteTask: %v", err)
+		}
+		checkAssignee(t, s, id, m[0], "wrap around")
+
+		// An assignee out of the rotation hands the task to the first member
+		task, err := s.GetTask(ctx, id)
+		if err != nil {
+			t.Fatalf("GetTask: %v", err)
+		}
+		task.AssigneeId = m[3]
+		if err := s.UpdateTask(ctx, id, task); err != nil {
+			t.Fatalf("UpdateTask: %v", err)
+		}
+		if err := s.CompleteTask(ctx, id, m[3]); err != nil {
+			t.Fatalf("CompleteTask: %v", err)
+		}
+		checkAssignee(t, s, id, m[0], "assignee out of the rotation")
+	})
+}
+
+func TestLeastRecentRotation(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		m := addMembers(t, s, "Ann", "Bob", "Cat")
+		id, err := s.AddTask(ctx, Task{
+			Name: "Vacuum", Period: 7, LastCompleted: time.Now(),
+			AssigneeId: m[0], RotationMode: LeastRecent, Rotation: m,
+		})
+		if err != nil {
+			t.Fatalf("AddTask: %v", err)
+		}
+
+		day := time.Now().Truncate(time.Second).AddDate(0, 0, -10)
+		complete := func(by MemberId, daysLater int) {
+			t.Helper()
+			if _, err := s.AddCompletion(ctx, Completion{TaskId: id, At: day.AddDate(0, 0, daysLater), MemberId: by}); err != nil {
+				t.Fatalf("AddCompletion: %v", err)
+			}
+		}
+
+		// Bob and Cat never did it, Bob comes first in the rotation
+		complete(m[0], 0)
+		checkAssignee(t, s, id, m[1], "members who never did the task")
+		complete(m[1], 1)
+		checkAssignee(t, s, id, m[2], "last member who never did the task")
+		complete(m[2], 2)
+		checkAssignee(t, s, id, m[0], "least recent member")
+		// A completion by somebody else does not change who did it least recently
+		complete(NoMember, 3)
+		checkAssignee(t, s, id, m[0], "completion by nobody")
+		complete(m[0], 4)
+		checkAssignee(t, s, id, m[1], "after a new completion")
+	})
+}
+
+func TestCompletionOfAnotherTask(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		dishes, err := s.AddTask(ctx, Task{Name: "Dishes", Period: 3})
+		if err != nil {
+			t.Fatalf("AddTask: %v", err)
+		}
+		bins, err := s.AddTask(ctx, Task{Name: "Bins", Period: 7})
+		if err != nil {
+			t.Fatalf("AddTask: %v", err)
+		}
+		at := time.Now().AddDate(0, 0, -1)
+		id, err := s.AddCompletion(ctx, Completion{TaskId: dishes, At: at, MemberId: NoMember})
+		if err != nil {
+			t.Fatalf("AddCompletion: %v", err)
+		}
+
+		if err := s.BackdateCompletion(ctx, bins, id, at.AddDate(0, 0, -1)); !errors.Is(err, ErrNotFound) {
+			t.Errorf("BackdateCompletion through another task = %v, want ErrNotFound", err)
+		}
+		if err := s.DeleteCompletion(ctx, bins, id); !errors.Is(err, ErrNotFound) {
+			t.Errorf("DeleteCompletion through another task = %v, want ErrNotFound", err)
+		}
+		completions, err := s.Completions(ctx, dishes)
+		if err != nil {
+			t.Fatalf("Completions: %v", err)
+		}
+		if len(completions) != 1 || !completions[0].At.Equal(at.Truncate(time.Second)) {
+			t.Errorf("completions of the task = %+v, want the one at %v untouched", completions, at)
+		}
+
+		if err := s.BackdateCompletion(ctx, dishes, id, at.AddDate(0, 0, -1)); err != nil {
+			t.Errorf("BackdateCompletion: %v", err)
+		}
+		if err := s.DeleteCompletion(ctx, dishes, id); err != nil {
+			t.Errorf("DeleteCompletion: %v", err)
+		}
+	})
+}
+
+func TestImportTasks(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		annId, err := s.AddMember(ctx, Member{Name: "Ann"})
+		if err != nil {
+			t.Fatalf("AddMember: %v", err)
+		}
+
+		imp := Import{
+			Groups:  []Group{{Name: "Kitchen"}},
+			Members: []Member{{Name: "Bob", Email: "bob@example.com"}},
+			Tasks: func(groupIds map[string]GroupId, memberIds map[string]MemberId) []TaskHistory {
+				if memberIds["Ann"] != annId {
+					t.Errorf("memberIds[Ann] = %d, want the existing member %d", memberIds["Ann"], annId)
+				}
+				return []TaskHistory{{
+					Task: Task{
+						Name: "Dishes", Period: 3, LastCompleted: Today(),
+						GroupId: groupIds["Kitchen"], AssigneeId: memberIds["Bob"],
+						RotationMode: RoundRobin, Rotation: []MemberId{memberIds["Bob"], memberIds["Ann"]},
+					},
+					Completions: []Completion{{At: Today(), MemberId: memberIds["Bob"]}},
+				}}
+			},
+		}
+		ids, err := s.ImportTasks(ctx, imp, false)
+		if err != nil {
+			t.Fatalf("ImportTasks: %v", err)
+		}
+		if len(ids) != 1 {
+			t.Fatalf("ImportTasks = %v, want one task", ids)
+		}
+		task, err := s.GetTask(ctx, ids[0])
+		if err != nil {
+			t.Fatalf("GetTask: %v", err)
+		}
+		if task.GroupName != "Kitchen" || task.AssigneeName != "Bob" || len(task.Rotation) != 2 || task.Rotation[1] != annId || task.Completions != 1 {
+			t.Errorf("imported task = %+v, want it in the new group and assigned to the new member", task)
+		}
+	})
+}
+
+func TestImportTasksRollsBack(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		imp := Import{
+			Groups:  []Group{{Name: "Kitchen"}},
+			Members: []Member{{Name: "Bob"}},
+			Tasks: func(groupIds map[string]GroupId, memberIds map[string]MemberId) []TaskHistory {
+				return []TaskHistory{
+					{Task: Task{Name: "Dishes", Period: 3, LastCompleted: Today(), GroupId: groupIds["Kitchen"], AssigneeId: NoMember}},
+					{Task: Task{Name: "Bins", Period: 7, LastCompleted: Today(), GroupId: NoGroup, AssigneeId: memberIds["Bob"] + 100}},
+				}
+			},
+		}
+		if _, err := s.ImportTasks(ctx, imp, false); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("ImportTasks with a missing assignee = %v, want ErrNotFound", err)
+		}
+
+		groups, err := s.GetGroups(ctx)
+		if err != nil {
+			t.Fatalf("GetGroups: %v", err)
+		}
+		members, err := s.GetMembers(ctx)
+		if err != nil {
+			t.Fatalf("GetMembers: %v", err)
+		}
+		tasks, err := s.Tasks(ctx, TaskFilter{Group: AnyGroup, Assignee: AnyMember, Done: true})
+		if err != nil {
+			t.Fatalf("Tasks: %v", err)
+		}
+		if len(groups) != 0 || len(members) != 0 || len(tasks) != 0 {
+			t.Errorf("after a failed import: groups %+v, members %+v, tasks %+v, want none", groups, members, tasks)
+		}
+
+		// A group twice in the same import is a conflict
+		imp.Groups = append(imp.Groups, Group{Name: "Kitchen"})
+		if _, err := s.ImportTasks(ctx, imp, false); !errors.Is(err, ErrConflict) {
+			t.Errorf("ImportTasks of a group twice = %v, want ErrConflict", err)
+		}
+	})
+}
@@ -0,0 +1,114 @@
+package notify
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"strings"
+
+	"github.com/markor147/peverel/internal/config"
+	"github.com/markor147/peverel/internal/data"
+	"github.com/markor147/peverel/internal/log"
+	gomail "gopkg.in/mail.v2"
+)
+
+//go:embed email.tmpl
+var emailTmpl string
+
+func init() {
+	Register(config.ChannelEmail, newEmailNotifier)
+}
+
+// emailNotifier sends the expired tasks as HTML emails.
+type emailNotifier struct {
+	tmpl       *template.Template
+	sender     string
+	recipients []string
+	smtp       config.SMTP
+	send       func(*gomail.Message) error // dials the SMTP server, replaced in the tests
+}
+
+func newEmailNotifier(ch config.Channel) (Notifier, error) {
+	text, err := readTemplate(ch.Template, emailTmpl)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := template.New("email").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parse template: %w", err)
+	}
+
+	smtp := ch.SMTP
+	if smtp.Port == 0 {
+		smtp.Port = 587
+	}
+	n := &emailNotifier{tmpl: tmpl, sender: ch.Sender, recipients: ch.Recipients, smtp: smtp}
+	n.send = func(m *gomail.Message) error {
+		return gomail.NewDialer(smtp.Server, smtp.Port, smtp.Username, smtp.Password).DialAndSend(m)
+	}
+	return n, nil
+}
+
+// Notify sends the expired tasks assigned to each member with an email address to that member,
+// and all the other expired tasks to the household recipients.
+func (n *emailNotifier) Notify(ctx context.Context, d Digest) error {
+	// Split the tasks between the members who can be reached and the household,
+	// in the order of the digest
+	reachable := make(map[data.MemberId]data.Member)
+	for _, member := range d.Members {
+		if member.Email != "" {
+			reachable[member.Id] = member
+		}
+	}
+	byAssignee := make(map[data.MemberId][]data.Task)
+	var assignees []data.MemberId
+	household := make([]data.Task, 0)
+	for _, task := range d.Tasks {
+		if _, ok := reachable[task.AssigneeId]; !ok {
+			household = append(household, task)
+			continue
+		}
+		if _, ok := byAssignee[task.AssigneeId]; !ok {
+			assignees = append(assignees, task.AssigneeId)
+		}
+		byAssignee[task.AssigneeId] = append(byAssignee[task.AssigneeId], task)
+	}
+
+	var errs []error
+	for _, id := range assignees {
+		member := reachable[id]
+		errs = append(errs, n.sendMessage(ctx, newMessage(member.Name, byAssignee[id]), []string{member.Email}))
+	}
+	if len(household) > 0 {
+		errs = append(errs, n.sendMessage(ctx, newMessage("", household), n.recipients))
+	}
+	return errors.Join(errs...)
+}
+
+// sendMessage sends the message to the recipients.
+func (n *emailNotifier) sendMessage(ctx context.Context, m Message, recipients []string) error {
+	// Execute the email body template
+	emailBodyBuilder := &strings.Builder{}
+	if err := n.tmpl.Execute(emailBodyBuilder, m); err != nil {
+		return fmt.Errorf("execute template: %w", err)
+	}
+
+	// Set up the email message
+	message := gomail.NewMessage()
+	message.SetHeader("From", n.sender)
+	message.SetHeader("To", recipients...)
+	message.SetHeader("Subject", title(m))
+	message.SetBody("text/html", emailBodyBuilder.String())
+
+	// Send the email
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("email not sent: %w", err)
+	}
+	if err := n.send(message); err != nil {
+		return fmt.Errorf("send email to %v: %w", recipients, err)
+	}
+	log.Logger.Infof("Email sent succesfully to %v", recipients)
+	return nil
+}
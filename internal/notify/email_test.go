@@ -0,0 +1,113 @@
+package notify
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/markor147/peverel/internal/config"
+	"github.com/markor147/peverel/internal/data"
+	gomail "gopkg.in/mail.v2"
+)
+
+// sentEmail is an email the notifier would have sent.
+type sentEmail struct {
+	to   []string
+	body string
+}
+
+func TestEmailNotifyRouting(t *testing.T) {
+	n := newNotifier(t, config.Channel{
+		Type:       config.ChannelEmail,
+		Sender:     "peverel@example.org",
+		Recipients: []string{"home@example.org"},
+		SMTP:       config.SMTP{Server: "smtp.example.org"},
+	}).(*emailNotifier)
+	var sent []sentEmail
+	n.send = func(m *gomail.Message) error {
+		var body strings.Builder
+		if _, err := m.WriteTo(&body); err != nil {
+			return err
+		}
+		sent = append(sent, sentEmail{to: m.GetHeader("To"), body: body.String()})
+		return nil
+	}
+
+	alice := data.Member{Id: 1, Name: "Alice", Email: "alice@example.org"}
+	bob := data.Member{Id: 2, Name: "Bob"} // no email, the tasks go to the household
+	carol := data.Member{Id: 3, Name: "Carol", Email: "carol@example.org"}
+	d := Digest{
+		Members: []data.Member{alice, bob, carol},
+		Tasks: []data.Task{
+			{Id: 1, Name: "Vacuum", AssigneeId: carol.Id},
+			{Id: 2, Name: "Dishes", AssigneeId: alice.Id},
+			{Id: 3, Name: "Windows", AssigneeId: data.NoMember},
+			{Id: 4, Name: "Laundry", AssigneeId: bob.Id},
+			{Id: 5, Name: "Trash", AssigneeId: 9}, // a member deleted meanwhile
+			{Id: 6, Name: "Plants", AssigneeId: alice.Id},
+		},
+	}
+	if err := n.Notify(t.Context(), d); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	want := []struct {
+		to    string
+		tasks []string
+	}{
+		{"carol@example.org", []string{"Vacuum"}},
+		{"alice@example.org", []string{"Dishes", "Plants"}},
+		{"home@example.org", []string{"Windows", "Laundry", "Trash"}},
+	}
+	if len(sent) != len(want) {
+		t.Fatalf("sent %d emails, want %d", len(sent), len(want))
+	}
+	all := []string{"Vacuum", "Dishes", "Windows", "Laundry", "Trash", "Plants"}
+	for i, w := range want {
+		got := sent[i]
+		if !slices.Equal(got.to, []string{w.to}) {
+			t.Errorf("email %d sent to %v, want %s", i, got.to, w.to)
+		}
+		// The tasks are listed in the order of the digest
+		last := -1
+		for _, name := range all {
+			at := strings.Index(got.body, name)
+			if !slices.Contains(w.tasks, name) {
+				if at >= 0 {
+					t.Errorf("email to %s lists %s", w.to, name)
+				}
+				continue
+			}
+			if at < 0 {
+				t.Errorf("email to %s does not list %s", w.to, name)
+			} else if at < last {
+				t.Errorf("email to %s lists %s out of order", w.to, name)
+			} else {
+				last = at
+			}
+		}
+	}
+}
+
+func TestEmailNotifyOnlyMembers(t *testing.T) {
+	n := newNotifier(t, config.Channel{
+		Type:       config.ChannelEmail,
+		Sender:     "peverel@example.org",
+		Recipients: []string{"home@example.org"},
+		SMTP:       config.SMTP{Server: "smtp.example.org"},
+	}).(*emailNotifier)
+	var to [][]string
+	n.send = func(m *gomail.Message) error {
+		to = append(to, m.GetHeader("To"))
+		return nil
+	}
+
+	alice := data.Member{Id: 1, Name: "Alice", Email: "alice@example.org"}
+	d := Digest{Members: []data.Member{alice}, Tasks: []data.Task{{Id: 1, Name: "Dishes", AssigneeId: alice.Id}}}
+	if err := n.Notify(t.Context(), d); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if len(to) != 1 || !slices.Equal(to[0], []string{"alice@example.org"}) {
+		t.Errorf("sent to %v, want only alice@example.org", to)
+	}
+}
@@ -0,0 +1,59 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"text/template"
+
+	"github.com/markor147/peverel/internal/config"
+)
+
+func init() {
+	Register(config.ChannelGotify, newGotifyNotifier)
+}
+
+// gotifyNotifier pushes the expired tasks as a message of a Gotify application.
+type gotifyNotifier struct {
+	tmpl     *template.Template
+	server   string
+	token    string
+	priority int
+}
+
+// gotifyMessage is the body of the Gotify create message request.
+type gotifyMessage struct {
+	Title    string `json:"title"`
+	Message  string `json:"message"`
+	Priority int    `json:"priority,omitempty"`
+}
+
+func newGotifyNotifier(ch config.Channel) (Notifier, error) {
+	tmpl, err := parseText(ch.Template)
+	if err != nil {
+		return nil, err
+	}
+	return &gotifyNotifier{
+		tmpl:     tmpl,
+		server:   strings.TrimSuffix(ch.URL, "/"),
+		token:    ch.Token,
+		priority: ch.Priority,
+	}, nil
+}
+
+func (n *gotifyNotifier) Notify(ctx context.Context, d Digest) error {
+	m := newMessage("", d.Tasks)
+	text, err := renderText(n.tmpl, m)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(gotifyMessage{Title: title(m), Message: text, Priority: n.priority})
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{}
+	header.Set("X-Gotify-Key", n.token)
+	return post(ctx, n.server+"/message", "application/json", body, header)
+}
@@ -0,0 +1,65 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+)
+
+//go:embed text.tmpl
+var textTmpl string
+
+// httpClient sends the requests of the HTTP channels.
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// post sends the body to the URL and fails unless the response status is 2xx.
+func post(ctx context.Context, url, contentType string, body []byte, header http.Header) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("POST %s: %s: %s", url, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// renderText executes the text template of a channel on the message.
+func renderText(tmpl *template.Template, m Message) (string, error) {
+	var b strings.Builder
+	if err := tmpl.Execute(&b, m); err != nil {
+		return "", fmt.Errorf("execute template: %w", err)
+	}
+	return b.String(), nil
+}
+
+// parseText parses the text template file of a channel, or the built-in text template.
+func parseText(path string) (*template.Template, error) {
+	text, err := readTemplate(path, textTmpl)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := template.New("text").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parse template: %w", err)
+	}
+	return tmpl, nil
+}
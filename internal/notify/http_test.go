@@ -0,0 +1,167 @@
+package notify
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/markor147/peverel/internal/config"
+	"github.com/markor147/peverel/internal/data"
+)
+
+// request is what a fake server received.
+type request struct {
+	method string
+	path   string
+	header http.Header
+	body   []byte
+}
+
+// recordServer starts a server recording the requests it receives.
+func recordServer(t *testing.T) (*httptest.Server, *[]request) {
+	t.Helper()
+	var received []request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = append(received, request{method: r.Method, path: r.URL.Path, header: r.Header, body: body})
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &received
+}
+
+// newNotifier builds the notifier of the channel.
+func newNotifier(t *testing.T, ch config.Channel) Notifier {
+	t.Helper()
+	n, err := factories[ch.Type](ch)
+	if err != nil {
+		t.Fatalf("new %s notifier: %v", ch.Type, err)
+	}
+	return n
+}
+
+func testDigest() Digest {
+	return Digest{Tasks: []data.Task{
+		{Id: 1, Name: "Dishes", Period: 1, AssigneeId: data.NoMember},
+		{Id: 2, Name: "Laundry", Period: 7, AssigneeId: data.NoMember},
+	}}
+}
+
+func TestWebhookNotify(t *testing.T) {
+	srv, received := recordServer(t)
+	n := newNotifier(t, config.Channel{Type: config.ChannelWebhook, Recipients: []string{srv.URL + "/hook"}, Token: "secret"})
+
+	if err := n.Notify(t.Context(), testDigest()); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if len(*received) != 1 {
+		t.Fatalf("got %d requests, want 1", len(*received))
+	}
+	req := (*received)[0]
+	if req.method != http.MethodPost || req.path != "/hook" {
+		t.Errorf("got %s %s, want POST /hook", req.method, req.path)
+	}
+	if got := req.header.Get("Authorization"); got != "Bearer secret" {
+		t.Errorf("Authorization = %q, want %q", got, "Bearer secret")
+	}
+	if got := req.header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+	var payload webhookPayload
+	if err := json.Unmarshal(req.body, &payload); err != nil {
+		t.Fatalf("decode body %q: %v", req.body, err)
+	}
+	if payload.Count != 2 || len(payload.Tasks) != 2 || payload.Tasks[0].Name != "Dishes" || payload.Tasks[1].Name != "Laundry" {
+		t.Errorf("got tasks %+v, want Dishes and Laundry", payload.Tasks)
+	}
+	if payload.Title == "" || payload.Text == "" {
+		t.Errorf("got title %q and text %q, want both set", payload.Title, payload.Text)
+	}
+}
+
+func TestWebhookNotifyWithoutToken(t *testing.T) {
+	srv, received := recordServer(t)
+	n := newNotifier(t, config.Channel{Type: config.ChannelWebhook, Recipients: []string{srv.URL}})
+
+	if err := n.Notify(t.Context(), testDigest()); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if got := (*received)[0].header.Get("Authorization"); got != "" {
+		t.Errorf("Authorization = %q, want none", got)
+	}
+}
+
+func TestNtfyNotify(t *testing.T) {
+	srv, received := recordServer(t)
+	n := newNotifier(t, config.Channel{
+		Type:       config.ChannelNtfy,
+		URL:        srv.URL + "/",
+		Recipients: []string{"chores", "other"},
+		Token:      "tk_secret",
+		Priority:   4,
+	})
+
+	if err := n.Notify(t.Context(), testDigest()); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if len(*received) != 2 {
+		t.Fatalf("got %d requests, want one per topic", len(*received))
+	}
+	for i, path := range []string{"/chores", "/other"} {
+		req := (*received)[i]
+		if req.method != http.MethodPost || req.path != path {
+			t.Errorf("got %s %s, want POST %s", req.method, req.path, path)
+		}
+		if got, want := req.header.Get("Title"), title(Message{Count: 2}); got != want {
+			t.Errorf("Title = %q, want %q", got, want)
+		}
+		if got := req.header.Get("Priority"); got != "4" {
+			t.Errorf("Priority = %q, want 4", got)
+		}
+		if got := req.header.Get("Authorization"); got != "Bearer tk_secret" {
+			t.Errorf("Authorization = %q, want %q", got, "Bearer tk_secret")
+		}
+		if len(req.body) == 0 {
+			t.Errorf("got an empty body")
+		}
+	}
+}
+
+func TestGotifyNotify(t *testing.T) {
+	srv, received := recordServer(t)
+	n := newNotifier(t, config.Channel{Type: config.ChannelGotify, URL: srv.URL, Token: "AbCdEf", Priority: 5})
+
+	if err := n.Notify(t.Context(), testDigest()); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if len(*received) != 1 {
+		t.Fatalf("got %d requests, want 1", len(*received))
+	}
+	req := (*received)[0]
+	if req.method != http.MethodPost || req.path != "/message" {
+		t.Errorf("got %s %s, want POST /message", req.method, req.path)
+	}
+	if got := req.header.Get("X-Gotify-Key"); got != "AbCdEf" {
+		t.Errorf("X-Gotify-Key = %q, want AbCdEf", got)
+	}
+	var msg gotifyMessage
+	if err := json.Unmarshal(req.body, &msg); err != nil {
+		t.Fatalf("decode body %q: %v", req.body, err)
+	}
+	if msg.Priority != 5 || msg.Title == "" || msg.Message == "" {
+		t.Errorf("got message %+v, want priority 5 with a title and a text", msg)
+	}
+}
+
+func TestPostFailsOnErrorStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+	n := newNotifier(t, config.Channel{Type: config.ChannelGotify, URL: srv.URL, Token: "wrong"})
+
+	if err := n.Notify(t.Context(), testDigest()); err == nil {
+		t.Fatalf("Notify succeeded, want an error on 401")
+	}
+}
@@ -0,0 +1,248 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/markor147/peverel/internal/config"
+	"github.com/markor147/peverel/internal/data"
+	"github.com/markor147/peverel/internal/log"
+)
+
+/*
+=== NOTIFICATION CHANNELS ===
+Every channel of the configuration is a Notifier, built by the Factory registered
+for its type by Register. At each tick, the expired tasks are sent through every channel,
+and a failing channel does not stop the others. The messages are rendered from a
+Message by the template of the channel, or by the built-in one of its type.
+==================================
+*/
+
+// Notifier sends the expired tasks through a channel.
+type Notifier interface {
+	// Notify sends the expired tasks of the digest.
+	Notify(ctx context.Context, d Digest) error
+}
+
+// Listener is implemented by the channels that also receive messages, such as bot commands.
+// The listeners run while the notifications are scheduled.
+type Listener interface {
+	// Listen handles the incoming messages until ctx is done.
+	Listen(ctx context.Context, store data.Store) error
+}
+
+// Digest is what the notifiers send: the expired tasks, and the members
+// for the channels able to reach them directly.
+type Digest struct {
+	Tasks   []data.Task
+	Members []data.Member
+}
+
+// Message is the data of the templates: the expired tasks of a member,
+// or of the whole household when Member is empty.
+type Message struct {
+	Member string `json:"member,omitempty"`
+	Count  int    `json:"count"`
+	Tasks  []Item `json:"tasks"`
+}
+
+// Item is a task of a Message.
+type Item struct {
+	Id          data.TaskId `json:"id"`
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	Group       string      `json:"group,omitempty"`
+	Assignee    string      `json:"assignee,omitempty"`
+	Due         string      `json:"due,omitempty"` // due date of the one-off tasks, to tell them apart from the chores
+}
+
+// Factory builds the notifier of a channel.
+type Factory func(ch config.Channel) (Notifier, error)
+
+var factories = map[string]Factory{}
+
+// Register makes a channel type available to the configuration. It panics if the type is already registered.
+func Register(channelType string, f Factory) {
+	if _, ok := factories[channelType]; ok {
+		panic(fmt.Sprintf("notification channel %q registered twice", channelType))
+	}
+	factories[channelType] = f
+}
+
+// channel is the notifier of a channel, named for the logs.
+type channel struct {
+	name string
+	Notifier
+}
+
+// newChannels builds the notifiers of the channels.
+func newChannels(channels []config.Channel) ([]channel, error) {
+	res := make([]channel, 0, len(channels))
+	var errs []error
+	for i, ch := range channels {
+		name := ch.Name
+		if name == "" {
+			name = fmt.Sprintf("%s #%d", ch.Type, i+1)
+		}
+		factory, ok := factories[ch.Type]
+		if !ok {
+			errs = append(errs, fmt.Errorf("channel %s: unknown type %q", name, ch.Type))
+			continue
+		}
+		n, err := factory(ch)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("channel %s: %w", name, err))
+			continue
+		}
+		res = append(res, channel{name: name, Notifier: n})
+	}
+	return res, errors.Join(errs...)
+}
+
+// Run sends the expired tasks through the channels of cfg at the scheduled time, then every ScheduledHours,
+// until ctx is done. Without a scheduled time, it sends them once right away.
+func Run(ctx context.Context, store data.Store, cfg config.Notifier) error {
+	channels, err := newChannels(cfg.AllChannels())
+	if err != nil {
+		return err
+	}
+	if len(channels) == 0 {
+		return errors.New("no notification channel")
+	}
+
+	// Set up the scheduler
+	scheduledHours := cfg.ScheduledHours
+	if cfg.ScheduledTime != "" {
+		// Parse the scheduled time
+		parsedTime, err := cfg.Schedule()
+		if err != nil {
+			return fmt.Errorf("parse scheduled time: %w", err)
+		}
+		now := time.Now()
+		schedule := time.Date(now.Year(), now.Month(), now.Day(), parsedTime.Hour(), parsedTime.Minute(), 0, 0, now.Location())
+
+		log.Logger.Infof("Service started with scheduled time: %s. Now is %s.", schedule.Format("15:04"), now.Format("15:04"))
+		listen(ctx, store, channels)
+
+		// Ff the scheduled time is today, add 24 hours to it
+		initialDuration := schedule.Sub(now)
+		if initialDuration < 0 {
+			initialDuration += time.Duration(scheduledHours) * time.Hour
+		}
+		// Send the notifications after the initial duration
+		log.Logger.Infof("Waiting for next tick: %f mins", initialDuration.Minutes())
+		select {
+		case <-ctx.Done():
+			log.Logger.Info("Service stopped")
+			return nil
+		case <-time.After(initialDuration):
+		}
+		send(ctx, store, channels)
+		log.Logger.Infof("Waiting for next tick")
+
+		// Set up a ticker to send the notifications every scheduledHours hours
+		ticker := time.NewTicker(time.Duration(scheduledHours) * time.Hour)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				log.Logger.Info("Service stopped")
+				return nil
+			case <-ticker.C:
+				send(ctx, store, channels)
+				log.Logger.Infof("Waiting for next tick")
+			}
+		}
+	} else {
+		// If the scheduled time is not set, send the notifications immediately
+		send(ctx, store, channels)
+	}
+	return nil
+}
+
+// listen starts the listeners among the channels, until ctx is done.
+func listen(ctx context.Context, store data.Store, channels []channel) {
+	for _, ch := range channels {
+		l, ok := ch.Notifier.(Listener)
+		if !ok {
+			continue
+		}
+		go func(name string) {
+			log.Logger.Infof("listening to channel %s", name)
+			if err := l.Listen(ctx, store); err != nil && ctx.Err() == nil {
+				log.Logger.Errorf("listen to channel %s: %v", name, err)
+			}
+		}(ch.name)
+	}
+}
+
+// send sends the expired tasks through every channel.
+func send(ctx context.Context, store data.Store, channels []channel) {
+	// Fetch the expired tasks
+	today := 0
+	expiredTasks, err := store.Tasks(ctx, data.TaskFilter{Days: &today, Expired: true})
+	if err != nil {
+		log.Logger.Errorf("get expired tasks: %v", err)
+		return
+	}
+
+	if len(expiredTasks) == 0 {
+		// No expired tasks, do not send anything
+		log.Logger.Infof("No expired tasks found")
+		return
+	}
+
+	members, err := store.GetMembers(ctx)
+	if err != nil {
+		log.Logger.Errorf("get members: %v", err)
+		return
+	}
+
+	d := Digest{Tasks: expiredTasks, Members: members}
+	for _, ch := range channels {
+		if err := ch.Notify(ctx, d); err != nil {
+			log.Logger.Errorf("notify through channel %s: %v", ch.name, err)
+			continue
+		}
+		log.Logger.Infof("notified %d expired tasks through channel %s", len(expiredTasks), ch.name)
+	}
+}
+
+// newMessage builds the message of the expired tasks of the member, or of the household if the name is empty.
+func newMessage(memberName string, tasks []data.Task) Message {
+	items := make([]Item, 0, len(tasks))
+	for _, task := range tasks {
+		item := Item{
+			Id:          task.Id,
+			Name:        task.Name,
+			Description: task.Description,
+			Group:       task.GroupName,
+			Assignee:    task.AssigneeName,
+		}
+		if task.OneOff() {
+			item.Due = task.DueDate.Format("2006-01-02")
+		}
+		items = append(items, item)
+	}
+	return Message{Member: memberName, Count: len(tasks), Tasks: items}
+}
+
+// title is the subject of the messages.
+func title(m Message) string {
+	return fmt.Sprintf("Peverel has something for you: %d expired tasks", m.Count)
+}
+
+// readTemplate returns the content of the template file of the channel, or the built-in template.
+func readTemplate(path, builtin string) (string, error) {
+	if path == "" {
+		return builtin, nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read template: %w", err)
+	}
+	return string(content), nil
+}
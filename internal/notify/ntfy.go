@@ -0,0 +1,63 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/markor147/peverel/internal/config"
+)
+
+func init() {
+	Register(config.ChannelNtfy, newNtfyNotifier)
+}
+
+// ntfyNotifier publishes the expired tasks to the recipient topics of an ntfy server.
+type ntfyNotifier struct {
+	tmpl     *template.Template
+	server   string
+	topics   []string
+	token    string
+	priority int
+}
+
+func newNtfyNotifier(ch config.Channel) (Notifier, error) {
+	tmpl, err := parseText(ch.Template)
+	if err != nil {
+		return nil, err
+	}
+	return &ntfyNotifier{
+		tmpl:     tmpl,
+		server:   strings.TrimSuffix(ch.URL, "/"),
+		topics:   ch.Recipients,
+		token:    ch.Token,
+		priority: ch.Priority,
+	}, nil
+}
+
+func (n *ntfyNotifier) Notify(ctx context.Context, d Digest) error {
+	m := newMessage("", d.Tasks)
+	text, err := renderText(n.tmpl, m)
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{}
+	header.Set("Title", title(m))
+	header.Set("Tags", "broom")
+	if n.priority > 0 {
+		header.Set("Priority", strconv.Itoa(n.priority))
+	}
+	if n.token != "" {
+		header.Set("Authorization", "Bearer "+n.token)
+	}
+	var errs []error
+	for _, topic := range n.topics {
+		errs = append(errs, post(ctx, n.server+"/"+url.PathEscape(topic), "text/plain; charset=utf-8", []byte(text), header))
+	}
+	return errors.Join(errs...)
+}
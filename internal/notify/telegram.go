@@ -0,0 +1,413 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/markor147/peverel/internal/config"
+	"github.com/markor147/peverel/internal/data"
+	"github.com/markor147/peverel/internal/log"
+)
+
+/*
+=== TELEGRAM BOT ===
+The telegram channel posts the expired tasks to the recipient chats with an inline
+"Done" button per task, whose callback data is "done:<task id>". While the
+notifications are scheduled, the bot long-polls the Bot API for updates and
+answers the members of those chats only:
+  - a press on a "Done" button completes the task and removes the button;
+  - /due lists the tasks due today or overdue, with their buttons;
+  - /done <name> completes the task with that name, or the only one containing it.
+The completions are credited to the member named as the first name of the Telegram user,
+or else recorded by that first name.
+==================================
+*/
+
+const (
+	// telegramAPI is the Bot API server used when the channel has no URL.
+	telegramAPI = "https://api.telegram.org"
+	// telegramPollTimeout is how long a getUpdates request waits for updates.
+	telegramPollTimeout = 30 * time.Second
+	// telegramRetryDelay is the pause after a failed getUpdates request.
+	telegramRetryDelay = 5 * time.Second
+	// telegramDonePrefix starts the callback data of the "Done" buttons.
+	telegramDonePrefix = "done:"
+)
+
+func init() {
+	Register(config.ChannelTelegram, newTelegramNotifier)
+}
+
+// telegramNotifier is the Telegram bot of the household chats.
+type telegramNotifier struct {
+	tmpl   *template.Template
+	api    string // base URL of the methods, with the bot token
+	chats  []int64
+	client *http.Client
+}
+
+type telegramResponse struct {
+	Ok          bool            `json:"ok"`
+	Description string          `json:"description"`
+	Result      json.RawMessage `json:"result"`
+}
+
+type telegramUpdate struct {
+	UpdateId      int64                  `json:"update_id"`
+	Message       *telegramMessage       `json:"message"`
+	CallbackQuery *telegramCallbackQuery `json:"callback_query"`
+}
+
+type telegramMessage struct {
+	MessageId   int64                   `json:"message_id"`
+	Chat        telegramChat            `json:"chat"`
+	From        *telegramUser           `json:"from"`
+	Text        string                  `json:"text"`
+	ReplyMarkup *telegramInlineKeyboard `json:"reply_markup,omitempty"`
+}
+
+type telegramChat struct {
+	Id int64 `json:"id"`
+}
+
+type telegramUser struct {
+	FirstName string `json:"first_name"`
+}
+
+type telegramCallbackQuery struct {
+	Id      string           `json:"id"`
+	From    telegramUser     `json:"from"`
+	Message *telegramMessage `json:"message"`
+	Data    string           `json:"data"`
+}
+
+type telegramInlineKeyboard struct {
+	InlineKeyboard [][]telegramButton `json:"inline_keyboard"`
+}
+
+type telegramButton struct {
+	Text         string `json:"text"`
+	CallbackData string `json:"callback_data"`
+}
+
+func newTelegramNotifier(ch config.Channel) (Notifier, error) {
+	tmpl, err := parseText(ch.Template)
+	if err != nil {
+		return nil, err
+	}
+
+	chats := make([]int64, 0, len(ch.Recipients))
+	for _, r := range ch.Recipients {
+		id, err := strconv.ParseInt(r, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid chat id %q", r)
+		}
+		chats = append(chats, id)
+	}
+
+	server := strings.TrimSuffix(ch.URL, "/")
+	if server == "" {
+		server = telegramAPI
+	}
+	return &telegramNotifier{
+		tmpl:   tmpl,
+		api:    server + "/bot" + ch.Token,
+		chats:  chats,
+		client: &http.Client{Timeout: telegramPollTimeout + 10*time.Second},
+	}, nil
+}
+
+// Notify posts the expired tasks to every chat, with a "Done" button per task.
+func (n *telegramNotifier) Notify(ctx context.Context, d Digest) error {
+	var errs []error
+	for _, chat := range n.chats {
+		errs = append(errs, n.sendTasks(ctx, chat, d.Tasks))
+	}
+	return errors.Join(errs...)
+}
+
+// Listen long-polls the updates of the bot and handles them until ctx is done.
+func (n *telegramNotifier) Listen(ctx context.Context, store data.Store) error {
+	var offset int64
+	for {
+		var updates []telegramUpdate
+		err := n.call(ctx, "getUpdates", map[string]any{
+			"offset":          offset,
+			"timeout":         int(telegramPollTimeout.Seconds()),
+			"allowed_updates": []string{"message", "callback_query"},
+		}, &updates)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			log.Logger.Warnf("telegram getUpdates: %v", err)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(telegramRetryDelay):
+			}
+			continue
+		}
+
+		for _, u := range updates {
+			offset = u.UpdateId + 1
+			if err := n.handle(ctx, store, u); err != nil {
+				log.Logger.Errorf("telegram update %d: %v", u.UpdateId, err)
+			}
+		}
+	}
+}
+
+// handle answers an update, ignoring the chats the bot does not notify.
+func (n *telegramNotifier) handle(ctx context.Context, store data.Store, u telegramUpdate) error {
+	switch {
+	case u.CallbackQuery != nil:
+		q := u.CallbackQuery
+		if q.Message == nil || !slices.Contains(n.chats, q.Message.Chat.Id) {
+			return n.call(ctx, "answerCallbackQuery", map[string]any{"callback_query_id": q.Id, "text": "This chat is not allowed."}, nil)
+		}
+		return n.handleDone(ctx, store, q)
+	case u.Message != nil:
+		if !slices.Contains(n.chats, u.Message.Chat.Id) {
+			log.Logger.Warnf("telegram message from chat %d ignored", u.Message.Chat.Id)
+			return nil
+		}
+		return n.handleCommand(ctx, store, u.Message)
+	}
+	return nil
+}
+
+// handleDone completes the task of a "Done" button and removes the button from the message.
+func (n *telegramNotifier) handleDone(ctx context.Context, store data.Store, q *telegramCallbackQuery) error {
+	idStr, ok := strings.CutPrefix(q.Data, telegramDonePrefix)
+	id, err := strconv.Atoi(idStr)
+	if !ok || err != nil {
+		return n.call(ctx, "answerCallbackQuery", map[string]any{"callback_query_id": q.Id, "text": "Unknown button."}, nil)
+	}
+
+	task, by, err := n.complete(ctx, store, data.TaskId(id), &q.From)
+	if err != nil {
+		answer := "Could not complete the task."
+		if errors.Is(err, data.ErrNotFound) {
+			answer = "The task does not exist anymore."
+		}
+		if aerr := n.call(ctx, "answerCallbackQuery", map[string]any{"callback_query_id": q.Id, "text": answer}, nil); aerr != nil {
+			return errors.Join(err, aerr)
+		}
+		return err
+	}
+
+	errs := []error{
+		n.call(ctx, "answerCallbackQuery", map[string]any{"callback_query_id": q.Id, "text": "Done: " + task.Name}, nil),
+		n.sendText(ctx, q.Message.Chat.Id, fmt.Sprintf("%s completed %s.", by, task.Name)),
+	}
+	if q.Message.ReplyMarkup != nil {
+		keyboard := removeButton(*q.Message.ReplyMarkup, q.Data)
+		errs = append(errs, n.call(ctx, "editMessageReplyMarkup", map[string]any{
+			"chat_id":      q.Message.Chat.Id,
+			"message_id":   q.Message.MessageId,
+			"reply_markup": keyboard,
+		}, nil))
+	}
+	return errors.Join(errs...)
+}
+
+// handleCommand answers the /due, /done and /help commands.
+func (n *telegramNotifier) handleCommand(ctx context.Context, store data.Store, m *telegramMessage) error {
+	command, arg, _ := strings.Cut(strings.TrimSpace(m.Text), " ")
+	command, _, _ = strings.Cut(command, "@") // /due@peverel_bot in groups
+	arg = strings.TrimSpace(arg)
+
+	switch command {
+	case "/due":
+		today := 0
+		tasks, err := store.Tasks(ctx, data.TaskFilter{Days: &today, Expired: true})
+		if err != nil {
+			return err
+		}
+		if len(tasks) == 0 {
+			return n.sendText(ctx, m.Chat.Id, "Nothing is due today.")
+		}
+		return n.sendTasks(ctx, m.Chat.Id, tasks)
+	case "/done":
+		if arg == "" {
+			return n.sendText(ctx, m.Chat.Id, "Usage: /done <task name>")
+		}
+		task, err := findTask(ctx, store, arg)
+		if err != nil {
+			return n.sendText(ctx, m.Chat.Id, err.Error())
+		}
+		_, by, err := n.complete(ctx, store, task.Id, m.From)
+		if err != nil {
+			return err
+		}
+		return n.sendText(ctx, m.Chat.Id, fmt.Sprintf("%s completed %s.", by, task.Name))
+	case "/start", "/help":
+		return n.sendText(ctx, m.Chat.Id, "/due lists the tasks due today or overdue\n/done <name> completes a task")
+	}
+	return nil
+}
+
+// complete completes the task and returns it, with the name of whom the completion is credited to:
+// the member named as the Telegram user, ignoring case, or else the first name of the user.
+func (n *telegramNotifier) complete(ctx context.Context, store data.Store, id data.TaskId, from *telegramUser) (data.Task, string, error) {
+	task, err := store.GetTask(ctx, id)
+	if err != nil {
+		return data.Task{}, "", err
+	}
+	if from == nil || from.FirstName == "" {
+		if err := store.CompleteTask(ctx, id, data.NoMember); err != nil {
+			return data.Task{}, "", err
+		}
+		log.Logger.Infof("task %d completed from telegram", id)
+		return task, "Someone", nil
+	}
+
+	members, err := store.GetMembers(ctx)
+	if err != nil {
+		return data.Task{}, "", err
+	}
+	for _, member := range members {
+		if strings.EqualFold(member.Name, from.FirstName) {
+			if err := store.CompleteTask(ctx, id, member.Id); err != nil {
+				return data.Task{}, "", err
+			}
+			log.Logger.Infof("task %d completed from telegram by member %d", id, member.Id)
+			return task, member.Name, nil
+		}
+	}
+
+	// Not a member: the completion records the name, and wakes the task up as CompleteTask does
+	c := data.Completion{TaskId: id, At: time.Now(), MemberId: data.NoMember, By: from.FirstName}
+	if _, err := store.AddCompletion(ctx, c); err != nil {
+		return data.Task{}, "", err
+	}
+	if !task.SnoozedUntil.IsZero() {
+		if err := store.SnoozeTask(ctx, id, time.Time{}); err != nil {
+			return data.Task{}, "", err
+		}
+	}
+	log.Logger.Infof("task %d completed from telegram by %s", id, from.FirstName)
+	return task, from.FirstName, nil
+}
+
+// sendTasks posts the tasks to the chat, rendered by the template, with a "Done" button per task.
+func (n *telegramNotifier) sendTasks(ctx context.Context, chat int64, tasks []data.Task) error {
+	m := newMessage("", tasks)
+	text, err := renderText(n.tmpl, m)
+	if err != nil {
+		return err
+	}
+
+	keyboard := telegramInlineKeyboard{InlineKeyboard: make([][]telegramButton, 0, len(m.Tasks))}
+	for _, item := range m.Tasks {
+		keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, []telegramButton{{
+			Text:         "Done: " + item.Name,
+			CallbackData: fmt.Sprintf("%s%d", telegramDonePrefix, item.Id),
+		}})
+	}
+	return n.call(ctx, "sendMessage", map[string]any{
+		"chat_id":      chat,
+		"text":         text,
+		"reply_markup": keyboard,
+	}, nil)
+}
+
+// sendText posts a plain message to the chat.
+func (n *telegramNotifier) sendText(ctx context.Context, chat int64, text string) error {
+	return n.call(ctx, "sendMessage", map[string]any{"chat_id": chat, "text": text}, nil)
+}
+
+// call calls a method of the Bot API and decodes its result into result, unless nil.
+func (n *telegramNotifier) call(ctx context.Context, method string, params any, result any) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.api+"/"+method, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		// The URL holds the bot token, keep it out of the logs
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			err = uerr.Err
+		}
+		return fmt.Errorf("telegram %s: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	var res telegramResponse
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return fmt.Errorf("telegram %s: %s: %w", method, resp.Status, err)
+	}
+	if !res.Ok {
+		return fmt.Errorf("telegram %s: %s", method, res.Description)
+	}
+	if result != nil {
+		if err := json.Unmarshal(res.Result, result); err != nil {
+			return fmt.Errorf("telegram %s: %w", method, err)
+		}
+	}
+	return nil
+}
+
+// === Helpers ===
+
+// findTask returns the active task with the name, ignoring case,
+// or else the only one whose name contains it.
+func findTask(ctx context.Context, store data.Store, name string) (data.Task, error) {
+	tasks, err := store.Tasks(ctx, data.TaskFilter{})
+	if err != nil {
+		return data.Task{}, err
+	}
+
+	var matches []data.Task
+	lower := strings.ToLower(name)
+	for _, t := range tasks {
+		if strings.EqualFold(t.Name, name) {
+			return t, nil
+		}
+		if strings.Contains(strings.ToLower(t.Name), lower) {
+			matches = append(matches, t)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return data.Task{}, fmt.Errorf("no task named %q", name)
+	case 1:
+		return matches[0], nil
+	default:
+		names := make([]string, 0, len(matches))
+		for _, t := range matches {
+			names = append(names, t.Name)
+		}
+		return data.Task{}, fmt.Errorf("%q matches %s, which one?", name, strings.Join(names, ", "))
+	}
+}
+
+// removeButton returns the keyboard without the button with the callback data.
+func removeButton(k telegramInlineKeyboard, callbackData string) telegramInlineKeyboard {
+	res := telegramInlineKeyboard{InlineKeyboard: make([][]telegramButton, 0, len(k.InlineKeyboard))}
+	for _, row := range k.InlineKeyboard {
+		row = slices.DeleteFunc(slices.Clone(row), func(b telegramButton) bool { return b.CallbackData == callbackData })
+		if len(row) > 0 {
+			res.InlineKeyboard = append(res.InlineKeyboard, row)
+		}
+	}
+	return res
+}
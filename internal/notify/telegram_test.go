@@ -0,0 +1,284 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/markor147/peverel/internal/config"
+	"github.com/markor147/peverel/internal/data"
+)
+
+const testChat = 42
+
+// botCall is a Bot API method called by the bot, with its parameters.
+type botCall struct {
+	method string
+	params map[string]any
+}
+
+// fakeBot starts a fake Bot API server answering every method with an empty result,
+// and returns the telegram notifier of the chat testChat using it.
+func fakeBot(t *testing.T) (*telegramNotifier, *[]botCall) {
+	t.Helper()
+	var calls []botCall
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, ok := strings.CutPrefix(r.URL.Path, "/bottest-token/")
+		if !ok {
+			http.Error(w, `{"ok":false,"description":"Unauthorized"}`, http.StatusUnauthorized)
+			return
+		}
+		var params map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+			t.Errorf("decode %s params: %v", method, err)
+		}
+		calls = append(calls, botCall{method: method, params: params})
+		_, _ = w.Write([]byte(`{"ok":true,"result":true}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	n, err := newTelegramNotifier(config.Channel{
+		Type:       config.ChannelTelegram,
+		URL:        srv.URL,
+		Token:      "test-token",
+		Recipients: []string{"42"},
+	})
+	if err != nil {
+		t.Fatalf("new telegram notifier: %v", err)
+	}
+	return n.(*telegramNotifier), &calls
+}
+
+// callsOf returns the parameters of the calls to the method.
+func callsOf(calls []botCall, method string) []map[string]any {
+	var res []map[string]any
+	for _, c := range calls {
+		if c.method == method {
+			res = append(res, c.params)
+		}
+	}
+	return res
+}
+
+// callbackData returns the callback data of the buttons of the keyboard, in order.
+func callbackData(t *testing.T, keyboard any) []string {
+	t.Helper()
+	raw, err := json.Marshal(keyboard)
+	if err != nil {
+		t.Fatalf("encode keyboard: %v", err)
+	}
+	var k telegramInlineKeyboard
+	if err := json.Unmarshal(raw, &k); err != nil {
+		t.Fatalf("decode keyboard %s: %v", raw, err)
+	}
+	var res []string
+	for _, row := range k.InlineKeyboard {
+		for _, b := range row {
+			res = append(res, b.CallbackData)
+		}
+	}
+	return res
+}
+
+// addTask adds a task expired for days to the store.
+func addTask(t *testing.T, store data.Store, name string, days int) data.TaskId {
+	t.Helper()
+	id, err := store.AddTask(context.Background(), data.Task{Name: name, Period: 1, LastCompleted: time.Now().AddDate(0, 0, -days)})
+	if err != nil {
+		t.Fatalf("AddTask %q: %v", name, err)
+	}
+	return id
+}
+
+// checkCompletions checks the number of completions of the task and returns them.
+func checkCompletions(t *testing.T, store data.Store, id data.TaskId, want int) []data.Completion {
+	t.Helper()
+	completions, err := store.Completions(context.Background(), id)
+	if err != nil {
+		t.Fatalf("Completions: %v", err)
+	}
+	if len(completions) != want {
+		t.Fatalf("task %d has %d completions, want %d", id, len(completions), want)
+	}
+	return completions
+}
+
+func TestTelegramNotify(t *testing.T) {
+	n, calls := fakeBot(t)
+	if err := n.Notify(t.Context(), testDigest()); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	sent := callsOf(*calls, "sendMessage")
+	if len(sent) != 1 {
+		t.Fatalf("got %d messages, want 1", len(sent))
+	}
+	if got := sent[0]["chat_id"]; got != float64(testChat) {
+		t.Errorf("chat_id = %v, want %d", got, testChat)
+	}
+	if text, _ := sent[0]["text"].(string); !strings.Contains(text, "Dishes") || !strings.Contains(text, "Laundry") {
+		t.Errorf("text = %q, want the tasks", text)
+	}
+	got := callbackData(t, sent[0]["reply_markup"])
+	if strings.Join(got, ",") != "done:1,done:2" {
+		t.Errorf("buttons = %v, want done:1 and done:2", got)
+	}
+}
+
+func TestTelegramDoneButton(t *testing.T) {
+	n, calls := fakeBot(t)
+	store := data.NewMemStore()
+	dishes := addTask(t, store, "Dishes", 2)
+	laundry := addTask(t, store, "Laundry", 2)
+	alice, err := store.AddMember(t.Context(), data.Member{Name: "Alice"})
+	if err != nil {
+		t.Fatalf("AddMember: %v", err)
+	}
+
+	keyboard := telegramInlineKeyboard{InlineKeyboard: [][]telegramButton{
+		{{Text: "Done: Dishes", CallbackData: "done:1"}},
+		{{Text: "Done: Laundry", CallbackData: "done:2"}},
+	}}
+	press := func(from, callback string) error {
+		return n.handle(t.Context(), store, telegramUpdate{CallbackQuery: &telegramCallbackQuery{
+			Id:      "q1",
+			From:    telegramUser{FirstName: from},
+			Message: &telegramMessage{MessageId: 7, Chat: telegramChat{Id: testChat}, ReplyMarkup: &keyboard},
+			Data:    callback,
+		}})
+	}
+
+	// The user is credited as the member with the same name
+	if err := press("alice", "done:1"); err != nil {
+		t.Fatalf("handle done: %v", err)
+	}
+	if c := checkCompletions(t, store, dishes, 1); c[0].MemberId != alice {
+		t.Errorf("completion credited to member %d, want %d", c[0].MemberId, alice)
+	}
+	edits := callsOf(*calls, "editMessageReplyMarkup")
+	if len(edits) != 1 {
+		t.Fatalf("got %d keyboard edits, want 1", len(edits))
+	}
+	if edits[0]["message_id"] != float64(7) {
+		t.Errorf("message_id = %v, want 7", edits[0]["message_id"])
+	}
+	if got := callbackData(t, edits[0]["reply_markup"]); len(got) != 1 || got[0] != "done:2" {
+		t.Errorf("buttons left = %v, want done:2", got)
+	}
+	if sent := callsOf(*calls, "sendMessage"); len(sent) != 1 || sent[0]["text"] != "Alice completed Dishes." {
+		t.Errorf("messages = %v, want Alice completed Dishes.", sent)
+	}
+
+	// Somebody who is not a member is recorded by name, and the task wakes up as for the members
+	if err := store.SnoozeTask(t.Context(), laundry, data.Today().AddDate(0, 0, 3)); err != nil {
+		t.Fatalf("SnoozeTask: %v", err)
+	}
+	if err := press("Zoe", "done:2"); err != nil {
+		t.Fatalf("handle done: %v", err)
+	}
+	if c := checkCompletions(t, store, laundry, 1); c[0].MemberId != data.NoMember || c[0].By != "Zoe" {
+		t.Errorf("completion by member %d %q, want Zoe", c[0].MemberId, c[0].By)
+	}
+	if task, err := store.GetTask(t.Context(), laundry); err != nil || !task.SnoozedUntil.IsZero() {
+		t.Errorf("GetTask = %+v, %v, want the task awake", task, err)
+	}
+
+	// A task deleted meanwhile is only answered
+	*calls = nil
+	if err := press("Zoe", "done:99"); err == nil {
+		t.Errorf("handle done of a missing task succeeded")
+	}
+	if answers := callsOf(*calls, "answerCallbackQuery"); len(answers) != 1 || answers[0]["text"] != "The task does not exist anymore." {
+		t.Errorf("answers = %v, want the task does not exist anymore", answers)
+	}
+	if len(callsOf(*calls, "editMessageReplyMarkup")) != 0 {
+		t.Errorf("keyboard edited for a missing task")
+	}
+}
+
+func TestTelegramCommands(t *testing.T) {
+	n, calls := fakeBot(t)
+	store := data.NewMemStore()
+	wash := addTask(t, store, "Wash dishes", 2)
+	dry := addTask(t, store, "Dry dishes", 2)
+	addTask(t, store, "Plants", -3) // not due yet
+
+	command := func(text string) string {
+		t.Helper()
+		*calls = nil
+		err := n.handle(t.Context(), store, telegramUpdate{Message: &telegramMessage{
+			Chat: telegramChat{Id: testChat},
+			From: &telegramUser{FirstName: "Bob"},
+			Text: text,
+		}})
+		if err != nil {
+			t.Fatalf("handle %s: %v", text, err)
+		}
+		sent := callsOf(*calls, "sendMessage")
+		if len(sent) != 1 {
+			t.Fatalf("%s: got %d messages, want 1", text, len(sent))
+		}
+		reply, _ := sent[0]["text"].(string)
+		if text == "/due" {
+			got := callbackData(t, sent[0]["reply_markup"])
+			if len(got) != 2 {
+				t.Errorf("/due buttons = %v, want the two due tasks", got)
+			}
+		}
+		return reply
+	}
+
+	if reply := command("/due"); !strings.Contains(reply, "Wash dishes") || !strings.Contains(reply, "Dry dishes") || strings.Contains(reply, "Plants") {
+		t.Errorf("/due = %q, want the dishes only", reply)
+	}
+
+	if reply := command("/done dishes"); !strings.Contains(reply, "which one?") {
+		t.Errorf("/done dishes = %q, want the ambiguous name reply", reply)
+	}
+	checkCompletions(t, store, wash, 0)
+	checkCompletions(t, store, dry, 0)
+
+	if reply := command("/done@peverel_bot dry"); reply != "Bob completed Dry dishes." {
+		t.Errorf("/done dry = %q, want Bob completed Dry dishes.", reply)
+	}
+	checkCompletions(t, store, dry, 1)
+
+	if reply := command("/done windows"); reply != `no task named "windows"` {
+		t.Errorf("/done windows = %q, want no task named", reply)
+	}
+}
+
+func TestTelegramOtherChat(t *testing.T) {
+	n, calls := fakeBot(t)
+	store := data.NewMemStore()
+	id := addTask(t, store, "Dishes", 2)
+
+	err := n.handle(t.Context(), store, telegramUpdate{Message: &telegramMessage{
+		Chat: telegramChat{Id: 999},
+		Text: "/done Dishes",
+	}})
+	if err != nil {
+		t.Fatalf("handle message: %v", err)
+	}
+	if len(*calls) != 0 {
+		t.Errorf("answered a message of another chat: %v", *calls)
+	}
+
+	err = n.handle(t.Context(), store, telegramUpdate{CallbackQuery: &telegramCallbackQuery{
+		Id:      "q1",
+		From:    telegramUser{FirstName: "Eve"},
+		Message: &telegramMessage{Chat: telegramChat{Id: 999}},
+		Data:    "done:1",
+	}})
+	if err != nil {
+		t.Fatalf("handle callback: %v", err)
+	}
+	if answers := callsOf(*calls, "answerCallbackQuery"); len(answers) != 1 || answers[0]["text"] != "This chat is not allowed." {
+		t.Errorf("answers = %v, want the chat is not allowed", answers)
+	}
+	checkCompletions(t, store, id, 0)
+}
@@ -0,0 +1,60 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"text/template"
+
+	"github.com/markor147/peverel/internal/config"
+)
+
+func init() {
+	Register(config.ChannelWebhook, newWebhookNotifier)
+}
+
+// webhookNotifier posts the expired tasks as JSON to the recipient URLs.
+// The body is the Message of the household, with its rendering by the template in the text field.
+type webhookNotifier struct {
+	tmpl  *template.Template
+	urls  []string
+	token string
+}
+
+// webhookPayload is the JSON body posted by the webhook channel.
+type webhookPayload struct {
+	Message
+	Title string `json:"title"`
+	Text  string `json:"text"`
+}
+
+func newWebhookNotifier(ch config.Channel) (Notifier, error) {
+	tmpl, err := parseText(ch.Template)
+	if err != nil {
+		return nil, err
+	}
+	return &webhookNotifier{tmpl: tmpl, urls: ch.Recipients, token: ch.Token}, nil
+}
+
+func (n *webhookNotifier) Notify(ctx context.Context, d Digest) error {
+	m := newMessage("", d.Tasks)
+	text, err := renderText(n.tmpl, m)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(webhookPayload{Message: m, Title: title(m), Text: text})
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{}
+	if n.token != "" {
+		header.Set("Authorization", "Bearer "+n.token)
+	}
+	var errs []error
+	for _, url := range n.urls {
+		errs = append(errs, post(ctx, url, "application/json", body, header))
+	}
+	return errors.Join(errs...)
+}
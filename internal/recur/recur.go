@@ -0,0 +1,452 @@
+package recur
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+=== RECURRENCE RULES ===
+A subset of the iCalendar RRULE (RFC 5545) with day granularity:
+FREQ (DAILY, WEEKLY, MONTHLY, YEARLY), INTERVAL, BYDAY, BYMONTHDAY and BYMONTH.
+Examples:
+  - FREQ=MONTHLY;BYDAY=1SA         first Saturday of each month
+  - FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR every weekday
+  - FREQ=MONTHLY;INTERVAL=3        every 3 months
+  - FREQ=YEARLY;BYMONTH=3          every March
+Weeks start on Monday. Occurrences are counted from a start day, the DTSTART of the rule.
+==================================
+*/
+
+// Freq is the base unit of a rule.
+type Freq int
+
+const (
+	Daily Freq = iota
+	Weekly
+	Monthly
+	Yearly
+)
+
+var freqNames = map[Freq]string{
+	Daily:   "DAILY",
+	Weekly:  "WEEKLY",
+	Monthly: "MONTHLY",
+	Yearly:  "YEARLY",
+}
+
+// Weekday is a BYDAY entry: a day of the week, optionally with its ordinal
+// within the month (or the year) such as 1 for the first or -1 for the last.
+type Weekday struct {
+	Day time.Weekday
+	N   int // 0 matches every such weekday
+}
+
+var weekdayNames = []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// Rule is a parsed recurrence rule.
+type Rule struct {
+	Freq       Freq
+	Interval   int
+	ByDay      []Weekday
+	ByMonthDay []int
+	ByMonth    []time.Month
+}
+
+// Days returns the rule repeating every n days.
+func Days(n int) Rule {
+	return Rule{Freq: Daily, Interval: n}
+}
+
+// MaxInterval is the largest INTERVAL accepted by Parse.
+const MaxInterval = 1000
+
+// maxSteps bounds the days and the skipped periods visited by Next.
+const maxSteps = 10000
+
+// ErrNoOccurrence is returned by Validate for rules that never occur, e.g. every February 30.
+var ErrNoOccurrence = errors.New("rule has no occurrence")
+
+// Parse parses a rule such as "FREQ=MONTHLY;BYDAY=1SA".
+// A leading "RRULE:" is accepted and ignored.
+func Parse(s string) (Rule, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(strings.ToUpper(s)), "RRULE:")
+	if s == "" {
+		return Rule{}, errors.New("empty rule")
+	}
+
+	r := Rule{Freq: -1, Interval: 1}
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(s, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok || value == "" {
+			return Rule{}, fmt.Errorf("invalid rule part %q", part)
+		}
+		if seen[key] {
+			return Rule{}, fmt.Errorf("duplicated rule part %s", key)
+		}
+		seen[key] = true
+
+		switch key {
+		case "FREQ":
+			f, err := parseFreq(value)
+			if err != nil {
+				return Rule{}, err
+			}
+			r.Freq = f
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 || n > MaxInterval {
+				return Rule{}, fmt.Errorf("invalid INTERVAL %q, must be between 1 and %d", value, MaxInterval)
+			}
+			r.Interval = n
+		case "BYDAY":
+			for _, v := range strings.Split(value, ",") {
+				wd, err := parseWeekday(v)
+				if err != nil {
+					return Rule{}, err
+				}
+				r.ByDay = append(r.ByDay, wd)
+			}
+		case "BYMONTHDAY":
+			for _, v := range strings.Split(value, ",") {
+				n, err := strconv.Atoi(v)
+				if err != nil || n == 0 || n < -31 || n > 31 {
+					return Rule{}, fmt.Errorf("invalid BYMONTHDAY %q", v)
+				}
+				r.ByMonthDay = append(r.ByMonthDay, n)
+			}
+		case "BYMONTH":
+			for _, v := range strings.Split(value, ",") {
+				n, err := strconv.Atoi(v)
+				if err != nil || n < 1 || n > 12 {
+					return Rule{}, fmt.Errorf("invalid BYMONTH %q", v)
+				}
+				r.ByMonth = append(r.ByMonth, time.Month(n))
+			}
+		default:
+			return Rule{}, fmt.Errorf("unsupported rule part %s", key)
+		}
+	}
+
+	if r.Freq < 0 {
+		return Rule{}, errors.New("FREQ is required")
+	}
+	if r.Freq == Daily || r.Freq == Weekly {
+		for _, wd := range r.ByDay {
+			if wd.N != 0 {
+				return Rule{}, fmt.Errorf("BYDAY ordinals are not allowed with FREQ=%s", freqNames[r.Freq])
+			}
+		}
+	}
+	if r.Freq == Weekly && len(r.ByMonthDay) > 0 {
+		return Rule{}, errors.New("BYMONTHDAY is not allowed with FREQ=WEEKLY")
+	}
+	return r, nil
+}
+
+// Validate returns ErrNoOccurrence if the rule never occurs after start.
+func (r Rule) Validate(start time.Time) error {
+	if r.Next(start, start).IsZero() {
+		return ErrNoOccurrence
+	}
+	return nil
+}
+
+// String formats the rule in its RRULE form.
+func (r Rule) String() string {
+	parts := []string{"FREQ=" + freqNames[r.Freq]}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, 0, len(r.ByDay))
+		for _, wd := range r.ByDay {
+			days = append(days, wd.String())
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		parts = append(parts, "BYMONTHDAY="+joinInts(r.ByMonthDay))
+	}
+	if len(r.ByMonth) > 0 {
+		months := make([]int, 0, len(r.ByMonth))
+		for _, m := range r.ByMonth {
+			months = append(months, int(m))
+		}
+		parts = append(parts, "BYMONTH="+joinInts(months))
+	}
+	return strings.Join(parts, ";")
+}
+
+// String formats the weekday in its BYDAY form, e.g. "1SA".
+func (wd Weekday) String() string {
+	if wd.N == 0 {
+		return weekdayNames[wd.Day]
+	}
+	return strconv.Itoa(wd.N) + weekdayNames[wd.Day]
+}
+
+// Next returns the first occurrence strictly after the day of after,
+// counting occurrences from the day of start. The result is midnight UTC.
+// It returns the zero time if the rule does not occur in the next years.
+func (r Rule) Next(start, after time.Time) time.Time {
+	start = day(start)
+	d := day(after).AddDate(0, 0, 1)
+	if d.Before(start) {
+		d = start
+	}
+
+	interval := max(r.Interval, 1)
+	limit := d.AddDate(9*interval, 0, 0)
+	for i := 0; i < maxSteps && d.Before(limit); i++ {
+		// Only the days of every interval-th period can match
+		if next := r.periodStart(start, d); next.After(d) {
+			d = next
+			continue
+		}
+		if r.matches(start, d) {
+			return d
+		}
+		d = d.AddDate(0, 0, 1)
+	}
+	return time.Time{}
+}
+
+// Describe returns a short human readable form of the rule, e.g. "every 3 months".
+func (r Rule) Describe() string {
+	units := map[Freq]string{Daily: "day", Weekly: "week", Monthly: "month", Yearly: "year"}
+	res := "every " + units[r.Freq]
+	if r.Interval > 1 {
+		res = fmt.Sprintf("every %d %ss", r.Interval, units[r.Freq])
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, 0, len(r.ByDay))
+		for _, wd := range r.ByDay {
+			name := wd.Day.String()[:3]
+			switch {
+			case wd.N == -1:
+				name = "last " + name
+			case wd.N < 0:
+				name = ordinal(-wd.N) + " to last " + name
+			case wd.N > 0:
+				name = ordinal(wd.N) + " " + name
+			}
+			days = append(days, name)
+		}
+		res += " on " + strings.Join(days, ", ")
+	}
+	if len(r.ByMonthDay) > 0 {
+		days := make([]string, 0, len(r.ByMonthDay))
+		for _, n := range r.ByMonthDay {
+			switch {
+			case n == -1:
+				days = append(days, "last day")
+			case n < 0:
+				days = append(days, ordinal(-n)+" to last day")
+			default:
+				days = append(days, "day "+strconv.Itoa(n))
+			}
+		}
+		res += " on " + strings.Join(days, ", ")
+	}
+	if len(r.ByMonth) > 0 {
+		months := make([]string, 0, len(r.ByMonth))
+		for _, m := range r.ByMonth {
+			months = append(months, m.String())
+		}
+		res += " in " + strings.Join(months, ", ")
+	}
+	return res
+}
+
+// === Helpers ===
+
+// matches reports whether d is an occurrence of the rule started at start.
+// Both days are midnight UTC and d is not before start.
+func (r Rule) matches(start, d time.Time) bool {
+	interval := max(r.Interval, 1)
+	switch r.Freq {
+	case Daily:
+		if daysBetween(start, d)%interval != 0 {
+			return false
+		}
+	case Weekly:
+		if daysBetween(weekStart(start), weekStart(d))/7%interval != 0 {
+			return false
+		}
+	case Monthly:
+		if monthsBetween(start, d)%interval != 0 {
+			return false
+		}
+	case Yearly:
+		if (d.Year()-start.Year())%interval != 0 {
+			return false
+		}
+	}
+
+	if len(r.ByMonth) > 0 && !slices.Contains(r.ByMonth, d.Month()) {
+		return false
+	}
+	if len(r.ByMonthDay) > 0 && !r.matchesMonthDay(d) {
+		return false
+	}
+	if len(r.ByDay) > 0 && !r.matchesWeekday(d) {
+		return false
+	}
+	if len(r.ByMonthDay) > 0 || len(r.ByDay) > 0 {
+		return true
+	}
+
+	// Without BYDAY and BYMONTHDAY, the rule repeats the day of start.
+	switch r.Freq {
+	case Weekly:
+		return d.Weekday() == start.Weekday()
+	case Monthly:
+		return d.Day() == start.Day()
+	case Yearly:
+		if len(r.ByMonth) == 0 && d.Month() != start.Month() {
+			return false
+		}
+		return d.Day() == start.Day()
+	default:
+		return true
+	}
+}
+
+// periodStart returns d if its period (day, week, month or year) is counted by the interval
+// from start, or else the first day of the next counted period.
+func (r Rule) periodStart(start, d time.Time) time.Time {
+	interval := max(r.Interval, 1)
+	switch r.Freq {
+	case Daily:
+		if off := daysBetween(start, d) % interval; off != 0 {
+			return d.AddDate(0, 0, interval-off)
+		}
+	case Weekly:
+		if off := daysBetween(weekStart(start), weekStart(d)) / 7 % interval; off != 0 {
+			return weekStart(d).AddDate(0, 0, 7*(interval-off))
+		}
+	case Monthly:
+		if off := monthsBetween(start, d) % interval; off != 0 {
+			return time.Date(d.Year(), d.Month()+time.Month(interval-off), 1, 0, 0, 0, 0, time.UTC)
+		}
+	case Yearly:
+		if off := (d.Year() - start.Year()) % interval; off != 0 {
+			return time.Date(d.Year()+interval-off, 1, 1, 0, 0, 0, 0, time.UTC)
+		}
+	}
+	return d
+}
+
+func (r Rule) matchesMonthDay(d time.Time) bool {
+	last := daysIn(d.Year(), d.Month())
+	for _, n := range r.ByMonthDay {
+		if n > 0 && d.Day() == n || n < 0 && d.Day() == last+n+1 {
+			return true
+		}
+	}
+	return false
+}
+
+func (r Rule) matchesWeekday(d time.Time) bool {
+	for _, wd := range r.ByDay {
+		if wd.Day != d.Weekday() {
+			continue
+		}
+		if wd.N == 0 {
+			return true
+		}
+
+		// Ordinals count within the month, or within the year
+		// for yearly rules without BYMONTH.
+		pos, total := d.Day(), daysIn(d.Year(), d.Month())
+		if r.Freq == Yearly && len(r.ByMonth) == 0 {
+			pos, total = d.YearDay(), time.Date(d.Year(), 12, 31, 0, 0, 0, 0, time.UTC).YearDay()
+		}
+		if wd.N > 0 && (pos-1)/7+1 == wd.N || wd.N < 0 && (total-pos)/7+1 == -wd.N {
+			return true
+		}
+	}
+	return false
+}
+
+func parseFreq(s string) (Freq, error) {
+	for f, name := range freqNames {
+		if name == s {
+			return f, nil
+		}
+	}
+	return 0, fmt.Errorf("unsupported FREQ %q", s)
+}
+
+func parseWeekday(s string) (Weekday, error) {
+	if len(s) < 2 {
+		return Weekday{}, fmt.Errorf("invalid BYDAY %q", s)
+	}
+	name, nStr := s[len(s)-2:], s[:len(s)-2]
+	i := slices.Index(weekdayNames, name)
+	if i < 0 {
+		return Weekday{}, fmt.Errorf("invalid BYDAY %q", s)
+	}
+	wd := Weekday{Day: time.Weekday(i)}
+	if nStr != "" {
+		n, err := strconv.Atoi(nStr)
+		if err != nil || n == 0 || n < -53 || n > 53 {
+			return Weekday{}, fmt.Errorf("invalid BYDAY %q", s)
+		}
+		wd.N = n
+	}
+	return wd, nil
+}
+
+// day returns midnight UTC of the day of t in UTC.
+func day(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func daysBetween(a, b time.Time) int {
+	return int(b.Sub(a).Hours() / 24)
+}
+
+func monthsBetween(a, b time.Time) int {
+	return (b.Year()-a.Year())*12 + int(b.Month()) - int(a.Month())
+}
+
+// weekStart returns the Monday of the week of d.
+func weekStart(d time.Time) time.Time {
+	offset := (int(d.Weekday()) + 6) % 7
+	return d.AddDate(0, 0, -offset)
+}
+
+func daysIn(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+func ordinal(n int) string {
+	switch {
+	case n%100 >= 11 && n%100 <= 13:
+		return strconv.Itoa(n) + "th"
+	case n%10 == 1:
+		return strconv.Itoa(n) + "st"
+	case n%10 == 2:
+		return strconv.Itoa(n) + "nd"
+	case n%10 == 3:
+		return strconv.Itoa(n) + "rd"
+	default:
+		return strconv.Itoa(n) + "th"
+	}
+}
+
+func joinInts(xs []int) string {
+	strs := make([]string, 0, len(xs))
+	for _, x := range xs {
+		strs = append(strs, strconv.Itoa(x))
+	}
+	return strings.Join(strs, ",")
+}
@@ -0,0 +1,147 @@
+package recur
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func date(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestParseString(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string // String of the parsed rule
+	}{
+		{"FREQ=DAILY", "FREQ=DAILY"},
+		{"rrule:freq=daily;interval=1", "FREQ=DAILY"},
+		{"FREQ=MONTHLY;BYDAY=1SA", "FREQ=MONTHLY;BYDAY=1SA"},
+		{"FREQ=MONTHLY;BYDAY=-1FR", "FREQ=MONTHLY;BYDAY=-1FR"},
+		{"FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR", "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR"},
+		{"FREQ=MONTHLY;INTERVAL=3", "FREQ=MONTHLY;INTERVAL=3"},
+		{"FREQ=MONTHLY;BYMONTHDAY=1,-1", "FREQ=MONTHLY;BYMONTHDAY=1,-1"},
+		{"FREQ=YEARLY;BYMONTH=3", "FREQ=YEARLY;BYMONTH=3"},
+		{"BYMONTH=2,8;BYMONTHDAY=29;FREQ=YEARLY;INTERVAL=1000", "FREQ=YEARLY;INTERVAL=1000;BYMONTHDAY=29;BYMONTH=2,8"},
+	}
+	for _, tt := range tests {
+		r, err := Parse(tt.in)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.in, err)
+			continue
+		}
+		if got := r.String(); got != tt.want {
+			t.Errorf("Parse(%q).String() = %q, want %q", tt.in, got, tt.want)
+		}
+		again, err := Parse(r.String())
+		if err != nil || again.String() != r.String() {
+			t.Errorf("Parse(%q) = %q, %v, want the same rule", r.String(), again.String(), err)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, in := range []string{
+		"",
+		"RRULE:",
+		"INTERVAL=2",
+		"FREQ=HOURLY",
+		"FREQ=DAILY;FREQ=WEEKLY",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;INTERVAL=1001",
+		"FREQ=DAILY;INTERVAL=x",
+		"FREQ=DAILY;COUNT=3",
+		"FREQ=DAILY;BYDAY=",
+		"FREQ=WEEKLY;BYDAY=1MO",
+		"FREQ=WEEKLY;BYMONTHDAY=1",
+		"FREQ=MONTHLY;BYDAY=0SA",
+		"FREQ=MONTHLY;BYDAY=XX",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=MONTHLY;BYMONTHDAY=0",
+		"FREQ=YEARLY;BYMONTH=13",
+	} {
+		if r, err := Parse(in); err == nil {
+			t.Errorf("Parse(%q) = %q, want an error", in, r)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	tests := []struct {
+		rule  string
+		start string
+		after string
+		want  string
+	}{
+		// BYDAY ordinals
+		{"FREQ=MONTHLY;BYDAY=1SA", "2025-01-01", "2025-01-01", "2025-01-04"},
+		{"FREQ=MONTHLY;BYDAY=1SA", "2025-01-01", "2025-01-04", "2025-02-01"},
+		{"FREQ=MONTHLY;BYDAY=-1FR", "2025-01-01", "2025-01-01", "2025-01-31"},
+		{"FREQ=MONTHLY;BYDAY=-1FR", "2025-01-01", "2025-01-31", "2025-02-28"},
+		{"FREQ=YEARLY;BYDAY=1SA", "2025-01-01", "2025-01-04", "2026-01-03"},
+		{"FREQ=WEEKLY;BYDAY=MO,FR", "2025-01-01", "2025-01-03", "2025-01-06"},
+		// Negative BYMONTHDAY
+		{"FREQ=MONTHLY;BYMONTHDAY=-1", "2025-01-01", "2025-02-01", "2025-02-28"},
+		{"FREQ=MONTHLY;BYMONTHDAY=-1", "2024-01-01", "2024-02-01", "2024-02-29"},
+		{"FREQ=MONTHLY;BYMONTHDAY=-2", "2025-01-01", "2025-01-01", "2025-01-30"},
+		// INTERVAL counted from the start
+		{"FREQ=DAILY;INTERVAL=10", "2025-01-01", "2025-01-05", "2025-01-11"},
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=MO", "2025-01-01", "2025-01-01", "2025-01-13"},
+		{"FREQ=MONTHLY;INTERVAL=3", "2025-01-15", "2025-01-15", "2025-04-15"},
+		{"FREQ=MONTHLY;INTERVAL=3", "2025-01-15", "2025-05-01", "2025-07-15"},
+		{"FREQ=YEARLY;INTERVAL=2;BYMONTH=3;BYMONTHDAY=1", "2025-01-01", "2025-06-01", "2027-03-01"},
+		{"FREQ=YEARLY;INTERVAL=1000;BYMONTH=3;BYMONTHDAY=1", "2025-01-01", "2025-06-01", "3025-03-01"},
+		// Occurrences not before the start
+		{"FREQ=WEEKLY", "2025-01-15", "2025-01-01", "2025-01-15"},
+		// Rare occurrences
+		{"FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=29", "2025-01-01", "2025-01-01", "2028-02-29"},
+	}
+	for _, tt := range tests {
+		r, err := Parse(tt.rule)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.rule, err)
+		}
+		got := r.Next(date(tt.start), date(tt.after))
+		if !got.Equal(date(tt.want)) {
+			t.Errorf("%s from %s: Next(%s) = %s, want %s", tt.rule, tt.start, tt.after, got.Format("2006-01-02"), tt.want)
+		}
+	}
+}
+
+func TestNextDays(t *testing.T) {
+	got := Days(3).Next(date("2025-01-01"), date("2025-01-02"))
+	if want := date("2025-01-04"); !got.Equal(want) {
+		t.Errorf("Days(3).Next = %s, want %s", got.Format("2006-01-02"), want.Format("2006-01-02"))
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		rule string
+		want error
+	}{
+		{"FREQ=MONTHLY;BYDAY=1SA", nil},
+		{"FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=29", nil},
+		{"FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30", ErrNoOccurrence},
+		{"FREQ=MONTHLY;BYMONTH=4;BYMONTHDAY=31", ErrNoOccurrence},
+		// Every other year from 2025 never reaches a leap year
+		{"FREQ=YEARLY;INTERVAL=2;BYMONTH=2;BYMONTHDAY=29", ErrNoOccurrence},
+		// Large intervals are stepped by period, not by day
+		{"FREQ=YEARLY;INTERVAL=1000;BYMONTH=2;BYMONTHDAY=30", ErrNoOccurrence},
+		{"FREQ=DAILY;INTERVAL=1000;BYMONTH=2;BYMONTHDAY=30", ErrNoOccurrence},
+	}
+	for _, tt := range tests {
+		r, err := Parse(tt.rule)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.rule, err)
+		}
+		if err := r.Validate(date("2025-01-01")); !errors.Is(err, tt.want) {
+			t.Errorf("Validate(%q) = %v, want %v", tt.rule, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package transfer
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CSVHeader lists the columns of the CSV format, in the order written by Write.
+// Read accepts them in any order, and missing columns are left empty.
+var CSVHeader = []string{
+	"name",
+	"description",
+	"period",
+	"recurrence",
+	"schedule",
+	"anchor",
+	"due",
+	"group",
+	"assignee",
+	"rotation_mode",
+	"rotation",
+	"last_completed",
+	"snoozed_until",
+	"skipped_until",
+	"completions",
+}
+
+// csvListSep separates the items of the rotation and completions columns.
+const csvListSep = ";"
+
+func writeCSV(w io.Writer, doc Document) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(CSVHeader); err != nil {
+		return err
+	}
+	for _, t := range doc.Tasks {
+		completions := make([]string, 0, len(t.Completions))
+		for _, c := range t.Completions {
+			completions = append(completions, c.At.UTC().Format(time.RFC3339))
+		}
+		period := ""
+		if t.Period != 0 {
+			period = strconv.Itoa(t.Period)
+		}
+		if err := cw.Write([]string{
+			t.Name,
+			t.Description,
+			period,
+			t.Recurrence,
+			t.Schedule,
+			t.Anchor,
+			t.Due,
+			t.Group,
+			t.Assignee,
+			t.RotationMode,
+			strings.Join(t.Rotation, csvListSep),
+			t.LastCompleted,
+			t.SnoozedUntil,
+			t.SkippedUntil,
+			strings.Join(completions, csvListSep),
+		}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func readCSV(r io.Reader) (Document, error) {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return Document{}, errors.New("empty CSV file")
+	}
+	if err != nil {
+		return Document{}, fmt.Errorf("read CSV header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !slices.Contains(CSVHeader, name) {
+			return Document{}, fmt.Errorf("unknown CSV column %q", name)
+		}
+		columns[name] = i
+	}
+	if _, ok := columns["name"]; !ok {
+		return Document{}, errors.New("missing CSV column \"name\"")
+	}
+
+	doc := Document{Version: Version}
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return Document{}, fmt.Errorf("read CSV: %w", err)
+		}
+		line, _ := cr.FieldPos(0)
+		get := func(column string) string {
+			i, ok := columns[column]
+			if !ok {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		task := Task{
+			Name:          get("name"),
+			Description:   get("description"),
+			Recurrence:    get("recurrence"),
+			Schedule:      get("schedule"),
+			Anchor:        get("anchor"),
+			Due:           get("due"),
+			Group:         get("group"),
+			Assignee:      get("assignee"),
+			RotationMode:  get("rotation_mode"),
+			Rotation:      splitCSVList(get("rotation")),
+			LastCompleted: get("last_completed"),
+			SnoozedUntil:  get("snoozed_until"),
+			SkippedUntil:  get("skipped_until"),
+		}
+		if period := get("period"); period != "" {
+			task.Period, err = strconv.Atoi(period)
+			if err != nil {
+				return Document{}, fmt.Errorf("line %d: invalid period %q", line, period)
+			}
+		}
+		for _, at := range splitCSVList(get("completions")) {
+			t, err := time.Parse(time.RFC3339, at)
+			if err != nil {
+				return Document{}, fmt.Errorf("line %d: invalid completion time %q", line, at)
+			}
+			task.Completions = append(task.Completions, Completion{At: t})
+		}
+		doc.Tasks = append(doc.Tasks, task)
+	}
+	return doc, nil
+}
+
+// splitCSVList splits a list column, "" being the empty list.
+func splitCSVList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	items := strings.Split(s, csvListSep)
+	for i := range items {
+		items[i] = strings.TrimSpace(items[i])
+	}
+	return items
+}
@@ -0,0 +1,566 @@
+package transfer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/markor147/peverel/internal/data"
+	"github.com/markor147/peverel/internal/recur"
+)
+
+/*
+=== EXPORT FORMAT ===
+The JSON format, version 1, is a Document:
+
+	{
+	  "version": 1,
+	  "exported_at": "2026-10-18T09:00:00Z",
+	  "groups": [{"name": "kitchen"}],
+	  "members": [{"name": "Ann", "email": "ann@example.org"}],
+	  "tasks": [
+	    {
+	      "name": "descale the kettle",
+	      "description": "vinegar, then rinse twice",
+	      "period": 30,                       // days, when there is no recurrence
+	      "recurrence": "FREQ=MONTHLY;BYDAY=1SA",
+	      "schedule": "fixed",                // floating (default) or fixed
+	      "anchor": "2026-01-03",             // first due day of fixed tasks
+	      "due": "2026-12-01",                // makes a one-off task
+	      "group": "kitchen",
+	      "assignee": "Ann",
+	      "rotation_mode": "round-robin",     // or least-recent
+	      "rotation": ["Ann", "Bob"],
+	      "last_completed": "2026-10-01T08:00:00Z",
+	      "snoozed_until": "2026-10-20",
+	      "skipped_until": "2026-10-05",
+	      "completions": [
+	        {"at": "2026-10-01T08:00:00Z", "member": "Ann", "note": "used citric acid"}
+	      ]
+	    }
+	  ]
+	}
+
+Groups, members and rotations refer to each other by name, so that a document
+can be imported in another database. Empty fields are omitted.
+
+The CSV format has a header row and a row per task, with the columns of CSVHeader.
+Rotations are lists of member names separated by ";", and completions are lists
+of RFC3339 times separated by ";": the CSV format does not keep who completed
+the tasks nor the notes, and members get no email.
+*/
+
+// Version is the version of the JSON format written by Write.
+const Version = 1
+
+// Document is the content of an export.
+type Document struct {
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exported_at"`
+	Groups     []Group   `json:"groups"`
+	Members    []Member  `json:"members"`
+	Tasks      []Task    `json:"tasks"`
+}
+
+type Group struct {
+	Name string `json:"name"`
+}
+
+type Member struct {
+	Name  string `json:"name"`
+	Email string `json:"email,omitempty"`
+}
+
+type Task struct {
+	Name          string       `json:"name"`
+	Description   string       `json:"description,omitempty"`
+	Period        int          `json:"period,omitempty"`
+	Recurrence    string       `json:"recurrence,omitempty"`
+	Schedule      string       `json:"schedule,omitempty"`
+	Anchor        string       `json:"anchor,omitempty"`
+	Due           string       `json:"due,omitempty"`
+	Group         string       `json:"group,omitempty"`
+	Assignee      string       `json:"assignee,omitempty"`
+	RotationMode  string       `json:"rotation_mode,omitempty"`
+	Rotation      []string     `json:"rotation,omitempty"`
+	LastCompleted string       `json:"last_completed,omitempty"`
+	SnoozedUntil  string       `json:"snoozed_until,omitempty"`
+	SkippedUntil  string       `json:"skipped_until,omitempty"`
+	Completions   []Completion `json:"completions,omitempty"`
+}
+
+type Completion struct {
+	At     time.Time `json:"at"`
+	Member string    `json:"member,omitempty"`
+	By     string    `json:"by,omitempty"` // who completed the task, when not a member
+	Note   string    `json:"note,omitempty"`
+}
+
+// Format is the encoding of a Document.
+type Format string
+
+const (
+	JSON Format = "json"
+	CSV  Format = "csv"
+)
+
+// ParseFormat parses the name of a format.
+func ParseFormat(s string) (Format, error) {
+	switch Format(strings.ToLower(s)) {
+	case JSON:
+		return JSON, nil
+	case CSV:
+		return CSV, nil
+	default:
+		return "", fmt.Errorf("invalid format %q, expected json or csv", s)
+	}
+}
+
+// FormatOf returns the format of a file from its extension.
+func FormatOf(filename string) (Format, error) {
+	ext := strings.TrimPrefix(filepath.Ext(filename), ".")
+	if ext == "" {
+		return "", fmt.Errorf("unknown format of %q", filename)
+	}
+	return ParseFormat(ext)
+}
+
+// Write encodes the document in the given format.
+func Write(w io.Writer, doc Document, format Format) error {
+	switch format {
+	case JSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(doc)
+	case CSV:
+		return writeCSV(w, doc)
+	default:
+		return fmt.Errorf("invalid format %q", format)
+	}
+}
+
+// Read decodes a document in the given format.
+func Read(r io.Reader, format Format) (Document, error) {
+	switch format {
+	case JSON:
+		var doc Document
+		dec := json.NewDecoder(r)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&doc); err != nil {
+			return Document{}, fmt.Errorf("decode JSON: %w", err)
+		}
+		if doc.Version > Version {
+			return Document{}, fmt.Errorf("unsupported version %d, expected at most %d", doc.Version, Version)
+		}
+		return doc, nil
+	case CSV:
+		return readCSV(r)
+	default:
+		return Document{}, fmt.Errorf("invalid format %q", format)
+	}
+}
+
+// Export returns every task of the store with its completion history,
+// together with all the groups and members.
+func Export(ctx context.Context, store data.Store) (Document, error) {
+	groups, err := store.GetGroups(ctx)
+	if err != nil {
+		return Document{}, err
+	}
+	members, err := store.GetMembers(ctx)
+	if err != nil {
+		return Document{}, err
+	}
+	tasks, err := store.Tasks(ctx, data.TaskFilter{Done: true})
+	if err != nil {
+		return Document{}, err
+	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].Id < tasks[j].Id })
+
+	doc := Document{
+		Version:    Version,
+		ExportedAt: time.Now().UTC().Truncate(time.Second),
+		Groups:     make([]Group, 0, len(groups)),
+		Members:    make([]Member, 0, len(members)),
+		Tasks:      make([]Task, 0, len(tasks)),
+	}
+	for _, g := range groups {
+		doc.Groups = append(doc.Groups, Group{Name: g.Name})
+	}
+	memberNames := make(map[data.MemberId]string, len(members))
+	for _, m := range members {
+		doc.Members = append(doc.Members, Member{Name: m.Name, Email: m.Email})
+		memberNames[m.Id] = m.Name
+	}
+
+	for _, t := range tasks {
+		completions, err := store.Completions(ctx, t.Id)
+		if err != nil {
+			return Document{}, err
+		}
+
+		task := Task{
+			Name:          t.Name,
+			Description:   t.Description,
+			Period:        t.Period,
+			Recurrence:    t.Recurrence,
+			Group:         t.GroupName,
+			Assignee:      t.AssigneeName,
+			RotationMode:  string(t.RotationMode),
+			LastCompleted: formatTime(t.LastCompleted),
+			SnoozedUntil:  formatDate(t.SnoozedUntil),
+			SkippedUntil:  formatDate(t.SkippedUntil),
+		}
+		if t.OneOff() {
+			task.Due = formatDate(t.DueDate)
+		} else if t.Schedule == data.Fixed {
+			task.Schedule = string(data.Fixed)
+			task.Anchor = formatDate(t.Anchor)
+		}
+		for _, id := range t.Rotation {
+			task.Rotation = append(task.Rotation, memberNames[id])
+		}
+		// Oldest first, as they happened
+		for i := len(completions) - 1; i >= 0; i-- {
+			c := completions[i]
+			completion := Completion{At: c.At.UTC(), Note: c.Note}
+			if name, ok := memberNames[c.MemberId]; ok {
+				completion.Member = name
+			} else {
+				completion.By = c.By
+			}
+			task.Completions = append(task.Completions, completion)
+		}
+		doc.Tasks = append(doc.Tasks, task)
+	}
+	return doc, nil
+}
+
+// Options tell how Import handles the tasks already in the store.
+type Options struct {
+	// Replace deletes every task before importing. Otherwise the imported tasks
+	// are merged: those with the name of an existing task are skipped.
+	Replace bool
+	// DryRun only reports what the import would do.
+	DryRun bool
+}
+
+// Report tells what an import did, or would do with a dry run.
+type Report struct {
+	DryRun         bool
+	Created        []string      // names of the imported tasks
+	Skipped        []string      // names of the tasks conflicting with existing ones
+	Deleted        int           // number of tasks deleted by a replace
+	DeletedIds     []data.TaskId // ids of the tasks deleted by a replace
+	Completions    int           // number of imported completions
+	GroupsCreated  []string
+	MembersCreated []string
+}
+
+func (r Report) String() string {
+	var b strings.Builder
+	if r.DryRun {
+		b.WriteString("dry run, nothing was changed\n")
+	}
+	if r.Deleted > 0 {
+		fmt.Fprintf(&b, "tasks deleted: %d\n", r.Deleted)
+	}
+	fmt.Fprintf(&b, "tasks imported: %d, with %d completions\n", len(r.Created), r.Completions)
+	if len(r.Skipped) > 0 {
+		fmt.Fprintf(&b, "tasks skipped, a task with the same name exists: %s\n", strings.Join(r.Skipped, ", "))
+	}
+	if len(r.GroupsCreated) > 0 {
+		fmt.Fprintf(&b, "groups created: %s\n", strings.Join(r.GroupsCreated, ", "))
+	}
+	if len(r.MembersCreated) > 0 {
+		fmt.Fprintf(&b, "members created: %s\n", strings.Join(r.MembersCreated, ", "))
+	}
+	return b.String()
+}
+
+// Import adds the tasks of the document, with their completion history, to the store.
+// The groups and members the tasks refer to are created if missing.
+// The document is validated as a whole first, and the groups, members and tasks are added
+// in a single transaction.
+func Import(ctx context.Context, store data.Store, doc Document, opts Options) (Report, []data.TaskId, error) {
+	if doc.Version > Version {
+		return Report{}, nil, fmt.Errorf("unsupported version %d, expected at most %d", doc.Version, Version)
+	}
+
+	existingTasks, err := store.Tasks(ctx, data.TaskFilter{Done: true})
+	if err != nil {
+		return Report{}, nil, err
+	}
+	groups, err := store.GetGroups(ctx)
+	if err != nil {
+		return Report{}, nil, err
+	}
+	members, err := store.GetMembers(ctx)
+	if err != nil {
+		return Report{}, nil, err
+	}
+
+	report := Report{DryRun: opts.DryRun}
+	existing := make(map[string]bool, len(existingTasks))
+	for _, t := range existingTasks {
+		existing[t.Name] = true
+	}
+	if opts.Replace {
+		report.Deleted = len(existingTasks)
+		for _, t := range existingTasks {
+			report.DeletedIds = append(report.DeletedIds, t.Id)
+		}
+		existing = map[string]bool{}
+	}
+
+	// Validate the tasks and keep those not conflicting
+	var (
+		tasks []Task
+		errs  []error
+		seen  = make(map[string]bool)
+	)
+	for i, t := range doc.Tasks {
+		t.Name = strings.TrimSpace(t.Name)
+		if err := validateTask(t); err != nil {
+			errs = append(errs, fmt.Errorf("task %d (%q): %w", i+1, t.Name, err))
+			continue
+		}
+		if seen[t.Name] {
+			errs = append(errs, fmt.Errorf("task %d (%q): name used by another task of the document", i+1, t.Name))
+			continue
+		}
+		seen[t.Name] = true
+		if existing[t.Name] {
+			report.Skipped = append(report.Skipped, t.Name)
+			continue
+		}
+		tasks = append(tasks, t)
+		report.Created = append(report.Created, t.Name)
+		report.Completions += len(t.Completions)
+	}
+	if len(errs) > 0 {
+		return Report{}, nil, errors.Join(errs...)
+	}
+
+	// Groups and members referred to but missing
+	groupIds := make(map[string]data.GroupId, len(groups))
+	for _, g := range groups {
+		groupIds[g.Name] = g.Id
+	}
+	memberIds := make(map[string]data.MemberId, len(members))
+	for _, m := range members {
+		memberIds[m.Name] = m.Id
+	}
+	var newGroups []string
+	addGroup := func(name string) {
+		if _, ok := groupIds[name]; name != "" && !ok && !slices.Contains(newGroups, name) {
+			newGroups = append(newGroups, name)
+		}
+	}
+	var newMembers []Member
+	emails := make(map[string]string, len(doc.Members))
+	for _, m := range doc.Members {
+		emails[m.Name] = m.Email
+	}
+	addMember := func(name string) {
+		if _, ok := memberIds[name]; name == "" || ok {
+			return
+		}
+		for _, m := range newMembers {
+			if m.Name == name {
+				return
+			}
+		}
+		newMembers = append(newMembers, Member{Name: name, Email: emails[name]})
+	}
+	for _, g := range doc.Groups {
+		addGroup(g.Name)
+	}
+	for _, m := range doc.Members {
+		addMember(m.Name)
+	}
+	for _, t := range tasks {
+		addGroup(t.Group)
+		addMember(t.Assignee)
+		for _, name := range t.Rotation {
+			addMember(name)
+		}
+		for _, c := range t.Completions {
+			addMember(c.Member)
+		}
+	}
+	report.GroupsCreated = newGroups
+	for _, m := range newMembers {
+		report.MembersCreated = append(report.MembersCreated, m.Name)
+	}
+
+	if opts.DryRun {
+		return report, nil, nil
+	}
+
+	imp := data.Import{
+		Groups:  make([]data.Group, 0, len(newGroups)),
+		Members: make([]data.Member, 0, len(newMembers)),
+		Tasks: func(groupIds map[string]data.GroupId, memberIds map[string]data.MemberId) []data.TaskHistory {
+			histories := make([]data.TaskHistory, 0, len(tasks))
+			for _, t := range tasks {
+				histories = append(histories, toTaskHistory(t, groupIds, memberIds))
+			}
+			return histories
+		},
+	}
+	for _, name := range newGroups {
+		imp.Groups = append(imp.Groups, data.Group{Name: name})
+	}
+	for _, m := range newMembers {
+		imp.Members = append(imp.Members, data.Member{Name: m.Name, Email: m.Email})
+	}
+	ids, err := store.ImportTasks(ctx, imp, opts.Replace)
+	if err != nil {
+		return Report{}, nil, err
+	}
+	return report, ids, nil
+}
+
+// === Helpers ===
+
+// validateTask checks the fields of an imported task, as the task forms do.
+func validateTask(t Task) error {
+	if t.Name == "" {
+		return errors.New("name is required")
+	}
+	if _, err := data.ParseSchedule(t.Schedule); err != nil {
+		return err
+	}
+	mode, err := data.ParseRotationMode(t.RotationMode)
+	if err != nil {
+		return err
+	}
+	if mode != data.NoRotation && len(t.Rotation) == 0 {
+		return errors.New("a rotation needs at least one member")
+	}
+	if slices.Contains(t.Rotation, "") {
+		return errors.New("rotation with an empty member name")
+	}
+	if t.Recurrence != "" {
+		if _, err := recur.Parse(t.Recurrence); err != nil {
+			return fmt.Errorf("invalid recurrence: %w", err)
+		}
+	}
+	if t.Due == "" && t.Recurrence == "" && t.Period <= 0 {
+		return errors.New("period must be greater than zero")
+	}
+	for field, value := range map[string]string{
+		"anchor":        t.Anchor,
+		"due":           t.Due,
+		"snoozed_until": t.SnoozedUntil,
+		"skipped_until": t.SkippedUntil,
+	} {
+		if _, err := parseDate(value); err != nil {
+			return fmt.Errorf("invalid %s %q, expected YYYY-MM-DD", field, value)
+		}
+	}
+	if _, err := parseTime(t.LastCompleted); err != nil {
+		return fmt.Errorf("invalid last_completed %q, expected an RFC3339 time", t.LastCompleted)
+	}
+	for _, c := range t.Completions {
+		if c.At.IsZero() {
+			return errors.New("completion without time")
+		}
+	}
+	return nil
+}
+
+// toTaskHistory converts a validated task, resolving the names of groups and members.
+func toTaskHistory(t Task, groupIds map[string]data.GroupId, memberIds map[string]data.MemberId) data.TaskHistory {
+	task := data.Task{
+		Name:        t.Name,
+		Description: t.Description,
+		Period:      t.Period,
+		GroupId:     data.NoGroup,
+		AssigneeId:  data.NoMember,
+	}
+	task.Schedule, _ = data.ParseSchedule(t.Schedule)
+	task.RotationMode, _ = data.ParseRotationMode(t.RotationMode)
+	if t.Recurrence != "" {
+		rule, _ := recur.Parse(t.Recurrence)
+		task.Recurrence = rule.String()
+	}
+	task.Anchor, _ = parseDate(t.Anchor)
+	task.DueDate, _ = parseDate(t.Due)
+	task.SnoozedUntil, _ = parseDate(t.SnoozedUntil)
+	task.SkippedUntil, _ = parseDate(t.SkippedUntil)
+	task.LastCompleted, _ = parseTime(t.LastCompleted)
+	if task.OneOff() {
+		task.Schedule = data.Floating
+		task.Anchor = time.Time{}
+	} else if task.Schedule == data.Fixed && task.Anchor.IsZero() {
+		task.Anchor = data.Today()
+	}
+	if task.LastCompleted.IsZero() {
+		task.LastCompleted = time.Now()
+	}
+	if id, ok := groupIds[t.Group]; ok && t.Group != "" {
+		task.GroupId = id
+	}
+	if id, ok := memberIds[t.Assignee]; ok && t.Assignee != "" {
+		task.AssigneeId = id
+	}
+	if task.RotationMode != data.NoRotation {
+		for _, name := range t.Rotation {
+			task.Rotation = append(task.Rotation, memberIds[name])
+		}
+		if task.AssigneeId == data.NoMember {
+			task.AssigneeId = task.Rotation[0]
+		}
+	}
+
+	completions := make([]data.Completion, 0, len(t.Completions))
+	for _, c := range t.Completions {
+		completion := data.Completion{At: c.At, MemberId: data.NoMember, By: c.By, Note: c.Note}
+		if id, ok := memberIds[c.Member]; ok && c.Member != "" {
+			completion.MemberId = id
+		}
+		completions = append(completions, completion)
+	}
+	return data.TaskHistory{Task: task, Completions: completions}
+}
+
+// formatDate formats a day as YYYY-MM-DD, or "" for the zero time.
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format("2006-01-02")
+}
+
+// formatTime formats a time as RFC3339 UTC, or "" for the zero time.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// parseDate parses a YYYY-MM-DD day, "" being the zero time.
+func parseDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse("2006-01-02", s)
+}
+
+// parseTime parses an RFC3339 time, "" being the zero time.
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
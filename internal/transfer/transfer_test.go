@@ -0,0 +1,100 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/markor147/peverel/internal/data"
+)
+
+// staleStore is a MemStore whose GetMembers misses the members,
+// as if they had been added by another request during the import.
+type staleStore struct {
+	*data.MemStore
+}
+
+func (s staleStore) GetMembers(ctx context.Context) ([]data.Member, error) {
+	return nil, nil
+}
+
+var importDoc = Document{
+	Version: Version,
+	Groups:  []Group{{Name: "Kitchen"}},
+	Members: []Member{{Name: "Ann"}},
+	Tasks: []Task{{
+		Name:     "Dishes",
+		Period:   3,
+		Group:    "Garden",
+		Assignee: "Bob",
+		Completions: []Completion{
+			{At: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), Member: "Cat"},
+		},
+	}},
+}
+
+func TestImportRollsBack(t *testing.T) {
+	ctx := context.Background()
+	store := staleStore{data.NewMemStore()}
+	if _, err := store.AddMember(ctx, data.Member{Name: "Cat"}); err != nil {
+		t.Fatalf("AddMember: %v", err)
+	}
+
+	_, _, err := Import(ctx, store, importDoc, Options{})
+	if !errors.Is(err, data.ErrConflict) {
+		t.Fatalf("Import = %v, want ErrConflict for the member created meanwhile", err)
+	}
+
+	groups, err := store.GetGroups(ctx)
+	if err != nil {
+		t.Fatalf("GetGroups: %v", err)
+	}
+	if len(groups) != 0 {
+		t.Errorf("groups after a failed import = %+v, want none", groups)
+	}
+	members, err := store.MemStore.GetMembers(ctx)
+	if err != nil {
+		t.Fatalf("GetMembers: %v", err)
+	}
+	if len(members) != 1 || members[0].Name != "Cat" {
+		t.Errorf("members after a failed import = %+v, want only the existing one", members)
+	}
+	tasks, err := store.Tasks(ctx, data.TaskFilter{Done: true})
+	if err != nil {
+		t.Fatalf("Tasks: %v", err)
+	}
+	if len(tasks) != 0 {
+		t.Errorf("tasks after a failed import = %+v, want none", tasks)
+	}
+}
+
+func TestImportReplace(t *testing.T) {
+	ctx := context.Background()
+	store := data.NewMemStore()
+	old, err := store.AddTask(ctx, data.Task{Name: "Vacuum", Period: 7, LastCompleted: time.Now()})
+	if err != nil {
+		t.Fatalf("AddTask: %v", err)
+	}
+
+	report, ids, err := Import(ctx, store, importDoc, Options{Replace: true})
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if report.Deleted != 1 || len(report.DeletedIds) != 1 || report.DeletedIds[0] != old {
+		t.Errorf("Import deleted %d tasks %v, want task %d", report.Deleted, report.DeletedIds, old)
+	}
+	if len(ids) != 1 {
+		t.Fatalf("Import created %d tasks, want 1", len(ids))
+	}
+	task, err := store.GetTask(ctx, ids[0])
+	if err != nil {
+		t.Fatalf("GetTask: %v", err)
+	}
+	if task.GroupName != "Garden" || task.AssigneeName != "Bob" || task.Completions != 1 {
+		t.Errorf("imported task = %+v, want it in Garden, assigned to Bob, with 1 completion", task)
+	}
+	if _, err := store.GetTask(ctx, old); !errors.Is(err, data.ErrNotFound) {
+		t.Errorf("GetTask of the replaced task = %v, want ErrNotFound", err)
+	}
+}
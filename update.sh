@@ -1,5 +1,12 @@
 #!/bin/bash
 go build -o ./build/peverel ./cmd/peverel/
-go build -o ./build/peverel-notifier ./cmd/notifier/
 sudo install -o root -g root -m 0755 ./build/peverel /usr/local/bin/peverel
-sudo install -o root -g root -m 0755 ./build/peverel-notifier /usr/local/bin/peverel-notifier
\ No newline at end of file
+# `peverel notify` does the job of peverel-notifier, which is only updated where already installed
+if [ -x /usr/local/bin/peverel-notifier ]; then
+    go build -o ./build/peverel-notifier ./cmd/notifier/
+    sudo install -o root -g root -m 0755 ./build/peverel-notifier /usr/local/bin/peverel-notifier
+fi
+# Apply pending schema migrations (the server also applies them at startup)
+if [ -n "$DB_CONN_STRING" ]; then
+    LOG_LEVEL=info LOG_OUTPUT=stderr /usr/local/bin/peverel migrate up
+fi